@@ -0,0 +1,39 @@
+//go:build go1.23
+
+package cmap
+
+import "iter"
+
+// All returns an iterator over the keys and values present in the map,
+// for use with range-over-func:
+//
+//	for k, v := range m.All() {
+//		...
+//	}
+//
+// The entries are copied out one bucket at a time, so the loop body never
+// runs while a bucket is being read, and breaking out of the loop stops
+// the iteration at once. Like Range, All does not correspond to any
+// consistent snapshot of the map's contents.
+func (m *CMap) All() iter.Seq2[interface{}, interface{}] {
+	return func(yield func(key, value interface{}) bool) {
+		m.rangeBuckets(yield)
+	}
+}
+
+// Keys returns an iterator over the keys present in the map, see All and
+// RangeKeys.
+func (m *CMap) Keys() iter.Seq[interface{}] {
+	return func(yield func(key interface{}) bool) {
+		m.RangeKeys(yield)
+	}
+}
+
+// Values returns an iterator over the values present in the map, see All.
+func (m *CMap) Values() iter.Seq[interface{}] {
+	return func(yield func(value interface{}) bool) {
+		m.rangeBuckets(func(_, value interface{}) bool {
+			return yield(value)
+		})
+	}
+}
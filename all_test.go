@@ -0,0 +1,45 @@
+//go:build go1.23
+
+package cmap_test
+
+import (
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestAll(t *testing.T) {
+	var m cmap.CMap
+	for i := 0; i < 100; i++ {
+		m.Store(i, i*2)
+	}
+
+	seen := make(map[interface{}]bool)
+	for k, v := range m.All() {
+		if v != k.(int)*2 {
+			t.Fatalf("All yields %v: %v", k, v)
+		}
+		seen[k] = true
+	}
+	if len(seen) != 100 {
+		t.Fatalf("All yields %d keys, want 100", len(seen))
+	}
+
+	n := 0
+	for range m.Keys() {
+		if n++; n == 10 {
+			break
+		}
+	}
+	if n != 10 {
+		t.Fatalf("Keys did not stop at break")
+	}
+
+	sum := 0
+	for v := range m.Values() {
+		sum += v.(int)
+	}
+	if sum != 99*100 {
+		t.Fatalf("Values sum = %d, want %d", sum, 99*100)
+	}
+}
@@ -0,0 +1,68 @@
+package cmap_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/min1324/cmap"
+)
+
+type point struct{ x, y int }
+
+// allocKeys are keys of the kinds boxed by the callers of Load.
+var allocKeys = []interface{}{1 << 20, "key", point{1, 2}, 1.5, uint64(1 << 40)}
+
+func allocMaps() map[string]*cmap.CMap {
+	return map[string]*cmap.CMap{
+		"default":  cmap.New(),
+		"swiss":    cmap.New(cmap.WithSwissBuckets()),
+		"meta":     cmap.New(cmap.WithEntryMeta()),
+		"keyStats": cmap.New(cmap.WithKeyStats()),
+	}
+}
+
+func TestLoadAllocs(t *testing.T) {
+	for name, m := range allocMaps() {
+		for i := 0; i < 1000; i++ {
+			m.Store(i, i)
+		}
+		for _, k := range allocKeys {
+			m.Store(k, k)
+		}
+		m.StoreWithIdleTTL("idle", 0, time.Hour)
+		// keys are boxed in the closures, as they would be by a caller
+		i, s, p, f, u := 1<<20, "key", point{1, 2}, 1.5, uint64(1<<40)
+		for j, load := range []func(){
+			func() { m.Load(i) },
+			func() { m.Load(s) },
+			func() { m.Load(p) },
+			func() { m.Load(f) },
+			func() { m.Load(u) },
+			func() { m.Load(i + 1) }, // miss
+			func() { m.Load("idle") },
+		} {
+			if allocs := testing.AllocsPerRun(100, load); allocs != 0 {
+				t.Errorf("%s: Load %d allocates %v times", name, j, allocs)
+			}
+		}
+		m.Close()
+	}
+}
+
+func BenchmarkLoadHit(b *testing.B) {
+	for name, m := range allocMaps() {
+		for i, k := range allocKeys {
+			m.Store(k, i)
+		}
+		for _, k := range allocKeys {
+			b.Run(fmt.Sprintf("%s/%T", name, k), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					m.Load(k)
+				}
+			})
+		}
+		m.Close()
+	}
+}
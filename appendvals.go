@@ -0,0 +1,55 @@
+package cmap
+
+import "reflect"
+
+var sliceType = reflect.TypeOf([]interface{}(nil))
+
+// Append appends elems to the []interface{} value of key, stored with the
+// bucket of key locked, and returns the new length of the value. A missing
+// key gets a new slice. Like Update, a ttl of the value is kept.
+//
+// The slices loaded from key must not be modified: Append writes past
+// their length, in place when their capacity allows it. Append panics
+// with a *TypeError if the value of key is not a []interface{}.
+func (m *CMap) Append(key interface{}, elems ...interface{}) int {
+	return m.appendValues(key, elems, false)
+}
+
+// AppendUnique is like Append, but only appends the elems not in the
+// value of key yet, once each.
+func (m *CMap) AppendUnique(key interface{}, elems ...interface{}) int {
+	return m.appendValues(key, elems, true)
+}
+
+func (m *CMap) appendValues(key interface{}, elems []interface{}, unique bool) int {
+	var n int
+	var err error
+	m.compute(key, func(value interface{}, loaded bool) (interface{}, action) {
+		vs, ok := value.([]interface{})
+		if loaded && !ok && value != nil {
+			// panics once the bucket is unlocked
+			err = &TypeError{Key: key, Value: value, Want: sliceType}
+			return nil, actKeep
+		}
+		for _, e := range elems {
+			if !unique || !containsValue(vs, e) {
+				vs = append(vs, e)
+			}
+		}
+		n = len(vs)
+		return vs, actStore
+	})
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+func containsValue(vs []interface{}, v interface{}) bool {
+	for _, x := range vs {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package cmap_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestAppend(t *testing.T) {
+	var m cmap.CMap
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				m.Append("events", g, i)
+			}
+		}(g)
+	}
+	wg.Wait()
+	v, _ := m.Load("events")
+	if n := len(v.([]interface{})); n != 1600 {
+		t.Fatalf("len(events) = %d after concurrent Appends, want 1600", n)
+	}
+
+	if n := m.AppendUnique("set", 1, 2, 1); n != 2 {
+		t.Fatalf("AppendUnique(set, 1, 2, 1) = %d, want 2", n)
+	}
+	if n := m.AppendUnique("set", 2, 3); n != 3 {
+		t.Fatalf("AppendUnique(set, 2, 3) = %d, want 3", n)
+	}
+
+	m.Store("int", 1)
+	defer func() {
+		var te *cmap.TypeError
+		if err, _ := recover().(error); !errors.As(err, &te) {
+			t.Fatalf("Append to an int panicked with %v, want a TypeError", err)
+		}
+		if v, _ := m.Load("int"); v != 1 {
+			t.Fatalf("Load(int) = %v after a failed Append, want 1", v)
+		}
+		m.Store("int", 3)
+	}()
+	m.Append("int", 2)
+}
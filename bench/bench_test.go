@@ -0,0 +1,192 @@
+package bench
+
+import (
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"gitee.com/absir_admin/cmap"
+	cm "github.com/orcaman/concurrent-map/v2"
+	"github.com/puzpuzpuz/xsync/v3"
+)
+
+// intMap is the method set the benchmarks use. The generic maps are used
+// with int keys, the others box them, as their users would.
+type intMap interface {
+	Load(key int) (value interface{}, ok bool)
+	Store(key int, value interface{})
+	Delete(key int)
+}
+
+type cmapMap struct{ m *cmap.CMap }
+
+func (m cmapMap) Load(key int) (interface{}, bool) { return m.m.Load(key) }
+func (m cmapMap) Store(key int, value interface{}) { m.m.Store(key, value) }
+func (m cmapMap) Delete(key int)                   { m.m.Delete(key) }
+
+type syncMap struct{ m *sync.Map }
+
+func (m syncMap) Load(key int) (interface{}, bool) { return m.m.Load(key) }
+func (m syncMap) Store(key int, value interface{}) { m.m.Store(key, value) }
+func (m syncMap) Delete(key int)                   { m.m.Delete(key) }
+
+type rwMutexMap struct {
+	mu sync.RWMutex
+	m  map[int]interface{}
+}
+
+func (m *rwMutexMap) Load(key int) (interface{}, bool) {
+	m.mu.RLock()
+	v, ok := m.m[key]
+	m.mu.RUnlock()
+	return v, ok
+}
+
+func (m *rwMutexMap) Store(key int, value interface{}) {
+	m.mu.Lock()
+	m.m[key] = value
+	m.mu.Unlock()
+}
+
+func (m *rwMutexMap) Delete(key int) {
+	m.mu.Lock()
+	delete(m.m, key)
+	m.mu.Unlock()
+}
+
+type orcamanMap struct {
+	m cm.ConcurrentMap[int, interface{}]
+}
+
+func (m orcamanMap) Load(key int) (interface{}, bool) { return m.m.Get(key) }
+func (m orcamanMap) Store(key int, value interface{}) { m.m.Set(key, value) }
+func (m orcamanMap) Delete(key int)                   { m.m.Remove(key) }
+
+type xsyncMap struct {
+	m *xsync.MapOf[int, interface{}]
+}
+
+func (m xsyncMap) Load(key int) (interface{}, bool) { return m.m.Load(key) }
+func (m xsyncMap) Store(key int, value interface{}) { m.m.Store(key, value) }
+func (m xsyncMap) Delete(key int)                   { m.m.Delete(key) }
+
+var impls = []struct {
+	name string
+	new  func() intMap
+}{
+	{"cmap", func() intMap { return cmapMap{cmap.New()} }},
+	{"sync.Map", func() intMap { return syncMap{new(sync.Map)} }},
+	{"RWMutex", func() intMap { return &rwMutexMap{m: make(map[int]interface{})} }},
+	{"orcaman", func() intMap {
+		return orcamanMap{cm.NewWithCustomShardingFunction[int, interface{}](func(key int) uint32 {
+			// fibonacci hashing, the shards are picked by the low bits
+			return uint32((uint64(key) * 0x9E3779B97F4A7C15) >> 32)
+		})}
+	}},
+	{"xsync", func() intMap { return xsyncMap{xsync.NewMapOf[int, interface{}]()} }},
+}
+
+// op is an operation of a workload.
+type op uint8
+
+const (
+	opLoad op = iota
+	opStore
+	opDelete
+)
+
+// workload is a mix of operations on keys in [0, keys), half of which
+// are stored before the benchmark starts.
+type workload struct {
+	loads, stores int // percent of the ops, the rest are deletes
+	keys          int
+	zipf          float64 // s of the zipf distribution of the keys, uniform if 0
+}
+
+// seqLen is the length of the op sequence of a goroutine, a power of 2.
+const seqLen = 1 << 16
+
+type step struct {
+	op  op
+	key int
+}
+
+// sequence returns the ops of goroutine g, drawn with a seed of g so that
+// every run does the same ops.
+func (w workload) sequence(g int64) []step {
+	rnd := rand.New(rand.NewSource(g))
+	var zipf *rand.Zipf
+	if w.zipf != 0 {
+		zipf = rand.NewZipf(rnd, w.zipf, 1, uint64(w.keys-1))
+	}
+	seq := make([]step, seqLen)
+	for i := range seq {
+		s := &seq[i]
+		if zipf != nil {
+			s.key = int(zipf.Uint64())
+		} else {
+			s.key = rnd.Intn(w.keys)
+		}
+		switch p := rnd.Intn(100); {
+		case p < w.loads:
+			s.op = opLoad
+		case p < w.loads+w.stores:
+			s.op = opStore
+		default:
+			s.op = opDelete
+		}
+	}
+	return seq
+}
+
+func (w workload) run(b *testing.B) {
+	for _, impl := range impls {
+		b.Run("impl="+impl.name, func(b *testing.B) {
+			m := impl.new()
+			for i := 0; i < w.keys; i += 2 {
+				m.Store(i, i)
+			}
+			seqs := make([][]step, runtime.GOMAXPROCS(0))
+			for g := range seqs {
+				seqs[g] = w.sequence(int64(g))
+			}
+			var g int64
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				seq := seqs[int(atomic.AddInt64(&g, 1)-1)%len(seqs)]
+				for i := 0; pb.Next(); i++ {
+					s := seq[i&(seqLen-1)]
+					switch s.op {
+					case opLoad:
+						m.Load(s.key)
+					case opStore:
+						m.Store(s.key, i)
+					default:
+						m.Delete(s.key)
+					}
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkReadHeavy(b *testing.B) {
+	workload{loads: 99, stores: 1, keys: 1 << 16}.run(b)
+}
+
+func BenchmarkWriteHeavy(b *testing.B) {
+	workload{loads: 10, stores: 60, keys: 1 << 16}.run(b)
+}
+
+func BenchmarkMixed(b *testing.B) {
+	workload{loads: 75, stores: 20, keys: 1 << 16}.run(b)
+}
+
+// BenchmarkSkewed hits a few hot keys most of the time, which contend on
+// the same shards.
+func BenchmarkSkewed(b *testing.B) {
+	workload{loads: 90, stores: 10, keys: 1 << 16, zipf: 1.1}.run(b)
+}
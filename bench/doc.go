@@ -0,0 +1,12 @@
+// Package bench compares cmap with sync.Map, a map guarded by a
+// sync.RWMutex, orcaman/concurrent-map and xsync.MapOf, on read-heavy,
+// write-heavy, mixed and skewed workloads.
+//
+// It is a module of its own, so that the maps compared with are not
+// dependencies of cmap. The benchmarks draw their operations and keys
+// from fixed seeds, and name the maps as impl=<name> sub-benchmarks,
+// which benchstat can compare:
+//
+//	go test -run - -bench . -count 10 > bench.txt
+//	benchstat -col /impl bench.txt
+package bench
@@ -0,0 +1,11 @@
+module gitee.com/absir_admin/cmap/bench
+
+go 1.18
+
+require (
+	gitee.com/absir_admin/cmap v0.0.0
+	github.com/orcaman/concurrent-map/v2 v2.0.1
+	github.com/puzpuzpuz/xsync/v3 v3.5.1
+)
+
+replace gitee.com/absir_admin/cmap => ../
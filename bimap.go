@@ -0,0 +1,122 @@
+package cmap
+
+import "errors"
+
+// errBiMapRetry makes a BiMap retry a change whose entries changed before
+// their buckets were locked.
+var errBiMapRetry = errors.New("cmap: bimap entries changed")
+
+// BiMap is a concurrent one-to-one map of keys to values, which can be
+// looked up by key or by value. Storing a pair removes the pairs holding
+// its key or its value, so that each key and each value belongs to one
+// pair at most. Values must be comparable, like keys.
+//
+// Both directions are kept in one CMap, and every change updates them
+// with DoAtomic, so a reader never sees a pair in one direction only.
+//
+// The zero BiMap is empty and ready for use. A BiMap must not be copied
+// after first use.
+type BiMap struct {
+	m CMap
+}
+
+// biKey is the key of a BiMap entry: the key of a pair mapped to its
+// value, or the value of a pair mapped to its key if inverse is set.
+type biKey struct {
+	k       interface{}
+	inverse bool
+}
+
+// Store sets the value of key, deleting the pairs holding key or value.
+func (bm *BiMap) Store(key, value interface{}) {
+	fwd, inv := biKey{key, false}, biKey{value, true}
+	for {
+		oldValue, hasValue := bm.m.Load(fwd)
+		oldKey, hasKey := bm.m.Load(inv)
+		keys := []interface{}{fwd, inv}
+		if hasValue {
+			keys = append(keys, biKey{oldValue, true})
+		}
+		if hasKey {
+			keys = append(keys, biKey{oldKey, false})
+		}
+		err := bm.m.DoAtomic(keys, func(tx TxView) error {
+			if v, ok := tx.Get(fwd); ok != hasValue || v != oldValue {
+				return errBiMapRetry
+			}
+			if k, ok := tx.Get(inv); ok != hasKey || k != oldKey {
+				return errBiMapRetry
+			}
+			if hasValue {
+				tx.Delete(biKey{oldValue, true})
+			}
+			if hasKey {
+				tx.Delete(biKey{oldKey, false})
+			}
+			tx.Set(fwd, value)
+			tx.Set(inv, key)
+			return nil
+		})
+		if err == nil {
+			return
+		}
+	}
+}
+
+// Load returns the value of key.
+func (bm *BiMap) Load(key interface{}) (value interface{}, ok bool) {
+	return bm.m.Load(biKey{key, false})
+}
+
+// LoadByValue returns the key of value.
+func (bm *BiMap) LoadByValue(value interface{}) (key interface{}, ok bool) {
+	return bm.m.Load(biKey{value, true})
+}
+
+// Delete deletes the pair holding key, and returns its value.
+func (bm *BiMap) Delete(key interface{}) (value interface{}, loaded bool) {
+	return bm.delete(biKey{key, false})
+}
+
+// DeleteByValue deletes the pair holding value, and returns its key.
+func (bm *BiMap) DeleteByValue(value interface{}) (key interface{}, loaded bool) {
+	return bm.delete(biKey{value, true})
+}
+
+// delete deletes the pair of k, and returns the other side of the pair.
+func (bm *BiMap) delete(k biKey) (other interface{}, loaded bool) {
+	for {
+		other, loaded = bm.m.Load(k)
+		if !loaded {
+			return nil, false
+		}
+		mirror := biKey{other, !k.inverse}
+		err := bm.m.DoAtomic([]interface{}{k, mirror}, func(tx TxView) error {
+			if v, ok := tx.Get(k); !ok || v != other {
+				return errBiMapRetry
+			}
+			tx.Delete(k)
+			tx.Delete(mirror)
+			return nil
+		})
+		if err == nil {
+			return other, true
+		}
+	}
+}
+
+// Len returns the number of pairs.
+func (bm *BiMap) Len() int {
+	return bm.m.Len() / 2
+}
+
+// Range calls f sequentially for each key and value present in the map.
+// If f returns false, range stops the iteration.
+func (bm *BiMap) Range(f func(key, value interface{}) bool) bool {
+	return bm.m.Range(func(k, v interface{}) bool {
+		if bk := k.(biKey); !bk.inverse {
+			return f(bk.k, v)
+		}
+		return true
+	})
+}
@@ -0,0 +1,73 @@
+package cmap_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestBiMap(t *testing.T) {
+	var bm cmap.BiMap
+	bm.Store(1, "one")
+	bm.Store(2, "two")
+	if k, ok := bm.LoadByValue("two"); !ok || k != 2 {
+		t.Fatalf("LoadByValue(two) = %v, %v; want 2, true", k, ok)
+	}
+
+	// a new value for 1 frees "one"
+	bm.Store(1, "uno")
+	if _, ok := bm.LoadByValue("one"); ok {
+		t.Fatalf("LoadByValue(one) found the old value of 1")
+	}
+	// "two" for 3 deletes the pair of 2
+	bm.Store(3, "two")
+	if _, ok := bm.Load(2); ok {
+		t.Fatalf("Load(2) found a key whose value was taken")
+	}
+	if n := bm.Len(); n != 2 {
+		t.Fatalf("Len() = %d, want 2", n)
+	}
+
+	if k, ok := bm.DeleteByValue("uno"); !ok || k != 1 {
+		t.Fatalf("DeleteByValue(uno) = %v, %v; want 1, true", k, ok)
+	}
+	if v, ok := bm.Delete(3); !ok || v != "two" {
+		t.Fatalf("Delete(3) = %v, %v; want two, true", v, ok)
+	}
+	if bm.Len() != 0 {
+		t.Fatalf("Len() = %d after deleting every pair", bm.Len())
+	}
+}
+
+func TestBiMapConcurrent(t *testing.T) {
+	var bm cmap.BiMap
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				k, v := (g+i)%16, (g*i)%16
+				if i%5 == 0 {
+					bm.DeleteByValue(v)
+				} else {
+					bm.Store(k, v)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	n := 0
+	bm.Range(func(key, value interface{}) bool {
+		n++
+		if k, ok := bm.LoadByValue(value); !ok || k != key {
+			t.Errorf("LoadByValue(%v) = %v, %v; want %v", value, k, ok, key)
+		}
+		return true
+	})
+	if n != bm.Len() {
+		t.Fatalf("Range saw %d pairs, Len() = %d", n, bm.Len())
+	}
+}
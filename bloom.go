@@ -0,0 +1,104 @@
+package cmap
+
+import "sync/atomic"
+
+// bloomHashes is the number of counters of a key in a bloom.
+const bloomHashes = 3
+
+// bloom is a counting Bloom filter of hashes: it may report a hash never
+// added, but never misses one added and not removed since. Its counters
+// are atomic, so it can be read while it is written.
+type bloom struct {
+	counters []uint32
+	mask     uintptr
+}
+
+// newBloom returns a bloom of n counters, rounded up to a power of 2.
+func newBloom(n int) *bloom {
+	size := 1
+	for size < n {
+		size <<= 1
+	}
+	return &bloom{counters: make([]uint32, size), mask: uintptr(size - 1)}
+}
+
+// index returns the i-th counter of x, hash remixed by bloomMix, by
+// double hashing.
+func (f *bloom) index(x uint64, i int) uintptr {
+	return uintptr(x+uint64(i)*(x>>32|1)) & f.mask
+}
+
+// bloomMix remixes hash, whose low bits may be the same for many keys,
+// like those of a partition, see WithPartitioner.
+func bloomMix(hash uintptr) uint64 {
+	return scramble(uint64(hash))
+}
+
+func (f *bloom) add(hash uintptr) {
+	x := bloomMix(hash)
+	for i := 0; i < bloomHashes; i++ {
+		atomic.AddUint32(&f.counters[f.index(x, i)], 1)
+	}
+}
+
+// remove removes a hash added before.
+func (f *bloom) remove(hash uintptr) {
+	x := bloomMix(hash)
+	for i := 0; i < bloomHashes; i++ {
+		atomic.AddUint32(&f.counters[f.index(x, i)], ^uint32(0))
+	}
+}
+
+// mayContain reports false if hash is not in f.
+func (f *bloom) mayContain(hash uintptr) bool {
+	x := bloomMix(hash)
+	for i := 0; i < bloomHashes; i++ {
+		if atomic.LoadUint32(&f.counters[f.index(x, i)]) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bloomCounters is the number of counters of a WithBloomFilter filter per
+// key, for about 3% of false positives.
+const bloomCounters = 8
+
+// WithBloomFilter makes the map keep a counting Bloom filter of the hashes
+// of its keys, sized for n keys, so that Load and TryLoad of most absent
+// keys return without looking the key up. It costs 32 bytes per key of n,
+// and an update of the filter on each insert and delete: it pays off when
+// most loads miss, like dedup checks. Past n keys, the filter lets more
+// and more absent keys through, but never hides a present key.
+//
+// The filter is shared by the buckets, indexed by the hash of the keys,
+// so that resizes leave it unchanged.
+func WithBloomFilter(n int) Option {
+	return func(m *CMap) {
+		if n > 0 {
+			m.keyFilter = newBloom(n * bloomCounters)
+		}
+	}
+}
+
+// addKey is called before a new key of hash is stored. It takes the slot
+// of the key, see WithMaxEntries, adds it to the filter, and reports
+// whether the map has room for it.
+func (m *CMap) addKey(hash uintptr) bool {
+	if !m.admit(1) {
+		return false
+	}
+	if m.keyFilter != nil {
+		m.keyFilter.add(hash)
+	}
+	return true
+}
+
+// dropKey undoes addKey once the key is deleted, or was not stored after
+// all.
+func (m *CMap) dropKey(hash uintptr) {
+	m.release(1)
+	if m.keyFilter != nil {
+		m.keyFilter.remove(hash)
+	}
+}
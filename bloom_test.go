@@ -0,0 +1,86 @@
+package cmap_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/min1324/cmap"
+)
+
+func TestBloomFilter(t *testing.T) {
+	for _, swiss := range []bool{false, true} {
+		opts := []cmap.Option{cmap.WithBloomFilter(100)}
+		if swiss {
+			opts = append(opts, cmap.WithSwissBuckets())
+		}
+		m := cmap.New(opts...)
+		m.Store("a", 1)
+		m.LoadOrStore("b", 2)
+		m.Update("c", func(interface{}, bool) (interface{}, bool) { return 3, false })
+		m.DoAtomic([]interface{}{"d"}, func(tx cmap.TxView) error {
+			tx.Set("d", 4)
+			return nil
+		})
+		m.StoreWithTTL("e", 5, time.Millisecond)
+		for i, k := range []string{"a", "b", "c", "d", "e"} {
+			if v, ok := m.Load(k); !ok || v != i+1 {
+				t.Fatalf("swiss=%v: Load(%s) = %v, %v; want %d, true", swiss, k, v, ok, i+1)
+			}
+		}
+		m.Delete("a")
+		m.Update("c", func(interface{}, bool) (interface{}, bool) { return nil, true })
+		time.Sleep(2 * time.Millisecond)
+		m.DeleteExpired()
+		for _, k := range []string{"a", "c", "e", "missing"} {
+			if _, ok := m.Load(k); ok {
+				t.Fatalf("swiss=%v: Load(%s) found a deleted key", swiss, k)
+			}
+		}
+		m.Store("a", 6)
+		if v, ok := m.Load("a"); !ok || v != 6 {
+			t.Fatalf("swiss=%v: Load(a) = %v, %v after storing it again", swiss, v, ok)
+		}
+	}
+}
+
+// TestBloomFilterConcurrent stores and deletes the same keys concurrently,
+// through resizes, and checks that the filter never hides a key.
+func TestBloomFilterConcurrent(t *testing.T) {
+	const keys = 1000
+	m := cmap.New(cmap.WithBloomFilter(keys), cmap.WithLoadFactor(0.1, 0.05))
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 20*keys; i++ {
+				k := (i * 7) % keys
+				if (i+g)%3 == 0 {
+					m.Delete(k)
+				} else {
+					m.Store(k, i)
+				}
+				// a key of g only, which the others resize
+				own := keys + g
+				m.Store(own, i)
+				if v, ok := m.Load(own); !ok || v != i {
+					t.Errorf("Load(%d) = %v, %v after storing %d", own, v, ok, i)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	n := 0
+	m.Range(func(key, _ interface{}) bool {
+		n++
+		if _, ok := m.Load(key); !ok {
+			t.Fatalf("Load(%v) missed a present key", key)
+		}
+		return true
+	})
+	if n != m.Len() {
+		t.Fatalf("Range saw %d keys, Len() = %d", n, m.Len())
+	}
+}
@@ -0,0 +1,54 @@
+package cmap
+
+import "unsafe"
+
+// b2s returns a string sharing the memory of b, which must not be
+// modified while the string is in use, nor retained by the map.
+func b2s(b []byte) string {
+	return *(*string)(unsafe.Pointer(&b))
+}
+
+// LoadBytes is like Load with string(key), without copying key.
+func (m *StringMap) LoadBytes(key []byte) (value interface{}, ok bool) {
+	return m.Load(b2s(key))
+}
+
+// StoreBytes is like Store with string(key). The key is only copied if it
+// is new, since the map keeps it.
+func (m *StringMap) StoreBytes(key []byte, value interface{}) {
+	s := m.getShard(b2s(key))
+	s.mu.Lock()
+	if e, ok := s.m[b2s(key)]; ok {
+		// set through the entry, s.m[b2s(key)] = e would keep b2s(key)
+		e.value = value
+	} else {
+		s.set(string(key), value)
+	}
+	s.mu.Unlock()
+}
+
+// LoadOrStoreBytes is like LoadOrStore with string(key). The key is only
+// copied if the value is stored.
+func (m *StringMap) LoadOrStoreBytes(key []byte, value interface{}) (actual interface{}, loaded bool) {
+	s := m.getShard(b2s(key))
+	s.mu.Lock()
+	if e, ok := s.m[b2s(key)]; ok {
+		actual, loaded = e.value, true
+	} else {
+		s.set(string(key), value)
+		actual = value
+	}
+	s.mu.Unlock()
+	return actual, loaded
+}
+
+// LoadAndDeleteBytes is like LoadAndDelete with string(key), without
+// copying key.
+func (m *StringMap) LoadAndDeleteBytes(key []byte) (value interface{}, loaded bool) {
+	return m.LoadAndDelete(b2s(key))
+}
+
+// DeleteBytes is like Delete with string(key), without copying key.
+func (m *StringMap) DeleteBytes(key []byte) {
+	m.LoadAndDelete(b2s(key))
+}
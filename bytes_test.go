@@ -0,0 +1,62 @@
+package cmap_test
+
+import (
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestStringMapBytes(t *testing.T) {
+	var m cmap.StringMap
+	key := []byte("key")
+	m.StoreBytes(key, 1)
+	if v, loaded := m.LoadOrStoreBytes(key, 2); !loaded || v != 1 {
+		t.Fatalf("LoadOrStoreBytes = %v, %v", v, loaded)
+	}
+	// the map must not retain the caller's bytes
+	m.StoreBytes(key, 4) // over "key", kept by the map
+	key[0] = 'K'
+	if v, ok := m.Load("key"); !ok || v != 4 {
+		t.Fatalf(`Load("key") = %v, %v`, v, ok)
+	}
+	if _, ok := m.LoadBytes(key); ok {
+		t.Fatalf("LoadBytes(%q) found a value", key)
+	}
+	if v, loaded := m.LoadOrStoreBytes(key, 2); loaded || v != 2 {
+		t.Fatalf("LoadOrStoreBytes = %v, %v", v, loaded)
+	}
+	key[0] = 'k'
+	if v, ok := m.Load("Key"); !ok || v != 2 {
+		t.Fatalf(`Load("Key") = %v, %v`, v, ok)
+	}
+	m.DeleteBytes(key)
+	if v, ok := m.LoadBytes(key); ok {
+		t.Fatalf("LoadBytes after DeleteBytes = %v", v)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		m.LoadBytes([]byte("Key"))
+	})
+	if allocs != 0 {
+		t.Fatalf("LoadBytes allocates %v times", allocs)
+	}
+
+	m.StoreBytes(key, 3)
+	allocs = testing.AllocsPerRun(100, func() {
+		m.StoreBytes([]byte("Key"), nil)
+	})
+	if allocs != 0 {
+		t.Fatalf("StoreBytes over a key allocates %v times", allocs)
+	}
+	m.StoreBytes(key, 4) // over "key", kept by the map
+	key[0] = 'K'
+	if v, ok := m.Load("key"); !ok || v != 4 {
+		t.Fatalf(`Load("key") = %v, %v after StoreBytes`, v, ok)
+	}
+	if v, ok := m.Load("Key"); !ok || v != nil {
+		t.Fatalf(`Load("Key") = %v, %v after StoreBytes`, v, ok)
+	}
+	if n := m.Len(); n != 2 {
+		t.Fatalf("Len() = %d, want 2", n)
+	}
+}
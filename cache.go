@@ -0,0 +1,229 @@
+package cmap
+
+import (
+	"container/list"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// Cache is a concurrent LRU cache holding at most a fixed number of
+// entries, or of total weight, see WithMaxWeight.
+//
+// Like CMap the keys are spread over buckets by hash, each bucket keeps
+// its own recency list under its own lock, so the least recently used
+// entry is chosen per bucket rather than across the whole cache.
+type Cache struct {
+	max   int64
+	count int64 // number of element
+	mask  uintptr
+	data  []cacheBucket
+
+	maxWeight int64 // 0 without WithMaxWeight
+	weight    int64 // total weight of the entries
+	weigher   func(key, value interface{}) int64
+
+	onEvict  func(key, value interface{})
+	onDelete func(key, value interface{})
+}
+
+type cacheBucket struct {
+	mu    sync.Mutex
+	ll    *list.List // front is the most recently used
+	items map[interface{}]*list.Element
+}
+
+type cacheEntry struct {
+	key, value interface{}
+	weight     int64
+}
+
+// CacheOption configures a Cache created by NewCache.
+type CacheOption func(*Cache)
+
+// WithOnEvict sets a function called with every entry evicted to keep
+// the cache under its bound. It is called without holding any lock.
+func WithOnEvict(f func(key, value interface{})) CacheOption {
+	return func(c *Cache) {
+		c.onEvict = f
+	}
+}
+
+// WithOnDelete sets a function called with every entry deleted by Delete.
+// It is called without holding any lock.
+func WithOnDelete(f func(key, value interface{})) CacheOption {
+	return func(c *Cache) {
+		c.onDelete = f
+	}
+}
+
+// WithMaxWeight bounds the total weight of the entries of the cache to n,
+// as given by weigher for each entry, like its size in bytes. The least
+// recently used entries are evicted until the cache is under both bounds.
+// An entry heavier than n on its own is still cached, until evicted.
+//
+// weigher is called when an entry is set, without holding any lock, and
+// must return the same weight for the same entry.
+func WithMaxWeight(n int64, weigher func(key, value interface{}) int64) CacheOption {
+	return func(c *Cache) {
+		if n > 0 && weigher != nil {
+			c.maxWeight, c.weigher = n, weigher
+		}
+	}
+}
+
+// NewCache returns an empty Cache which holds up to maxEntries entries.
+// With WithMaxWeight, maxEntries may be zero to bound the weight only.
+func NewCache(maxEntries int, opts ...CacheOption) *Cache {
+	c := &Cache{
+		max:  int64(maxEntries),
+		mask: bucketMask(mInitBit),
+		data: make([]cacheBucket, bucketShift(mInitBit)),
+	}
+	for i := range c.data {
+		c.data[i].ll = list.New()
+		c.data[i].items = make(map[interface{}]*list.Element)
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if maxEntries <= 0 {
+		if c.maxWeight == 0 {
+			panic("cmap: NewCache maxEntries must be positive")
+		}
+		c.max = math.MaxInt64
+	}
+	return c
+}
+
+func (c *Cache) getBucket(hash uintptr) *cacheBucket {
+	return &c.data[hash&c.mask]
+}
+
+// Get returns the value stored in the cache for a key and marks it as
+// the most recently used.
+// The ok result indicates whether value was found in the cache.
+func (c *Cache) Get(key interface{}) (value interface{}, ok bool) {
+	b := c.getBucket(chash(key, procSeed))
+	b.mu.Lock()
+	e, ok := b.items[key]
+	if ok {
+		b.ll.MoveToFront(e)
+		value = e.Value.(*cacheEntry).value
+	}
+	b.mu.Unlock()
+	return value, ok
+}
+
+// Peek returns the value stored in the cache for a key without updating
+// its recency.
+func (c *Cache) Peek(key interface{}) (value interface{}, ok bool) {
+	b := c.getBucket(chash(key, procSeed))
+	b.mu.Lock()
+	e, ok := b.items[key]
+	if ok {
+		value = e.Value.(*cacheEntry).value
+	}
+	b.mu.Unlock()
+	return value, ok
+}
+
+// Set sets the value for a key and marks it as the most recently used,
+// evicting the least recently used entries if the cache is full.
+func (c *Cache) Set(key, value interface{}) {
+	var weight int64
+	if c.weigher != nil {
+		weight = c.weigher(key, value)
+	}
+	hash := chash(key, procSeed)
+	b := c.getBucket(hash)
+	b.mu.Lock()
+	if e, ok := b.items[key]; ok {
+		ent := e.Value.(*cacheEntry)
+		ent.value = value
+		weight, ent.weight = weight-ent.weight, weight
+		b.ll.MoveToFront(e)
+		b.mu.Unlock()
+		atomic.AddInt64(&c.weight, weight)
+		if weight > 0 {
+			c.evict(hash)
+		}
+		return
+	}
+	b.items[key] = b.ll.PushFront(&cacheEntry{key: key, value: value, weight: weight})
+	b.mu.Unlock()
+
+	atomic.AddInt64(&c.weight, weight)
+	atomic.AddInt64(&c.count, 1)
+	c.evict(hash)
+}
+
+// Delete deletes the value for a key.
+func (c *Cache) Delete(key interface{}) {
+	b := c.getBucket(chash(key, procSeed))
+	b.mu.Lock()
+	e, ok := b.items[key]
+	var ent *cacheEntry
+	if ok {
+		ent = c.removeLocked(b, e)
+	}
+	b.mu.Unlock()
+	if ok && c.onDelete != nil {
+		c.onDelete(ent.key, ent.value)
+	}
+}
+
+// Len returns the number of entries in the cache.
+func (c *Cache) Len() int {
+	return int(atomic.LoadInt64(&c.count))
+}
+
+// Weight returns the total weight of the entries in the cache, always
+// zero without WithMaxWeight.
+func (c *Cache) Weight() int64 {
+	return atomic.LoadInt64(&c.weight)
+}
+
+// over reports whether the cache is over one of its bounds.
+func (c *Cache) over() bool {
+	return atomic.LoadInt64(&c.count) > c.max ||
+		c.maxWeight > 0 && atomic.LoadInt64(&c.weight) > c.maxWeight
+}
+
+// evict removes least recently used entries until the cache is within
+// its bounds, starting with the bucket of hash.
+func (c *Cache) evict(hash uintptr) {
+	for c.over() && c.evictOne(hash) {
+	}
+}
+
+// evictOne removes the least recently used entry of the first bucket
+// holding one from the bucket of hash, but the newest entry of that
+// bucket. It reports whether an entry was removed.
+func (c *Cache) evictOne(hash uintptr) bool {
+	for i := uintptr(0); i <= c.mask && c.over(); i++ {
+		b := c.getBucket(hash + i)
+		b.mu.Lock()
+		var ent *cacheEntry
+		// keep the newest entry of its own bucket
+		if e := b.ll.Back(); e != nil && (i > 0 || b.ll.Len() > 1) {
+			ent = c.removeLocked(b, e)
+		}
+		b.mu.Unlock()
+		if ent != nil {
+			if c.onEvict != nil {
+				c.onEvict(ent.key, ent.value)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Cache) removeLocked(b *cacheBucket, e *list.Element) *cacheEntry {
+	ent := b.ll.Remove(e).(*cacheEntry)
+	delete(b.items, ent.key)
+	atomic.AddInt64(&c.count, -1)
+	atomic.AddInt64(&c.weight, -ent.weight)
+	return ent
+}
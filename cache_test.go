@@ -0,0 +1,91 @@
+package cmap_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestCacheBound(t *testing.T) {
+	const max = 100
+	var evicted sync.Map
+	c := cmap.NewCache(max, cmap.WithOnEvict(func(key, value interface{}) {
+		if key != value {
+			t.Errorf("evicted %v with value %v", key, value)
+		}
+		evicted.Store(key, value)
+	}))
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := g * 1000; i < (g+1)*1000; i++ {
+				c.Set(i, i)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if c.Len() != max {
+		t.Fatalf("Len() = %d, want %d", c.Len(), max)
+	}
+	for i := 0; i < 4000; i++ {
+		_, cached := c.Peek(i)
+		_, gone := evicted.Load(i)
+		if cached == gone {
+			t.Fatalf("key %d: cached %v, evicted %v", i, cached, gone)
+		}
+	}
+}
+
+func TestCacheRecency(t *testing.T) {
+	c := cmap.NewCache(1)
+	c.Set("a", 1)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("Get(a) = %v, %v; want 1, true", v, ok)
+	}
+	c.Set("a", 2)
+	if v, ok := c.Peek("a"); !ok || v != 2 {
+		t.Fatalf("Peek(a) = %v, %v; want 2, true", v, ok)
+	}
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok || c.Len() != 0 {
+		t.Fatalf("Delete(a) left the entry in the cache")
+	}
+}
+
+func TestCacheMaxWeight(t *testing.T) {
+	size := func(_, value interface{}) int64 { return int64(len(value.([]byte))) }
+	c := cmap.NewCache(0, cmap.WithMaxWeight(1000, size))
+	for i := 0; i < 100; i++ {
+		c.Set(i, make([]byte, 10+i%50))
+		if w := c.Weight(); w > 1000 {
+			t.Fatalf("Weight() = %d after %d sets, over the bound", w, i+1)
+		}
+	}
+	sum := int64(0)
+	for i := 0; i < 100; i++ {
+		if v, ok := c.Peek(i); ok {
+			sum += int64(len(v.([]byte)))
+		}
+	}
+	if sum != c.Weight() {
+		t.Fatalf("cached entries weigh %d, Weight() = %d", sum, c.Weight())
+	}
+
+	// growing an entry evicts others
+	c.Set(99, make([]byte, 900))
+	if w := c.Weight(); w > 1000 {
+		t.Fatalf("Weight() = %d after growing an entry", w)
+	}
+	if _, ok := c.Peek(99); !ok {
+		t.Fatalf("grown entry evicted")
+	}
+	c.Delete(99)
+	if w, n := c.Weight(), c.Len(); n == 0 && w != 0 {
+		t.Fatalf("Weight() = %d with no entry", w)
+	}
+}
@@ -0,0 +1,118 @@
+package cmap
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// callback is called with an entry removed from a map.
+type callback func(key, value interface{})
+
+func (f callback) call(key, value interface{}) {
+	if f != nil {
+		f(key, value)
+	}
+}
+
+func loadCallback(v *atomic.Value) callback {
+	f, _ := v.Load().(callback)
+	return f
+}
+
+// OnDelete sets a function called with every entry deleted by Delete or
+// LoadAndDelete. It replaces the previous one, nil removes it.
+//
+// f is called after the entry has left the map and without holding any
+// lock, so it may safely use the map or release resources held by value.
+func (m *CMap) OnDelete(f func(key, value interface{})) {
+	m.onDelete.Store(callback(f))
+}
+
+// OnEvict sets a function called with every entry removed because it
+// expired, see StoreWithTTL. It replaces the previous one, nil removes it.
+//
+// f is called after the entry has left the map and without holding any
+// lock, so it may safely use the map or release resources held by value.
+func (m *CMap) OnEvict(f func(key, value interface{})) {
+	m.onEvict.Store(callback(f))
+}
+
+// OnResize sets a function called when the map starts resizing from 1<<oldB
+// to 1<<newB buckets, holding count elements. It replaces the previous
+// one, nil removes it.
+//
+// f is called by the goroutine whose write started the resize, without
+// holding any lock, before the buckets are evacuated.
+func (m *CMap) OnResize(f func(oldB, newB uint8, count int64)) {
+	m.onResize.Store(resizeCallback(f))
+}
+
+// SetLogger sets a function called with the internal events of the map,
+// like the start and the end of a resize, as a message and alternating
+// keys and values, as taken by the methods of log/slog.Logger:
+//
+//	m.SetLogger(slog.Default().Info)
+//
+// It replaces the previous one, nil removes it. f is called without
+// holding any lock.
+func (m *CMap) SetLogger(f func(msg string, args ...interface{})) {
+	m.logger.Store(logger(f))
+}
+
+type resizeCallback func(oldB, newB uint8, count int64)
+
+type logger func(msg string, args ...interface{})
+
+func (m *CMap) log(msg string, args ...interface{}) {
+	if f, _ := m.logger.Load().(logger); f != nil {
+		f(msg, args...)
+	}
+}
+
+// resizing is called once a resize from node o to node n started.
+func (m *CMap) resizing(o, n *node) {
+	onResize, _ := m.onResize.Load().(resizeCallback)
+	log, _ := m.logger.Load().(logger)
+	if onResize == nil && log == nil {
+		return
+	}
+	count := o.count()
+	if onResize != nil {
+		onResize(o.B, n.B, count)
+	}
+	if log != nil {
+		log("cmap: resize started", "oldB", o.B, "newB", n.B, "count", count)
+	}
+}
+
+// resized returns the done func of a new node, which logs the end of its
+// resize, or nil without logger.
+func (m *CMap) resized() func(n *node) {
+	log, _ := m.logger.Load().(logger)
+	if log == nil {
+		return nil
+	}
+	return func(n *node) {
+		log("cmap: resize done", "B", n.B, "elapsed", time.Duration(nanotime()-n.started))
+	}
+}
+
+func (m *CMap) deleted(key, value interface{}) {
+	m.weigh(key, value, -1)
+	loadCallback(&m.onDelete).call(key, value)
+	m.notify(EventDelete, key, value)
+	m.sendChanges()
+	if m.persister != nil {
+		m.persister.send(persistOp{del: true, key: key})
+	}
+}
+
+func (m *CMap) evicted(key, value interface{}) {
+	m.weigh(key, value, -1)
+	loadCallback(&m.onEvict).call(key, value)
+	m.notify(EventDelete, key, value)
+	m.sendChanges()
+	if m.persister != nil {
+		m.persister.send(persistOp{del: true, key: key})
+	}
+}
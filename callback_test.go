@@ -0,0 +1,128 @@
+package cmap_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/min1324/cmap"
+)
+
+func TestOnDeleteAndOnEvict(t *testing.T) {
+	var m cmap.CMap
+	deleted := make(map[interface{}]interface{})
+	evicted := make(map[interface{}]interface{})
+	m.OnDelete(func(key, value interface{}) { deleted[key] = value })
+	m.OnEvict(func(key, value interface{}) {
+		// the map must be usable from the callback
+		m.Load(key)
+		evicted[key] = value
+	})
+
+	m.Store("a", 1)
+	m.StoreWithTTL("b", 2, time.Millisecond)
+	m.StoreWithTTL("c", 3, time.Millisecond)
+	m.Delete("a")
+	m.Delete("missing")
+	time.Sleep(5 * time.Millisecond)
+	m.DeleteExpired()
+
+	if len(deleted) != 1 || deleted["a"] != 1 {
+		t.Fatalf("OnDelete saw %v, want map[a:1]", deleted)
+	}
+	if len(evicted) != 2 || evicted["b"] != 2 || evicted["c"] != 3 {
+		t.Fatalf("OnEvict saw %v, want map[b:2 c:3]", evicted)
+	}
+
+	m.OnDelete(nil)
+	m.Store("a", 1)
+	m.Delete("a")
+	if len(deleted) != 1 {
+		t.Fatalf("OnDelete(nil) did not remove the callback")
+	}
+}
+
+func TestOnResize(t *testing.T) {
+	var m cmap.CMap
+	type resize struct {
+		oldB, newB uint8
+		count      int64
+	}
+	var resizes []resize
+	m.OnResize(func(oldB, newB uint8, count int64) {
+		resizes = append(resizes, resize{oldB, newB, count})
+	})
+	var msgs []string
+	m.SetLogger(func(msg string, args ...interface{}) {
+		if len(args)%2 != 0 {
+			t.Errorf("%s logged with %d args", msg, len(args))
+		}
+		msgs = append(msgs, msg)
+	})
+
+	for i := 0; i < 100; i++ {
+		m.Store(i, i)
+	}
+	m.ForceResize(6)
+	m.WaitResize(context.Background())
+	if len(resizes) != 1 || resizes[0] != (resize{4, 6, 100}) {
+		t.Fatalf("OnResize called with %v, want one resize from 4 to 6 with 100 elements", resizes)
+	}
+	if len(msgs) != 2 || msgs[0] != "cmap: resize started" || msgs[1] != "cmap: resize done" {
+		t.Fatalf("logged %q, want the start and the end of the resize", msgs)
+	}
+
+	m.OnResize(nil)
+	m.ForceResize(5)
+	if len(resizes) != 1 {
+		t.Fatalf("OnResize(nil) kept the callback")
+	}
+}
+
+func TestCallbackPanics(t *testing.T) {
+	var m cmap.CMap
+	for i := 0; i < 100; i++ {
+		m.Store(i, i)
+	}
+	mustPanic := func(name string, f func()) {
+		t.Helper()
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("%s: recovered %v, want boom", name, r)
+			}
+		}()
+		f()
+	}
+	mustPanic("Update", func() {
+		m.Update(1, func(interface{}, bool) (interface{}, bool) { panic("boom") })
+	})
+	mustPanic("CloneFunc", func() {
+		m.CloneFunc(func(interface{}) interface{} { panic("boom") })
+	})
+	mustPanic("RangeParallel", func() {
+		m.RangeParallel(4, func(key, value interface{}) { panic("boom") })
+	})
+	mustPanic("Filter", func() {
+		m.Filter(func(key, value interface{}) bool { panic("boom") })
+	})
+	mustPanic("DoAtomic", func() {
+		m.DoAtomic([]interface{}{1, 2}, func(cmap.TxView) error { panic("boom") })
+	})
+
+	// every bucket must be writable again, and the map unchanged
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			m.Store(i, i+1)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a bucket is still locked after a panic")
+	}
+	if n := m.Len(); n != 100 {
+		t.Fatalf("Len() = %d, want 100", n)
+	}
+}
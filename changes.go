@@ -0,0 +1,327 @@
+package cmap
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ChangeOp is the kind of change reported by a Change.
+type ChangeOp uint8
+
+const (
+	// ChangeInsert reports a value stored for a key absent.
+	ChangeInsert ChangeOp = iota + 1
+	// ChangeUpdate reports a value stored over the value of a key.
+	ChangeUpdate
+	// ChangeDelete reports a key deleted.
+	ChangeDelete
+	// ChangeExpire reports a key removed because it expired.
+	ChangeExpire
+)
+
+func (op ChangeOp) String() string {
+	switch op {
+	case ChangeInsert:
+		return "Insert"
+	case ChangeUpdate:
+		return "Update"
+	case ChangeDelete:
+		return "Delete"
+	case ChangeExpire:
+		return "Expire"
+	default:
+		return "ChangeOp(?)"
+	}
+}
+
+// Change is a change of a CMap delivered by Changes.
+type Change struct {
+	Seq  uint64 // number of the change in its channel, from 1
+	Op   ChangeOp
+	Key  interface{}
+	Old  interface{} // the value replaced or removed, nil for ChangeInsert
+	New  interface{} // the value stored, nil for ChangeDelete and ChangeExpire
+	Time time.Time   // when the change was recorded
+}
+
+// ChangePolicy is what a channel of Changes does when its receiver falls
+// a buffer behind, see WithChangePolicy.
+type ChangePolicy uint8
+
+const (
+	// ChangesBlock makes the writers wait for the receiver, the default,
+	// so that no change is lost.
+	ChangesBlock ChangePolicy = iota
+	// ChangesDropOldest drops the oldest change buffered to make room for
+	// the new one, so that writers never wait. The receiver finds the
+	// changes dropped from the gaps in Seq.
+	ChangesDropOldest
+)
+
+// WithChangePolicy sets the policy of the channels of Changes for a
+// receiver which falls behind.
+func WithChangePolicy(policy ChangePolicy) Option {
+	return func(m *CMap) {
+		m.changePolicy = policy
+	}
+}
+
+// Changes returns a channel receiving the changes of every key, with the
+// values they replaced, for the indexes, replicas or audit logs kept in
+// sync with the map, and a cancel func which stops the delivery and
+// closes the channel. The channel buffers up to buffer changes, at least
+// one, and is closed as well by Close.
+//
+// The changes are recorded while the bucket of their key is locked, and
+// sent in the order they were recorded, numbered by Seq: the changes of a
+// bucket, and so of a key, are received in the order they were made, and
+// applying them in order to a copy of the map taken after the call to
+// Changes converges to the map. To record them in order, the writers lock
+// their bucket exclusively while a channel of Changes is open, instead of
+// sharing the lock.
+//
+// Unlike Subscribe, the writers wait while the receiver is a buffer
+// behind, unless WithChangePolicy says otherwise.
+func (m *CMap) Changes(buffer int) (<-chan Change, func()) {
+	if buffer < 1 {
+		buffer = 1
+	}
+	return m.getChangeHub().add(m, buffer, m.changePolicy)
+}
+
+func (m *CMap) getChangeHub() *changeHub {
+	if h, _ := m.changes.Load().(*changeHub); h != nil {
+		return h
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, _ := m.changes.Load().(*changeHub)
+	if h == nil {
+		h = &changeHub{closed: atomic.LoadUint32(&m.closed) != 0, wal: m.wal}
+		m.changes.Store(h)
+	}
+	return h
+}
+
+// capturing reports whether the changes of m are recorded, for a channel
+// of Changes or the WAL. It is checked with the bucket changed locked.
+func (m *CMap) capturing() bool {
+	h, _ := m.changes.Load().(*changeHub)
+	return h != nil && (atomic.LoadUint32(&h.active) != 0 || h.wal != nil)
+}
+
+// record records a change made to a bucket locked exclusively, with
+// capturing true, for sendChanges.
+func (m *CMap) record(op ChangeOp, key, old, value interface{}) {
+	m.recordTTL(op, key, old, value, 0, 0)
+}
+
+// recordTTL is record of a value stored with a deadline and an idle ttl,
+// which the WAL logs.
+func (m *CMap) recordTTL(op ChangeOp, key, old, value interface{}, deadline, idle int64) {
+	h, _ := m.changes.Load().(*changeHub)
+	r := recorded{
+		Change:   Change{Op: op, Key: key, Old: old, New: value, Time: time.Now()},
+		deadline: deadline,
+		idle:     idle,
+	}
+	h.qmu.Lock()
+	h.queue = append(h.queue, r)
+	h.qmu.Unlock()
+}
+
+// recordStore records value, raw or not, stored for key over the raw
+// value prev if loaded.
+func (m *CMap) recordStore(key, prev interface{}, loaded bool, value interface{}) {
+	if e, ok := prev.(*expiring); ok && loaded {
+		if e.expired(nanotime()) {
+			m.record(ChangeExpire, key, e.value, nil)
+			loaded = false
+		} else {
+			prev = e.value
+		}
+	}
+	var deadline, idle int64
+	if e, ok := value.(*expiring); ok {
+		value, deadline, idle = e.value, atomic.LoadInt64(&e.deadline), e.idle
+	}
+	if loaded {
+		m.recordTTL(ChangeUpdate, key, prev, value, deadline, idle)
+	} else {
+		m.recordTTL(ChangeInsert, key, nil, value, deadline, idle)
+	}
+}
+
+// recordDelete records the raw value of key deleted.
+func (m *CMap) recordDelete(key, raw interface{}) {
+	if e, ok := raw.(*expiring); ok {
+		if e.expired(nanotime()) {
+			m.record(ChangeExpire, key, e.value, nil)
+			return
+		}
+		raw = e.value
+	}
+	m.record(ChangeDelete, key, raw, nil)
+}
+
+// sendChanges sends the changes recorded to the channels of Changes and
+// the WAL, once the writer unlocked its bucket.
+func (m *CMap) sendChanges() {
+	if h, _ := m.changes.Load().(*changeHub); h != nil {
+		h.send()
+	}
+}
+
+// changeHub keeps the channels of Changes of a CMap, and the changes
+// recorded for them and for the WAL.
+type changeHub struct {
+	mu     sync.Mutex
+	subs   atomic.Value // []*changeSub, replaced on changes
+	active uint32       // 1 while subs is not empty, see capturing
+	closed bool
+	wal    *wal // logs every change, see WithWAL
+
+	qmu   sync.Mutex
+	queue []recorded // not sent yet
+
+	sendMu sync.Mutex // serializes the senders, so that queue is sent in order
+}
+
+// recorded is a change recorded, with the ttl of the value stored.
+type recorded struct {
+	Change
+	deadline, idle int64
+}
+
+// changeSub is a channel of Changes.
+type changeSub struct {
+	ch     chan Change
+	policy ChangePolicy
+	once   sync.Once
+	done   chan struct{} // closed by cancel, to wake up a blocked sender
+
+	mu     sync.Mutex
+	seq    uint64
+	closed bool // ch is closed
+}
+
+func (h *changeHub) add(m *CMap, buffer int, policy ChangePolicy) (<-chan Change, func()) {
+	s := &changeSub{ch: make(chan Change, buffer), policy: policy, done: make(chan struct{})}
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		s.close()
+		return s.ch, func() {}
+	}
+	subs, _ := h.subs.Load().([]*changeSub)
+	h.subs.Store(append(subs[:len(subs):len(subs)], s))
+	first := atomic.SwapUint32(&h.active, 1) == 0
+	h.mu.Unlock()
+	if first {
+		// waits for the writers which locked their bucket before the
+		// changes were recorded, so that the changes after the call to
+		// Changes are all recorded
+		m.lockAll().unlockAll()
+	}
+
+	cancel := func() {
+		s.close()
+		h.mu.Lock()
+		subs, _ := h.subs.Load().([]*changeSub)
+		for i := range subs {
+			if subs[i] == s {
+				next := make([]*changeSub, 0, len(subs)-1)
+				next = append(append(next, subs[:i]...), subs[i+1:]...)
+				h.subs.Store(next)
+				if len(next) == 0 {
+					atomic.StoreUint32(&h.active, 0)
+				}
+				break
+			}
+		}
+		h.mu.Unlock()
+	}
+	return s.ch, cancel
+}
+
+// send sends the changes recorded to every channel, in order, without
+// holding the hub locked, since a sender may wait for a receiver.
+func (h *changeHub) send() {
+	h.sendMu.Lock()
+	defer h.sendMu.Unlock()
+	for {
+		h.qmu.Lock()
+		queue := h.queue
+		h.queue = nil
+		h.qmu.Unlock()
+		if len(queue) == 0 {
+			return
+		}
+		subs, _ := h.subs.Load().([]*changeSub)
+		for _, r := range queue {
+			for _, s := range subs {
+				s.send(r.Change)
+			}
+			if h.wal == nil {
+				continue
+			}
+			if r.Op == ChangeInsert || r.Op == ChangeUpdate {
+				h.wal.q.send(persistOp{key: r.Key, value: r.New, deadline: r.deadline, idle: r.idle})
+			} else {
+				h.wal.q.send(persistOp{del: true, key: r.Key})
+			}
+		}
+	}
+}
+
+// close closes every channel, once the map is closed.
+func (h *changeHub) close() {
+	h.mu.Lock()
+	subs, _ := h.subs.Load().([]*changeSub)
+	for _, s := range subs {
+		s.close()
+	}
+	h.subs.Store([]*changeSub(nil))
+	atomic.StoreUint32(&h.active, 0)
+	h.closed = true
+	h.mu.Unlock()
+}
+
+func (s *changeSub) send(c Change) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.seq++
+	c.Seq = s.seq
+	if s.policy == ChangesDropOldest {
+		for {
+			select {
+			case s.ch <- c:
+				return
+			default:
+			}
+			// the senders are serialized, so the room made is for c
+			select {
+			case <-s.ch:
+			default:
+			}
+		}
+	}
+	select {
+	case s.ch <- c:
+	case <-s.done:
+	}
+}
+
+func (s *changeSub) close() {
+	s.once.Do(func() {
+		close(s.done)
+		s.mu.Lock()
+		close(s.ch)
+		s.closed = true
+		s.mu.Unlock()
+	})
+}
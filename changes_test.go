@@ -0,0 +1,182 @@
+package cmap_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/min1324/cmap"
+)
+
+func TestChanges(t *testing.T) {
+	m := cmap.New()
+	defer m.Close()
+	ch, cancel := m.Changes(1)
+	go func() {
+		for i := 0; i < 100; i++ {
+			m.Store("k", i)
+		}
+		m.Delete("k")
+		m.StoreWithTTL("ttl", 0, time.Millisecond)
+		time.Sleep(2 * time.Millisecond)
+		m.DeleteExpired()
+	}()
+
+	var old interface{}
+	for i := 0; i <= 100; i++ {
+		c := <-ch
+		if c.Seq != uint64(i+1) || c.Key != "k" || c.Time.IsZero() {
+			t.Fatalf("change %d = %+v", i, c)
+		}
+		want := cmap.ChangeUpdate
+		switch i {
+		case 0:
+			want = cmap.ChangeInsert
+		case 100:
+			want = cmap.ChangeDelete
+		}
+		if c.Op == cmap.ChangeDelete {
+			if c.Old != 99 || c.New != nil {
+				t.Fatalf("change %d = %+v, want Delete of 99", i, c)
+			}
+			old = nil
+			c = <-ch
+			if c.Op != cmap.ChangeInsert || c.Key != "ttl" {
+				t.Fatalf("change = %+v, want Insert of ttl", c)
+			}
+			break
+		}
+		if c.Op != want || c.Old != old || c.New != i {
+			t.Fatalf("change %d = %+v, want %v of %v over %v", i, c, want, i, old)
+		}
+		old = c.New
+	}
+	if c := <-ch; c.Op != cmap.ChangeExpire || c.Key != "ttl" || c.Old != 0 {
+		t.Fatalf("change = %+v, want Expire of ttl", c)
+	}
+	cancel()
+	if _, ok := <-ch; ok {
+		t.Fatal("channel open after cancel")
+	}
+	m.Store("k", 0) // not sent
+}
+
+func TestChangesOrdered(t *testing.T) {
+	m := cmap.New()
+	defer m.Close()
+	ch, cancel := m.Changes(16)
+	replica := make(map[interface{}]interface{})
+	done := make(chan error)
+	go func() {
+		var seq uint64
+		for c := range ch {
+			seq++
+			if c.Seq != seq {
+				done <- fmt.Errorf("Seq %d, want %d", c.Seq, seq)
+				return
+			}
+			old, ok := replica[c.Key]
+			if ok != (c.Op != cmap.ChangeInsert) || old != c.Old {
+				done <- fmt.Errorf("%+v over %v, %v: out of order", c, old, ok)
+				return
+			}
+			if c.Op == cmap.ChangeInsert || c.Op == cmap.ChangeUpdate {
+				replica[c.Key] = c.New
+			} else {
+				delete(replica, c.Key)
+			}
+		}
+		done <- nil
+	}()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				key := i % 4
+				switch i % 5 {
+				case 0:
+					m.Delete(key)
+				case 1:
+					m.LoadOrStore(key, g*10000+i)
+				case 2:
+					m.Update(key, func(v interface{}, ok bool) (interface{}, bool) { return g*10000 + i, false })
+				default:
+					m.Store(key, g*10000+i)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if n := m.Len(); n != len(replica) {
+		t.Fatalf("replica has %d keys, map %d", len(replica), n)
+	}
+	for k, v := range replica {
+		if w, ok := m.Load(k); !ok || w != v {
+			t.Fatalf("replica[%v] = %v, map has %v, %v", k, v, w, ok)
+		}
+	}
+}
+
+func TestChangesCancelOne(t *testing.T) {
+	m := cmap.New()
+	defer m.Close()
+	a, cancelA := m.Changes(16)
+	b, cancelB := m.Changes(16)
+	defer cancelB()
+	m.Store("k", 1)
+	cancelA()
+	for range a {
+	}
+	m.Store("k", 2)
+	for _, want := range []interface{}{1, 2} {
+		select {
+		case c := <-b:
+			if c.New != want {
+				t.Fatalf("change %v, want New %v", c, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no change %v after another channel was cancelled", want)
+		}
+	}
+}
+
+func TestChangesDropOldest(t *testing.T) {
+	m := cmap.New(cmap.WithChangePolicy(cmap.ChangesDropOldest))
+	defer m.Close()
+	ch, cancel := m.Changes(4)
+	defer cancel()
+	for i := 0; i < 100; i++ {
+		m.Store(i, i)
+	}
+	for i := 96; i < 100; i++ {
+		if c := <-ch; c.Seq != uint64(i+1) || c.Key != i {
+			t.Fatalf("change = %+v, want Seq %d of %d", c, i+1, i)
+		}
+	}
+}
+
+func TestChangesBlock(t *testing.T) {
+	m := cmap.New()
+	ch, _ := m.Changes(1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			m.Store(i, i) // blocks once the buffer is full
+		}
+	}()
+	<-ch
+	m.Close()
+	wg.Wait()
+	for range ch {
+	}
+}
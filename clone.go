@@ -0,0 +1,61 @@
+package cmap
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// Clone returns a new map with the entries of m, copied one bucket at a
+// time. Keys keep their ttl. Options, callbacks and watchers are not
+// copied.
+//
+// Like Range, Clone does not correspond to any consistent snapshot of the
+// map's contents: use RangeSnapshot for that.
+func (m *CMap) Clone() *CMap {
+	return m.CloneFunc(nil)
+}
+
+// CloneFunc is like Clone, but stores copy(value) for each value in the
+// new map, for deep copies. copy is called with a bucket of m locked, so
+// it must not use m. If copy panics, the bucket is unlocked first.
+func (m *CMap) CloneFunc(copy func(value interface{}) interface{}) *CMap {
+	n := m.getNode()
+	nn := &node{
+		mask:  n.mask,
+		B:     n.B,
+		data:  make([]unsafe.Pointer, n.mask+1),
+		swiss: n.swiss,
+	}
+	now := nanotime()
+	for i := uintptr(0); i <= n.mask; i++ {
+		nb := nn.newBucket()
+		nb.cloneFrom(n.loadBucket(i), now, copy)
+		atomic.StorePointer(&nn.data[i], unsafe.Pointer(nb))
+	}
+	return &CMap{node: unsafe.Pointer(nn), maxB: m.maxB, swiss: m.swiss, seed: m.seed, part: m.part}
+}
+
+// cloneFrom stores the entries of b live at now into nb, see CloneFunc.
+// b is read locked, and unlocked even if copy panics.
+func (nb *bucket) cloneFrom(b *bucket, now int64, copy func(value interface{}) interface{}) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	b.rangeHash(func(key, value interface{}, hash uintptr) bool {
+		if isExpired(value, now) {
+			return true
+		}
+		if e, ok := value.(*expiring); ok {
+			// never shared, Load changes the access time of e
+			v := e.value
+			if copy != nil {
+				v = copy(v)
+			}
+			value = e.with(v)
+		} else if copy != nil {
+			value = copy(value)
+		}
+		nb.store(key, value, hash)
+		nb.count++
+		return true
+	})
+}
@@ -0,0 +1,47 @@
+package cmap_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/min1324/cmap"
+)
+
+func TestClone(t *testing.T) {
+	var m cmap.CMap
+	for i := 0; i < 1000; i++ {
+		m.Store(i, []int{i})
+	}
+	m.StoreWithTTL("ttl", 1, time.Hour)
+
+	c := m.Clone()
+	if c.Len() != m.Len() {
+		t.Fatalf("Clone has %d keys, want %d", c.Len(), m.Len())
+	}
+	if ttl, ok := c.GetTTL("ttl"); !ok || ttl <= 0 {
+		t.Fatalf("Clone lost the ttl of a key")
+	}
+	m.Delete(1)
+	if _, ok := c.Load(1); !ok {
+		t.Fatalf("Delete on the map changed the clone")
+	}
+	c.Store(2000, 0)
+	if _, ok := m.Load(2000); ok {
+		t.Fatalf("Store on the clone changed the map")
+	}
+
+	d := m.CloneFunc(func(value interface{}) interface{} {
+		if s, ok := value.([]int); ok {
+			return append([]int(nil), s...)
+		}
+		return value
+	})
+	v, _ := m.Load(5)
+	v.([]int)[0] = -1
+	if v, _ := d.Load(5); v.([]int)[0] != 5 {
+		t.Fatalf("CloneFunc shared a value")
+	}
+	if v, _ := c.Load(5); v.([]int)[0] != -1 {
+		t.Fatalf("Clone copied a value")
+	}
+}
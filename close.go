@@ -0,0 +1,71 @@
+package cmap
+
+import (
+	"errors"
+	"sync/atomic"
+	"unsafe"
+)
+
+// ErrClosed is returned by the operations of a closed CMap, see Close.
+var ErrClosed = errors.New("cmap: map closed")
+
+// Close stops the background work started by New, finishes any resize in
+// progress and drops the buckets of the map, so that their memory is
+// released even if the map is still referenced. Changes still queued for a
+// Persister are dropped, call Flush first to write them, while those of the
+// WAL are logged before its files are closed. Watch and Changes channels
+// are closed, and the generation of the map is incremented.
+//
+// A closed map reads as empty, writes are dropped, and the operations
+// returning an error return ErrClosed, or, with WithPanicOnClosed, every
+// operation panics with ErrClosed. Operations racing with Close may or may
+// not take effect. It is safe to call Close more than once.
+func (m *CMap) Close() {
+	if !atomic.CompareAndSwapUint32(&m.closed, 0, 1) {
+		return
+	}
+	// first wakes up the writers waiting for a receiver of Changes, like
+	// the janitor
+	if h, _ := m.changes.Load().(*changeHub); h != nil {
+		h.close()
+	}
+	if m.janitor != nil {
+		m.janitor.stop()
+	}
+	if m.persister != nil {
+		m.persister.stop()
+	}
+	if m.wal != nil {
+		// the changes recorded but not sent yet, now only to the WAL
+		m.sendChanges()
+		m.wal.q.stop()
+		m.wal.q.drain()
+		m.wal.close()
+	}
+
+	m.mu.Lock()
+	if h, _ := m.hub.Load().(*watchHub); h != nil {
+		h.close()
+	}
+	if n := (*node)(atomic.LoadPointer(&m.node)); n != nil {
+		n.evacuateAll()
+		atomic.StorePointer(&m.node, unsafe.Pointer(m.newNode()))
+		atomic.StoreInt64(&m.weight, 0)
+		atomic.StoreInt64(&m.entries, 0)
+	}
+	m.Invalidate()
+	m.mu.Unlock()
+	m.log("cmap: closed")
+}
+
+// checkOpen returns ErrClosed if m is closed, or panics with it if m was
+// created WithPanicOnClosed.
+func (m *CMap) checkOpen() error {
+	if atomic.LoadUint32(&m.closed) == 0 {
+		return nil
+	}
+	if m.panicClosed {
+		panic(ErrClosed)
+	}
+	return ErrClosed
+}
@@ -0,0 +1,77 @@
+package cmap_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/min1324/cmap"
+)
+
+func TestClose(t *testing.T) {
+	m := cmap.New(cmap.WithJanitor(time.Millisecond))
+	for i := 0; i < 10000; i++ {
+		m.Store(i, i)
+	}
+	ch, cancel := m.Watch("k")
+	defer cancel()
+	waited := make(chan error, 1)
+	go func() {
+		_, err := m.LoadWait(context.Background(), "absent")
+		waited <- err
+	}()
+
+	m.Close()
+	m.Close()
+	if _, ok := <-ch; ok {
+		t.Fatalf("Watch channel open after Close")
+	}
+	if err := <-waited; err != cmap.ErrClosed {
+		t.Fatalf("LoadWait returned %v on Close, want ErrClosed", err)
+	}
+	if n := m.Len(); n != 0 {
+		t.Fatalf("Len() = %d after Close", n)
+	}
+	if _, ok := m.Load(1); ok {
+		t.Fatalf("Load found a key after Close")
+	}
+	m.Store(1, 1)
+	if _, loaded := m.LoadOrStore(2, 2); loaded || m.Len() != 0 {
+		t.Fatalf("writes took effect after Close, Len() = %d", m.Len())
+	}
+	m.ForceResize(10)
+	if B := m.Stats().B; B != 4 {
+		t.Fatalf("closed map resized to B = %d", B)
+	}
+	err := m.DoAtomic([]interface{}{1}, func(cmap.TxView) error { return nil })
+	if err != cmap.ErrClosed {
+		t.Fatalf("DoAtomic returned %v after Close, want ErrClosed", err)
+	}
+	if err := m.Flush(context.Background()); err != cmap.ErrClosed {
+		t.Fatalf("Flush returned %v after Close, want ErrClosed", err)
+	}
+	sub, _ := m.Subscribe()
+	if _, ok := <-sub; ok {
+		t.Fatalf("Subscribe channel open after Close")
+	}
+}
+
+func TestPanicOnClosed(t *testing.T) {
+	m := cmap.New(cmap.WithPanicOnClosed())
+	m.Store(1, 1)
+	m.Close()
+	for name, op := range map[string]func(){
+		"Load":   func() { m.Load(1) },
+		"Store":  func() { m.Store(1, 1) },
+		"Delete": func() { m.Delete(1) },
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r != cmap.ErrClosed {
+					t.Errorf("%s after Close panicked with %v, want ErrClosed", name, r)
+				}
+			}()
+			op()
+		}()
+	}
+}
@@ -10,43 +10,172 @@ import (
 const (
 	mInitBit  = 4
 	mInitSize = 1 << mInitBit
+	mMaxBit   = 31
 )
 
+// CMap is a concurrent map split into buckets, each one a Map guarded by
+// its own lock, whose number grows and shrinks with the map.
+//
+// Reads take no lock: the buckets are published with atomic pointers, and
+// each Map serves present keys from its atomically published read-only
+// part, like sync.Map. The bucket locks are only taken by writers.
+//
+// The zero CMap is empty and ready for use. A CMap must not be copied
+// after first use.
 type CMap struct {
-	mu    sync.Mutex
-	count uint32         // number of element
-	node  unsafe.Pointer // *node
+	mu   sync.Mutex
+	node unsafe.Pointer // *node
+
+	seed       uintptr                       // hash seed, see WithHashSeed
+	part       func(key interface{}) uintptr // see WithPartitioner
+	maxB       uint8                         // log_2 of the max # of buckets, see WithMaxShardBits
+	grow       float64                       // load factor, see WithLoadFactor
+	shrink     float64                       // load factor, see WithLoadFactor
+	noShrink   bool                          // see WithShrinkDisabled
+	syncResize bool                          // see WithSynchronousResize
+	meta       bool                          // see WithEntryMeta
+	versioned  bool                          // see WithVersions
+	version    uint64                        // last version given to a value, see WithVersions
+	gen        uint64                        // see Generation
+	swiss      bool                          // see WithSwissBuckets
+	grows      uint64                        // number of resizes to more buckets
+	shrinks    uint64                        // number of resizes to fewer buckets
+	janitor    *janitor                      // removes expired entries, see WithJanitor
+
+	lenEvery int64 // ns a Len is reused for, see WithApproximateLen
+	lenCache int64 // last Len
+	lenTime  int64 // nanotime of lenCache, 0 before the first Len
+
+	persister *persister // see WithPersister
+	wal       *wal       // see WithWAL
+
+	weigher func(key, value interface{}) int64 // see WithWeigher
+	weight  int64                              // total weight of the entries
+
+	keyStats *keyStats // see WithKeyStats
+
+	keyFilter  *bloom // hashes of the keys, see WithBloomFilter
+	maxEntries int64  // see WithMaxEntries
+	entries    int64  // number of entries, only counted with maxEntries
+
+	closed      uint32       // 1 once closed, see Close
+	panicClosed bool         // see WithPanicOnClosed
+	nilKeys     NilKeyPolicy // see WithNilKeys
+
+	changePolicy ChangePolicy // see WithChangePolicy
+
+	onDelete atomic.Value // callback
+	onEvict  atomic.Value // callback
+	onResize atomic.Value // resizeCallback
+	logger   atomic.Value // logger
+	hub      atomic.Value // *watchHub
+	changes  atomic.Value // *changeHub
+	keyLocks atomic.Value // *keyLocks
 }
 
 type node struct {
 	mask   uintptr          // 1<<B - 1
 	B      uint8            // log_2 of # of buckets (can hold up to loadFactor * 2^B items)
-	resize uint32           // 重新计算进程，0表示完成，1表示正在进行
-	data   []unsafe.Pointer // *bucket
+	resize uint32           // 1 once a resize from this node started
+	data   []unsafe.Pointer // *bucket, nil until evacuated
+
+	// evacuation from the previous node, see resize.go
+	old       unsafe.Pointer // *node, nil once every group is evacuated
+	groups    []uint32       // evacuation state of each group
+	next      uint32         // next group to evacuate by writers
+	evacuated uint32         // number of groups evacuated
+	hint      int            // # of items to size each new bucket for
+	started   int64          // nanotime the resize started at
+	done      func(n *node)  // called once every group is evacuated
+
+	swiss bool // buckets are swiss tables
 }
 
 type bucket struct {
-	// something diy
-	m Map
+	// mu is held shared while writing m, and exclusively by the operations
+	// which need the content of the bucket not to change, like evacuating.
+	mu     sync.RWMutex
+	m      Map
+	s      *swissTable // replaces m, see WithSwissBuckets
+	count  int64       // number of element, changed with mu held
+	writes uint64      // number of writes, for Stats
+	shared uint32      // 1 once shared by Fork, never written again
 }
 
 // Load returns the value stored in the map for a key, or nil if no
 // value is present.
 // The ok result indicates whether value was found in the map.
+//
+// Load never waits for the bucket lock, even while the bucket is
+// evacuated or locked by DoAtomic, and never allocates: key does not
+// escape, so a key boxed by the caller stays on its stack.
 func (m *CMap) Load(key interface{}) (value interface{}, ok bool) {
-	hash := chash(key)
-	_, b := m.getNodeAndBucket(hash)
-	value, ok = b.tryLoad(key)
+	if m.panicClosed {
+		m.checkOpen()
+	}
+	// key is only kept by the key stats, which copy it first, see
+	// keyStats.loaded.
+	k := *(*interface{})(noescape(unsafe.Pointer(&key)))
+	hash := m.hashOf(k)
+	b := m.getNode().readBucket(hash)
+	if m.keyFilter == nil || m.keyFilter.mayContain(hash) {
+		value, ok = b.tryLoad(k, hash)
+	}
+	if m.keyStats != nil {
+		m.keyStats.loaded(k, hash, b, ok)
+	}
 	return
 }
 
 // Store sets the value for a key.
 func (m *CMap) Store(key, value interface{}) {
-	hash := chash(key)
+	if m.checkOpen() != nil || m.checkKey(key) != nil {
+		return
+	}
+	m.store(m.hash(key), key, m.wrap(value, 0, 0))
+}
+
+// StoreErr is like Store, but returns the error of a write Store drops:
+// ErrFull for a new key once the map is full, see WithMaxEntries,
+// ErrNilKey for a nil key the map rejects, see WithNilKeys, and ErrClosed
+// if the map is closed.
+func (m *CMap) StoreErr(key, value interface{}) error {
+	if err := m.checkOpen(); err != nil {
+		return err
+	}
+	if err := m.checkKey(key); err != nil {
+		return err
+	}
+	return m.store(m.hash(key), key, m.wrap(value, 0, 0))
+}
+
+// Swap swaps the value for a key and returns the previous value if any.
+// The loaded result reports whether the key was present. Like Store, it
+// drops a ttl of the previous value.
+func (m *CMap) Swap(key, value interface{}) (previous interface{}, loaded bool) {
+	if m.checkOpen() != nil || m.checkKey(key) != nil {
+		return nil, false
+	}
+	previous, loaded, _ = m.swap(m.hash(key), key, m.wrap(value, 0, 0))
+	return previous, loaded
+}
+
+// store stores the raw value of key, as wrapped by m.wrap.
+func (m *CMap) store(hash uintptr, key, value interface{}) error {
+	_, _, err := m.swap(hash, key, value)
+	return err
+}
+
+// swap is store returning the previous value of key, unwrapped, loaded
+// being false if it was absent or expired.
+func (m *CMap) swap(hash uintptr, key, value interface{}) (previous interface{}, loaded bool, err error) {
 	for {
-		n, b := m.getNodeAndBucket(hash)
-		if b.tryStore(m, n, key, value) {
-			return
+		_, b := m.getNodeAndBucket(hash)
+		if previous, loaded, ok, err := b.tryStore(m, hash, key, value); ok {
+			if err == nil {
+				m.stored(key, value)
+			}
+			return previous, loaded, err
 		}
 		runtime.Gosched()
 	}
@@ -56,12 +185,24 @@ func (m *CMap) Store(key, value interface{}) {
 // Otherwise, it stores and returns the given value.
 // The loaded result is true if the value was loaded, false if stored.
 func (m *CMap) LoadOrStore(key, value interface{}) (actual interface{}, loaded bool) {
-	hash := chash(key)
+	if m.checkOpen() != nil || m.checkKey(key) != nil {
+		return nil, false
+	}
+	hash := m.hash(key)
+	raw := m.wrap(value, 0, 0)
 	var ok bool
+	var err error
 	for {
-		n, b := m.getNodeAndBucket(hash)
-		actual, loaded, ok = b.tryLoadOrStore(m, n, key, value)
+		_, b := m.getNodeAndBucket(hash)
+		actual, loaded, ok, err = b.tryLoadOrStore(m, hash, key, raw)
 		if ok {
+			if err != nil {
+				return nil, false
+			}
+			if !loaded {
+				actual = value
+				m.stored(key, value)
+			}
 			return
 		}
 		runtime.Gosched()
@@ -75,7 +216,32 @@ func (m *CMap) Delete(key interface{}) {
 
 // Count returns the number of elements within the map.
 func (m *CMap) Count() uint32 {
-	return m.count
+	return uint32(m.Len())
+}
+
+// Len returns the number of elements within the map.
+//
+// Each bucket counts its own elements, so writers never contend on a
+// shared counter, and Len sums them up. With WithApproximateLen, the sum
+// may be reused for a while, see LenExact.
+func (m *CMap) Len() int {
+	if m.lenEvery == 0 {
+		return m.LenExact()
+	}
+	now := nanotime()
+	if t := atomic.LoadInt64(&m.lenTime); t != 0 && now-t < m.lenEvery {
+		return int(atomic.LoadInt64(&m.lenCache))
+	}
+	n := m.LenExact()
+	atomic.StoreInt64(&m.lenCache, int64(n))
+	atomic.StoreInt64(&m.lenTime, now)
+	return n
+}
+
+// LenExact returns the number of elements within the map, summing the
+// counts of the buckets even with WithApproximateLen.
+func (m *CMap) LenExact() int {
+	return int(m.getNode().count())
 }
 
 // Range calls f sequentially for each key and value present in the map.
@@ -83,8 +249,7 @@ func (m *CMap) Count() uint32 {
 func (m *CMap) Range(f func(key, value interface{}) bool) bool {
 	n := m.getNode()
 	for i := uintptr(0); i <= n.mask; i++ {
-		b := n.getBucket(i)
-		if !b.m.Range(f) {
+		if !n.loadBucket(i).rangeLive(f) {
 			return false
 		}
 	}
@@ -95,11 +260,14 @@ func (m *CMap) Range(f func(key, value interface{}) bool) bool {
 // LoadAndDelete deletes the value for a key, returning the previous value if any.
 // The loaded result reports whether the key was present.
 func (m *CMap) LoadAndDelete(key interface{}) (value interface{}, loaded bool) {
-	hash := chash(key)
+	if m.checkOpen() != nil {
+		return nil, false
+	}
+	hash := m.hash(key)
 	var ok bool
 	for {
-		n, b := m.getNodeAndBucket(hash)
-		value, loaded, ok = b.tryLoadAndDelete(m, n, key)
+		_, b := m.getNodeAndBucket(hash)
+		value, loaded, ok = b.tryLoadAndDelete(m, hash, key)
 		if ok {
 			return
 		}
@@ -108,17 +276,26 @@ func (m *CMap) LoadAndDelete(key interface{}) (value interface{}, loaded bool) {
 }
 
 func (m *CMap) getNodeAndBucket(hash uintptr) (n *node, b *bucket) {
-	for {
-		n = m.getNode()
-		b = n.getBucket(hash)
-		if b != nil {
-			break
-		}
-		runtime.Gosched()
-		// evacuted old bucket
-		// wait until init new bucket
+	n = m.getNode()
+	return n, n.loadBucket(hash & n.mask)
+}
+
+// hash returns the hash of key in m, for an operation on key.
+func (m *CMap) hash(key interface{}) uintptr {
+	hash := m.hashOf(key)
+	if m.keyStats != nil {
+		m.keyStats.access(key, hash)
+	}
+	return hash
+}
+
+// hashOf is hash without counting an operation on key.
+func (m *CMap) hashOf(key interface{}) uintptr {
+	if atomic.LoadPointer(&m.node) == nil {
+		// the seed is set with the first node
+		m.getNode()
 	}
-	return n, b
+	return m.keyHash(key)
 }
 
 func (m *CMap) getNode() *node {
@@ -127,15 +304,10 @@ func (m *CMap) getNode() *node {
 		m.mu.Lock()
 		n = (*node)(atomic.LoadPointer(&m.node))
 		if n == nil {
-			n = &node{
-				mask: uintptr(mInitSize - 1),
-				B:    mInitBit,
-				data: make([]unsafe.Pointer, mInitSize),
-			}
-			for i := 0; i < mInitSize; i++ {
-				b := new(bucket)
-				atomic.StorePointer(&n.data[i], unsafe.Pointer(b))
+			if m.seed == 0 {
+				m.seed = newSeed()
 			}
+			n = m.newNode()
 			atomic.StorePointer(&m.node, unsafe.Pointer(n))
 		}
 		m.mu.Unlock()
@@ -143,94 +315,292 @@ func (m *CMap) getNode() *node {
 	return n
 }
 
+// newNode returns a node of empty buckets of the initial size.
+func (m *CMap) newNode() *node {
+	n := &node{
+		mask:  uintptr(mInitSize - 1),
+		B:     mInitBit,
+		data:  make([]unsafe.Pointer, mInitSize),
+		swiss: m.swiss,
+	}
+	for i := 0; i < mInitSize; i++ {
+		b := n.newBucket()
+		atomic.StorePointer(&n.data[i], unsafe.Pointer(b))
+	}
+	return n
+}
+
 func (n *node) getBucket(i uintptr) *bucket {
 	return (*bucket)(atomic.LoadPointer(&n.data[i&n.mask]))
 }
 
-func (b *bucket) tryLoad(key interface{}) (value interface{}, ok bool) {
-	return b.m.Load(key)
+// count returns the number of elements within the buckets of n, or within
+// the old buckets for the groups not evacuated yet.
+func (n *node) count() int64 {
+	o := n.oldNode()
+	if o == nil {
+		return sumCount(n, 0, 1)
+	}
+	var count int64
+	step := uintptr(len(n.groups))
+	for g := uintptr(0); g < step; g++ {
+		if atomic.LoadUint32(&n.groups[g]) == evacDone {
+			count += sumCount(n, g, step)
+		} else {
+			count += sumCount(o, g, step)
+		}
+	}
+	return count
 }
 
-func (b *bucket) tryStore(m *CMap, n *node, key, value interface{}) bool {
-	_, loaded, _ := b.tryLoadOrStore(m, n, key, value)
-	if loaded {
-		b.m.Store(key, value)
+// sumCount sums the count of the buckets g, g+step, ... of n.
+func sumCount(n *node, g, step uintptr) int64 {
+	var count int64
+	for i := g; i <= n.mask; i += step {
+		count += atomic.LoadInt64(&n.getBucket(i).count)
+	}
+	return count
+}
+
+func (b *bucket) tryLoad(key interface{}, hash uintptr) (value interface{}, ok bool) {
+	value, ok = b.load(key, hash)
+	if ok {
+		value, ok = access(value)
+	}
+	return value, ok
+}
+
+// rlock locks b for writing a key of hash, ok is false if b is no longer
+// the bucket of hash, that is the key was evacuated by a resize.
+func (b *bucket) rlock(m *CMap, hash uintptr) (n *node, ok bool) {
+	b.mu.RLock()
+	n = m.getNode()
+	if n.getBucket(hash) != b {
+		debugFrozen(b)
+		b.mu.RUnlock()
+		return nil, false
+	}
+	if atomic.LoadUint32(&b.shared) != 0 {
+		b.mu.RUnlock()
+		n.unshare(hash, b)
+		return nil, false
+	}
+	atomic.AddUint64(&b.writes, 1)
+	return n, true
+}
+
+// wlock is rlock, but locks b exclusively while the changes of m are
+// recorded, see Changes, so that they are recorded in the order they are
+// made. capture reports it, and is passed to wunlock.
+func (b *bucket) wlock(m *CMap, hash uintptr) (n *node, capture, ok bool) {
+	for {
+		if m.capturing() {
+			n, ok = b.lock(m, hash)
+			return n, true, ok
+		}
+		if n, ok = b.rlock(m, hash); !ok || !m.capturing() {
+			return n, false, ok
+		}
+		// Changes was called meanwhile
+		b.mu.RUnlock()
+	}
+}
+
+// wunlock unlocks b locked by wlock.
+func (b *bucket) wunlock(capture bool) {
+	if capture {
+		b.mu.Unlock()
+	} else {
+		b.mu.RUnlock()
 	}
-	return true
 }
 
-func (b *bucket) tryLoadOrStore(m *CMap, n *node, key, value interface{}) (actual interface{}, loaded, ok bool) {
-	actual, loaded = b.m.LoadOrStore(key, value)
+func (b *bucket) tryStore(m *CMap, hash uintptr, key, value interface{}) (previous interface{}, loaded, ok bool, err error) {
+	if !m.addKey(hash) {
+		return b.tryReplace(m, hash, key, value)
+	}
+	n, capture, ok := b.wlock(m, hash)
+	if !ok {
+		m.dropKey(hash)
+		return nil, false, false, nil
+	}
+	// a swap, unlike a load and a store, can't put back a key deleted
+	// meanwhile by a writer sharing the lock
+	prev, loaded := b.swap(key, value, hash)
 	if loaded {
-		return actual, loaded, true
+		m.dropKey(hash)
+	} else {
+		atomic.AddInt64(&b.count, 1)
 	}
-	// grow
-	if overflowGrow(atomic.AddUint32(&m.count, 1), n.B) {
-		growWork(m, n, n.B+1)
+	if capture {
+		m.recordStore(key, prev, loaded, value)
 	}
-	return actual, loaded, true
+	b.wunlock(capture)
+	previous, loaded = m.replaced(n, b, key, prev, loaded)
+	return previous, loaded, true, nil
 }
 
-func (b *bucket) tryLoadAndDelete(m *CMap, n *node, key interface{}) (actual interface{}, loaded, ok bool) {
-	actual, loaded = b.m.LoadAndDelete(key)
+// tryReplace is tryStore for a full map, see WithMaxEntries: only a key
+// present can be written, with b locked exclusively so that it can't be
+// deleted meanwhile.
+func (b *bucket) tryReplace(m *CMap, hash uintptr, key, value interface{}) (previous interface{}, loaded, ok bool, err error) {
+	n, ok := b.lock(m, hash)
+	if !ok {
+		return nil, false, false, nil
+	}
+	prev, loaded := b.load(key, hash)
 	if !loaded {
-		atomic.AddUint32(&m.count, ^uint32(0))
+		b.mu.Unlock()
+		return nil, false, true, ErrFull
 	}
-	return actual, loaded, true
+	b.store(key, value, hash)
+	if m.capturing() {
+		m.recordStore(key, prev, loaded, value)
+	}
+	b.mu.Unlock()
+	previous, loaded = m.replaced(n, b, key, prev, loaded)
+	return previous, loaded, true, nil
 }
 
-func growWork(m *CMap, n *node, B uint8) {
-	if !atomic.CompareAndSwapUint32(&n.resize, 0, 1) {
-		return
+func (b *bucket) tryLoadOrStore(m *CMap, hash uintptr, key, value interface{}) (actual interface{}, loaded, ok bool, err error) {
+	n, capture, ok := b.wlock(m, hash)
+	if !ok {
+		return nil, false, false, nil
 	}
-	nn := &node{
-		mask:   bucketMask(B),
-		B:      B,
-		resize: 1,
-		data:   make([]unsafe.Pointer, bucketShift(B)),
+	actual, loaded, old, err := b.loadOrStoreLocked(m, hash, key, value)
+	if capture && err == nil && !loaded {
+		m.recordStore(key, old, old != nil, value)
 	}
-	// cas node
-	ok := atomic.CompareAndSwapPointer(&m.node, unsafe.Pointer(n), unsafe.Pointer(nn))
-	if !ok {
-		panic("BUG: failed swapping head")
-	}
-	// evacute old node to new node
-	go func() {
-		oLen := bucketShift(n.B)
-		for i := 0; i < int(oLen); i++ {
-			newBucket := new(bucket)
-			oldBucket := n.getBucket(uintptr(i))
-			// #issue01 concurrent delete or store err
-			oldBucket.m.walkLocketInFreeze(func(key, value interface{}) bool {
-				h := chash(key)
-				if h&nn.mask != uintptr(i) {
-					newBucket.m.Store(key, value)
-					oldBucket.m.deleteLocked(key)
-				}
-				return true
-			})
-			atomic.StorePointer(&nn.data[i+int(oLen)], unsafe.Pointer(newBucket))
-			atomic.StorePointer(&nn.data[i], unsafe.Pointer(oldBucket))
+	b.wunlock(capture)
+	if err != nil {
+		return nil, false, true, err
+	}
+	m.inserted(n, b, key, loaded, old)
+	n.assist()
+	return actual, loaded, true, nil
+}
+
+// loadOrStoreLocked is LoadOrStore of the bucket, but an expired value
+// is replaced as if it were absent, and returned as old. It returns
+// ErrFull instead of storing a new key into a full map.
+func (b *bucket) loadOrStoreLocked(m *CMap, hash uintptr, key, value interface{}) (actual interface{}, loaded bool, old *expiring, err error) {
+	// the key is added before knowing whether it is new, and dropped if it
+	// is not
+	added := m.addKey(hash)
+	for {
+		if added {
+			actual, loaded = b.loadOrStore(key, value, hash)
+			if !loaded {
+				atomic.AddInt64(&b.count, 1)
+				return actual, false, nil, nil
+			}
+		} else if actual, loaded = b.load(key, hash); !loaded {
+			return nil, false, nil, ErrFull
+		}
+		e, ok := actual.(*expiring)
+		if !ok {
+			if added {
+				m.dropKey(hash)
+			}
+			return actual, true, nil, nil
 		}
-		atomic.AddUint32(&nn.resize, 0)
-	}()
+		if !e.expired(nanotime()) {
+			if added {
+				m.dropKey(hash)
+			}
+			return e.value, true, nil, nil
+		}
+		if b.compareAndSwap(key, hash, actual, value) {
+			if added {
+				m.dropKey(hash)
+			}
+			return value, false, e, nil
+		}
+	}
+}
 
+// replaced is called after key was stored into bucket b of node n, over
+// the raw value prev if loaded. It returns prev unwrapped, and whether it
+// was live.
+func (m *CMap) replaced(n *node, b *bucket, key, prev interface{}, loaded bool) (interface{}, bool) {
+	var old *expiring
+	if e, ok := prev.(*expiring); ok && loaded {
+		if e.expired(nanotime()) {
+			old, loaded = e, false
+		} else {
+			prev = e.value
+		}
+	}
+	if loaded {
+		m.weigh(key, prev, -1)
+	}
+	m.inserted(n, b, key, loaded, old)
+	n.assist()
+	if !loaded {
+		return nil, false
+	}
+	return prev, true
 }
 
-// buckut len over loadfactor
-func overLoadFactor(blen uint32, B uint8) bool {
-	if B > 15 {
-		B = 15
+// inserted is called after a key was stored into bucket b of node n.
+func (m *CMap) inserted(n *node, b *bucket, key interface{}, loaded bool, old *expiring) {
+	n.debugCheck(b)
+	if old != nil {
+		// replaced an expired value, count is unchanged
+		m.evicted(key, old.value)
+		return
 	}
-	return blen > uint32(1<<(B+1)) && B < 31
+	if loaded {
+		return
+	}
+	// grow
+	if n.B < m.maxBit() && m.overLoadFactor(atomic.LoadInt64(&b.count), n.B) {
+		growWork(m, n, n.B+1, 0)
+	}
+}
+
+func (b *bucket) tryLoadAndDelete(m *CMap, hash uintptr, key interface{}) (actual interface{}, loaded, ok bool) {
+	n, capture, ok := b.wlock(m, hash)
+	if !ok {
+		return nil, false, false
+	}
+	actual, loaded = b.loadAndDelete(key, hash)
+	if loaded {
+		atomic.AddInt64(&b.count, -1)
+		m.dropKey(hash)
+		if capture {
+			m.recordDelete(key, actual)
+		}
+	}
+	b.wunlock(capture)
+	n.assist()
+	if loaded {
+		m.removed(n, b)
+		if e, ok := actual.(*expiring); ok {
+			if e.expired(nanotime()) {
+				m.evicted(key, e.value)
+				return nil, false, true
+			}
+			actual = e.value
+		}
+		m.deleted(key, actual)
+	}
+	return actual, loaded, true
+}
+
+// overLoadFactor reports whether a bucket of blen elements is over the
+// grow load factor of a node of 1<<B buckets.
+func (m *CMap) overLoadFactor(blen int64, B uint8) bool {
+	grow, _ := m.loadFactor()
+	return float64(blen) > grow*bucketCap(B)
 }
 
-// count overflow grow threshold
-func overflowGrow(count uint32, B uint8) bool {
-	if B > 31 {
-		return false
+// maxBit returns the max B the map grows to.
+func (m *CMap) maxBit() uint8 {
+	if m.maxB == 0 {
+		return mMaxBit
 	}
-	return count >= uint32(1<<(2*B))
+	return m.maxB
 }
 
 // bucketShift returns 1<<b, optimized for code generation.
@@ -2,6 +2,8 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
+//go:build go1.20
+
 package cmap_test
 
 import (
@@ -24,7 +26,7 @@ func benchMap(b *testing.B, bench bench) {
 		// &DeepCopyMap{},
 		// &RWMutexMap{},
 		&sync.Map{},
-		&cmap.Map{},
+		cmap.AdaptMap(&cmap.Map{}),
 	} {
 		b.Run(fmt.Sprintf("%T", m), func(b *testing.B) {
 			m = reflect.New(reflect.TypeOf(m).Elem()).Interface().(mapInterface)
@@ -7,19 +7,14 @@ package cmap_test
 import (
 	"sync"
 	"sync/atomic"
+
+	"github.com/min1324/cmap"
 )
 
 // This file contains reference map implementations for unit-tests.
 
-// mapInterface is the interface Map implements.
-type mapInterface interface {
-	Load(interface{}) (interface{}, bool)
-	Store(key, value interface{})
-	LoadOrStore(key, value interface{}) (actual interface{}, loaded bool)
-	LoadAndDelete(key interface{}) (value interface{}, loaded bool)
-	Delete(interface{})
-	Range(func(key, value interface{}) (shouldContinue bool))
-}
+// mapInterface is the interface Map implements, through cmap.AdaptMap.
+type mapInterface = cmap.Interface
 
 // RWMutexMap is an implementation of mapInterface using a sync.RWMutex.
 type RWMutexMap struct {
@@ -75,6 +70,27 @@ func (m *RWMutexMap) Delete(key interface{}) {
 	m.mu.Unlock()
 }
 
+func (m *RWMutexMap) Swap(key, value interface{}) (previous interface{}, loaded bool) {
+	m.mu.Lock()
+	if m.dirty == nil {
+		m.dirty = make(map[interface{}]interface{})
+	}
+	previous, loaded = m.dirty[key]
+	m.dirty[key] = value
+	m.mu.Unlock()
+	return
+}
+
+func (m *RWMutexMap) CompareAndSwap(key, old, new interface{}) (swapped bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if value, ok := m.dirty[key]; !ok || value != old {
+		return false
+	}
+	m.dirty[key] = new
+	return true
+}
+
 func (m *RWMutexMap) Range(f func(key, value interface{}) (shouldContinue bool)) {
 	m.mu.RLock()
 	keys := make([]interface{}, 0, len(m.dirty))
@@ -155,6 +171,34 @@ func (m *DeepCopyMap) Delete(key interface{}) {
 	m.mu.Unlock()
 }
 
+func (m *DeepCopyMap) Swap(key, value interface{}) (previous interface{}, loaded bool) {
+	m.mu.Lock()
+	dirty := m.dirty()
+	previous, loaded = dirty[key]
+	dirty[key] = value
+	m.clean.Store(dirty)
+	m.mu.Unlock()
+	return
+}
+
+func (m *DeepCopyMap) CompareAndSwap(key, old, new interface{}) (swapped bool) {
+	clean, _ := m.clean.Load().(map[interface{}]interface{})
+	if previous, ok := clean[key]; !ok || previous != old {
+		return false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dirty := m.dirty()
+	value, loaded := dirty[key]
+	if !loaded || value != old {
+		return false
+	}
+	dirty[key] = new
+	m.clean.Store(dirty)
+	return true
+}
+
 func (m *DeepCopyMap) Range(f func(key, value interface{}) (shouldContinue bool)) {
 	clean, _ := m.clean.Load().(map[interface{}]interface{})
 	for k, v := range clean {
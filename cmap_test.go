@@ -12,6 +12,7 @@ import (
 	"sync/atomic"
 	"testing"
 	"testing/quick"
+	"time"
 
 	"github.com/min1324/cmap"
 )
@@ -19,14 +20,16 @@ import (
 type mapOp string
 
 const (
-	opLoad          = mapOp("Load")
-	opStore         = mapOp("Store")
-	opLoadOrStore   = mapOp("LoadOrStore")
-	opLoadAndDelete = mapOp("LoadAndDelete")
-	opDelete        = mapOp("Delete")
+	opLoad           = mapOp("Load")
+	opStore          = mapOp("Store")
+	opLoadOrStore    = mapOp("LoadOrStore")
+	opLoadAndDelete  = mapOp("LoadAndDelete")
+	opDelete         = mapOp("Delete")
+	opSwap           = mapOp("Swap")
+	opCompareAndSwap = mapOp("CompareAndSwap")
 )
 
-var mapOps = [...]mapOp{opLoad, opStore, opLoadOrStore, opLoadAndDelete, opDelete}
+var mapOps = [...]mapOp{opLoad, opStore, opLoadOrStore, opLoadAndDelete, opDelete, opSwap, opCompareAndSwap}
 
 // mapCall is a quick.Generator for calls on mapInterface.
 type mapCall struct {
@@ -48,6 +51,13 @@ func (c mapCall) apply(m mapInterface) (interface{}, bool) {
 	case opDelete:
 		m.Delete(c.k)
 		return nil, false
+	case opSwap:
+		return m.Swap(c.k, c.v)
+	case opCompareAndSwap:
+		if m.CompareAndSwap(c.k, c.v, "swapped") {
+			return c.v, true
+		}
+		return nil, false
 	default:
 		panic("invalid mapOp")
 	}
@@ -69,7 +79,7 @@ func randValue(r *rand.Rand) interface{} {
 func (mapCall) Generate(r *rand.Rand, size int) reflect.Value {
 	c := mapCall{op: mapOps[rand.Intn(len(mapOps))], k: randValue(r)}
 	switch c.op {
-	case opStore, opLoadOrStore:
+	case opStore, opLoadOrStore, opSwap, opCompareAndSwap:
 		c.v = randValue(r)
 	}
 	return reflect.ValueOf(c)
@@ -91,11 +101,11 @@ func applyCalls(m mapInterface, calls []mapCall) (results []mapResult, final map
 }
 
 func applyMap(calls []mapCall) ([]mapResult, map[interface{}]interface{}) {
-	return applyCalls(new(cmap.Map), calls)
+	return applyCalls(cmap.AdaptMap(new(cmap.Map)), calls)
 }
 
-func applySyncMap(calls []mapCall) ([]mapResult, map[interface{}]interface{}) {
-	return applyCalls(new(sync.Map), calls)
+func applyCMap(calls []mapCall) ([]mapResult, map[interface{}]interface{}) {
+	return applyCalls(cmap.AdaptCMap(new(cmap.CMap)), calls)
 }
 
 func applyRWMutexMap(calls []mapCall) ([]mapResult, map[interface{}]interface{}) {
@@ -117,8 +127,8 @@ func TestMapEvacute(t *testing.T) {
 	}
 }
 
-func TestMapMatchesSync(t *testing.T) {
-	if err := quick.CheckEqual(applyMap, applySyncMap, nil); err != nil {
+func TestMapMatchesCMap(t *testing.T) {
+	if err := quick.CheckEqual(applyMap, applyCMap, nil); err != nil {
 		t.Error(err)
 	}
 }
@@ -271,3 +281,56 @@ func TestMapStoreAndLoad(t *testing.T) {
 		return false
 	})
 }
+
+func TestCMapLen(t *testing.T) {
+	var m cmap.CMap
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := g; i < 1000; i += 4 {
+				m.Store(i, i)
+				m.Store(i, i)
+				m.Delete(-i - 1)
+				if i%2 == 0 {
+					m.Delete(i)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if n := m.Len(); n != 500 {
+		t.Fatalf("Len() = %d, want 500", n)
+	}
+}
+
+func TestCMapLoadLockFree(t *testing.T) {
+	var m cmap.CMap
+	m.Store(1, 1)
+	locked := make(chan struct{})
+	release := make(chan struct{})
+	go m.DoAtomic([]interface{}{1}, func(tx cmap.TxView) error {
+		close(locked)
+		<-release
+		return nil
+	})
+	<-locked
+	defer close(release)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			if v, ok := m.Load(1); !ok || v != 1 {
+				t.Errorf("Load(1) = %v, %v", v, ok)
+				return
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Load blocked on a locked bucket")
+	}
+}
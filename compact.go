@@ -0,0 +1,35 @@
+package cmap
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// Compact rebuilds the buckets of the map sized for their current
+// elements, releasing the memory kept by Go maps after deletes.
+//
+// Buckets are rebuilt one at a time, each one blocking only its own
+// writers while it is copied. If the map starts resizing meanwhile,
+// Compact returns early, since a resize rebuilds every bucket anyway.
+func (m *CMap) Compact() {
+	n := m.getNode()
+	n.evacuateAll()
+	for i := uintptr(0); i <= n.mask; i++ {
+		b := n.lockBucket(i)
+		if m.getNode() != n {
+			b.mu.Unlock()
+			return
+		}
+		nb := n.newBucket()
+		nb.reserve(int(b.count))
+		b.rangeHash(func(key, value interface{}, hash uintptr) bool {
+			nb.store(key, value, hash)
+			return true
+		})
+		nb.count = b.count
+		// writers of b check that it is still in place once they lock it
+		atomic.StorePointer(&n.data[i], unsafe.Pointer(nb))
+		debugFreeze(b)
+		b.mu.Unlock()
+	}
+}
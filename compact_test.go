@@ -0,0 +1,44 @@
+package cmap_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestCompact(t *testing.T) {
+	var m cmap.CMap
+	for i := 0; i < 10000; i++ {
+		m.Store(i, i)
+	}
+	for i := 10; i < 10000; i++ {
+		m.Delete(i)
+	}
+
+	// writers racing with Compact must not be lost
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			m.Store(-i-1, i)
+		}
+	}()
+	m.Compact()
+	wg.Wait()
+
+	if m.Len() != 1010 {
+		t.Fatalf("Len() = %d after Compact, want 1010", m.Len())
+	}
+	for i := 0; i < 10; i++ {
+		if v, ok := m.Load(i); !ok || v != i {
+			t.Fatalf("Load(%d) = %v, %v after Compact", i, v, ok)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		if v, ok := m.Load(-i - 1); !ok || v != i {
+			t.Fatalf("Load(%d) = %v, %v after Compact", -i-1, v, ok)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package cmap
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+// Update sets the value of key to the result of f, called with the
+// current value of key, if any, while no other write to key can happen.
+// If del is true, key is deleted instead. A ttl of the current value is
+// kept for the new one.
+//
+// Update returns the value of key once f is applied, ok is false if key
+// is absent. f is called with the bucket of key locked, so it must not
+// use the map. If f panics, the bucket is unlocked and key left
+// unchanged before the panic goes on.
+func (m *CMap) Update(key interface{}, f func(value interface{}, loaded bool) (newValue interface{}, del bool)) (value interface{}, ok bool) {
+	return m.compute(key, func(value interface{}, loaded bool) (interface{}, action) {
+		value, del := f(value, loaded)
+		if del {
+			return nil, actDelete
+		}
+		return value, actStore
+	})
+}
+
+// action is what compute does with the result of its func.
+type action uint8
+
+const (
+	actKeep   action = iota // leave the key unchanged
+	actStore                // store the result
+	actDelete               // delete the key
+)
+
+// compute is Update with f deciding on an action.
+func (m *CMap) compute(key interface{}, f func(value interface{}, loaded bool) (interface{}, action)) (value interface{}, ok bool) {
+	return m.computeVersion(key, func(value interface{}, _ uint64, loaded bool) (interface{}, action) {
+		return f(value, loaded)
+	})
+}
+
+// computeVersion is compute with f also given the version of the value,
+// see WithVersions.
+func (m *CMap) computeVersion(key interface{}, f func(value interface{}, version uint64, loaded bool) (interface{}, action)) (value interface{}, ok bool) {
+	if m.checkOpen() != nil || m.checkKey(key) != nil {
+		return nil, false
+	}
+	hash := m.hash(key)
+	for {
+		_, b := m.getNodeAndBucket(hash)
+		if value, ok, done := b.tryCompute(m, hash, key, f); done {
+			return value, ok
+		}
+		runtime.Gosched()
+	}
+}
+
+// lock is like rlock, but locks b exclusively.
+func (b *bucket) lock(m *CMap, hash uintptr) (n *node, ok bool) {
+	b.mu.Lock()
+	n = m.getNode()
+	if n.getBucket(hash) != b {
+		debugFrozen(b)
+		b.mu.Unlock()
+		return nil, false
+	}
+	if atomic.LoadUint32(&b.shared) != 0 {
+		b.mu.Unlock()
+		n.unshare(hash, b)
+		return nil, false
+	}
+	atomic.AddUint64(&b.writes, 1)
+	return n, true
+}
+
+func (b *bucket) tryCompute(m *CMap, hash uintptr, key interface{}, f func(interface{}, uint64, bool) (interface{}, action)) (value interface{}, ok, done bool) {
+	n, done := b.lock(m, hash)
+	if !done {
+		return nil, false, false
+	}
+	raw, present := b.load(key, hash)
+	cur, loaded := raw, present
+	var old, e *expiring
+	if e, _ = raw.(*expiring); e != nil {
+		cur = e.value
+		if e.expired(nanotime()) {
+			old, cur, loaded = e, nil, false
+		}
+	}
+
+	var version uint64
+	if loaded && e != nil {
+		version = e.version
+	}
+	value, act := b.call(f, cur, version, loaded)
+	switch act {
+	case actKeep:
+		b.mu.Unlock()
+		return cur, loaded, true
+	case actDelete:
+		if present {
+			b.loadAndDelete(key, hash)
+			atomic.AddInt64(&b.count, -1)
+			m.dropKey(hash)
+			if m.capturing() {
+				m.recordDelete(key, raw)
+			}
+		}
+	default:
+		if !present && !m.addKey(hash) {
+			// full, key stays absent
+			b.mu.Unlock()
+			return nil, false, true
+		}
+		var deadline, idle int64
+		if loaded && e != nil {
+			deadline, idle = atomic.LoadInt64(&e.deadline), e.idle
+			if idle != 0 {
+				deadline = nanotime() + idle
+			}
+		}
+		prev := raw
+		raw = m.wrap(value, deadline, idle)
+		if m.capturing() {
+			m.recordStore(key, prev, present, raw)
+		}
+		b.store(key, raw, hash)
+		if !present {
+			atomic.AddInt64(&b.count, 1)
+		}
+	}
+	b.mu.Unlock()
+
+	if act == actDelete {
+		if old != nil {
+			m.evicted(key, old.value)
+		} else if loaded {
+			m.deleted(key, cur)
+		}
+		if present {
+			m.removed(n, b)
+		}
+		n.assist()
+		return nil, false, true
+	}
+	if loaded {
+		m.weigh(key, cur, -1)
+	}
+	m.inserted(n, b, key, present, old)
+	m.schedule(key, hash, raw)
+	m.stored(key, value)
+	n.assist()
+	return value, true, true
+}
+
+// call calls f with b locked by the caller, and unlocks b if f panics, so
+// that a panicking callback leaves the bucket usable.
+func (b *bucket) call(f func(interface{}, uint64, bool) (interface{}, action), value interface{}, version uint64, loaded bool) (interface{}, action) {
+	done := false
+	defer func() {
+		if !done {
+			b.mu.Unlock()
+		}
+	}()
+	value, act := f(value, version, loaded)
+	done = true
+	return value, act
+}
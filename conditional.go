@@ -0,0 +1,61 @@
+package cmap
+
+// SetIfAbsent stores value for key if key is absent, and reports whether
+// it did, like putIfAbsent of Java's ConcurrentHashMap.
+func (m *CMap) SetIfAbsent(key, value interface{}) bool {
+	_, loaded := m.LoadOrStore(key, value)
+	return !loaded
+}
+
+// Replace stores value for key only if key is present, and returns the
+// value it replaced. ok is false if key is absent, and nothing is stored.
+// Like Update, a ttl of the replaced value is kept for the new one.
+func (m *CMap) Replace(key, value interface{}) (old interface{}, ok bool) {
+	m.compute(key, func(cur interface{}, loaded bool) (interface{}, action) {
+		if !loaded {
+			return nil, actKeep
+		}
+		old, ok = cur, true
+		return value, actStore
+	})
+	return old, ok
+}
+
+// CompareAndSwap swaps the old and new values for key if the value stored
+// in the map is equal to old, and reports whether it did. Unlike the one
+// of sync.Map, it doesn't panic on a value which is not comparable, it
+// reports it different. Like Update, a ttl of the value is kept.
+func (m *CMap) CompareAndSwap(key, old, new interface{}) (swapped bool) {
+	return m.CompareAndSwapFunc(key, old, new, equal)
+}
+
+// CompareAndDelete deletes the entry for key if its value is equal to
+// old, and reports whether it did. Like CompareAndSwap, a value which is
+// not comparable is different.
+func (m *CMap) CompareAndDelete(key, old interface{}) (deleted bool) {
+	m.compute(key, func(cur interface{}, loaded bool) (interface{}, action) {
+		if !loaded || !equal(cur, old) {
+			return nil, actKeep
+		}
+		deleted = true
+		return nil, actDelete
+	})
+	return deleted
+}
+
+// CompareAndSwapFunc stores new for key if key is present and eq reports
+// its value equal to old, and reports whether it did. Unlike ==, eq can
+// compare values which are not comparable, like slices and maps.
+//
+// eq is called with the bucket of key locked, so it must not use the map.
+// Like Update, a ttl of the value is kept.
+func (m *CMap) CompareAndSwapFunc(key, old, new interface{}, eq func(a, b interface{}) bool) (swapped bool) {
+	m.compute(key, func(cur interface{}, loaded bool) (interface{}, action) {
+		if !loaded || !eq(cur, old) {
+			return nil, actKeep
+		}
+		swapped = true
+		return new, actStore
+	})
+	return swapped
+}
@@ -0,0 +1,97 @@
+package cmap_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/min1324/cmap"
+)
+
+func TestSetIfAbsent(t *testing.T) {
+	var m cmap.CMap
+	if !m.SetIfAbsent("a", 1) {
+		t.Fatalf("SetIfAbsent(a) did not store in an empty map")
+	}
+	if m.SetIfAbsent("a", 2) {
+		t.Fatalf("SetIfAbsent(a) stored over a present key")
+	}
+	if v, _ := m.Load("a"); v != 1 {
+		t.Fatalf("Load(a) = %v, want 1", v)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	var m cmap.CMap
+	if _, ok := m.Replace("a", 1); ok {
+		t.Fatalf("Replace(a) reported an absent key")
+	}
+	if _, ok := m.Load("a"); ok {
+		t.Fatalf("Replace stored an absent key")
+	}
+	m.StoreWithTTL("a", 1, time.Hour)
+	if old, ok := m.Replace("a", 2); !ok || old != 1 {
+		t.Fatalf("Replace(a) = %v, %v; want 1, true", old, ok)
+	}
+	if v, _ := m.Load("a"); v != 2 {
+		t.Fatalf("Load(a) = %v after Replace, want 2", v)
+	}
+	if ttl, _ := m.GetTTL("a"); ttl == cmap.NoExpiration {
+		t.Fatalf("Replace dropped the ttl of a")
+	}
+}
+
+func TestCompareAndSwapFunc(t *testing.T) {
+	var m cmap.CMap
+	eq := func(a, b interface{}) bool { return reflect.DeepEqual(a, b) }
+	if m.CompareAndSwapFunc("a", []int{1}, []int{2}, eq) {
+		t.Fatalf("CompareAndSwapFunc swapped an absent key")
+	}
+	m.Store("a", []int{1})
+	if m.CompareAndSwapFunc("a", []int{3}, []int{2}, eq) {
+		t.Fatalf("CompareAndSwapFunc swapped a different value")
+	}
+	if !m.CompareAndSwapFunc("a", []int{1}, []int{2}, eq) {
+		t.Fatalf("CompareAndSwapFunc did not swap an equal value")
+	}
+	if v, _ := m.Load("a"); !eq(v, []int{2}) {
+		t.Fatalf("Load(a) = %v, want [2]", v)
+	}
+}
+
+func TestCompareAndDelete(t *testing.T) {
+	var m cmap.CMap
+	m.Store("a", 1)
+	m.Store("b", []int{1})
+	if m.CompareAndDelete("a", 2) {
+		t.Fatalf("CompareAndDelete deleted a different value")
+	}
+	if m.CompareAndDelete("b", []int{1}) {
+		t.Fatalf("CompareAndDelete deleted a value not comparable")
+	}
+	if !m.CompareAndDelete("a", 1) {
+		t.Fatalf("CompareAndDelete did not delete an equal value")
+	}
+	if _, ok := m.Load("a"); ok {
+		t.Fatalf("a present after CompareAndDelete")
+	}
+	if m.CompareAndDelete("a", 1) {
+		t.Fatalf("CompareAndDelete deleted an absent key")
+	}
+}
+
+func TestSwapTTL(t *testing.T) {
+	var m cmap.CMap
+	m.StoreWithTTL("a", 1, time.Hour)
+	if v, loaded := m.Swap("a", 2); !loaded || v != 1 {
+		t.Fatalf("Swap(a) = %v, %v; want 1, true", v, loaded)
+	}
+	if ttl, ok := m.GetTTL("a"); !ok || ttl != cmap.NoExpiration {
+		t.Fatalf("GetTTL(a) = %v, %v after Swap; want NoExpiration, like Store", ttl, ok)
+	}
+	m.StoreWithTTL("b", 1, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if v, loaded := m.Swap("b", 2); loaded || v != nil {
+		t.Fatalf("Swap(b) = %v, %v over an expired value; want nil, false", v, loaded)
+	}
+}
@@ -0,0 +1,96 @@
+package cmap
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// FromMap returns a new map with the entries of src, sized for them. The
+// buckets are filled in parallel.
+func FromMap(src map[interface{}]interface{}) *CMap {
+	m := &CMap{seed: newSeed()}
+	B := m.sizeBit(len(src))
+	n := &node{
+		mask: bucketMask(B),
+		B:    B,
+		data: make([]unsafe.Pointer, bucketShift(B)),
+	}
+	type hashEntry struct {
+		Entry
+		hash uintptr
+	}
+	parts := make([][]hashEntry, bucketShift(B))
+	for k, v := range src {
+		hash := chash(k, m.seed)
+		parts[hash&n.mask] = append(parts[hash&n.mask], hashEntry{Entry{k, v}, hash})
+	}
+
+	var next uintptr
+	var wg sync.WaitGroup
+	for w := runtime.GOMAXPROCS(0); w > 0; w-- {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := atomic.AddUintptr(&next, 1) - 1; i <= n.mask; i = atomic.AddUintptr(&next, 1) - 1 {
+				b := n.newBucket()
+				b.reserve(len(parts[i]))
+				for _, e := range parts[i] {
+					b.store(e.Key, e.Value, e.hash)
+				}
+				b.count = int64(len(parts[i]))
+				atomic.StorePointer(&n.data[i], unsafe.Pointer(b))
+			}
+		}()
+	}
+	wg.Wait()
+	m.node = unsafe.Pointer(n)
+	return m
+}
+
+// ToMap returns a copy of the entries of m, taken at one instant like
+// RangeSnapshot.
+func (m *CMap) ToMap() map[interface{}]interface{} {
+	dst := make(map[interface{}]interface{}, m.Len())
+	m.RangeSnapshot(func(key, value interface{}) bool {
+		dst[key] = value
+		return true
+	})
+	return dst
+}
+
+// FromStringMap returns a new StringMap with the entries of src. The
+// shards are filled in parallel.
+func FromStringMap(src map[string]interface{}) *StringMap {
+	m := new(StringMap)
+	var parts [1 << sBit][]string
+	for k := range src {
+		i := shash(k) & (1<<sBit - 1)
+		parts[i] = append(parts[i], k)
+	}
+	var wg sync.WaitGroup
+	for i := range m.shards {
+		wg.Add(1)
+		go func(s *stringShard, keys []string) {
+			defer wg.Done()
+			s.m = make(map[string]*stringEntry, len(keys))
+			for _, k := range keys {
+				s.m[k] = &stringEntry{src[k]}
+			}
+		}(&m.shards[i], parts[i])
+	}
+	wg.Wait()
+	return m
+}
+
+// ToMap returns a copy of the entries of m. Like Range, it does not
+// correspond to any consistent snapshot of the map's contents.
+func (m *StringMap) ToMap() map[string]interface{} {
+	dst := make(map[string]interface{}, m.Len())
+	m.Range(func(key string, value interface{}) bool {
+		dst[key] = value
+		return true
+	})
+	return dst
+}
@@ -0,0 +1,39 @@
+package cmap_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestFromMapToMap(t *testing.T) {
+	src := make(map[interface{}]interface{})
+	for i := 0; i < 10000; i++ {
+		src[i] = strconv.Itoa(i)
+	}
+	m := cmap.FromMap(src)
+	if m.Len() != len(src) {
+		t.Fatalf("Len() = %d, want %d", m.Len(), len(src))
+	}
+	if v, ok := m.Load(1234); !ok || v != "1234" {
+		t.Fatalf("Load(1234) = %v, %v", v, ok)
+	}
+	m.Store(-1, "-1")
+	dst := m.ToMap()
+	if len(dst) != len(src)+1 || dst[-1] != "-1" || dst[9999] != "9999" {
+		t.Fatalf("ToMap() has %d entries", len(dst))
+	}
+
+	ssrc := make(map[string]interface{})
+	for i := 0; i < 1000; i++ {
+		ssrc[strconv.Itoa(i)] = i
+	}
+	sm := cmap.FromStringMap(ssrc)
+	if v, ok := sm.Load("42"); !ok || v != 42 || sm.Len() != len(ssrc) {
+		t.Fatalf("FromStringMap lost entries")
+	}
+	if sdst := sm.ToMap(); len(sdst) != len(ssrc) || sdst["999"] != 999 {
+		t.Fatalf("StringMap.ToMap() has %d entries", len(sdst))
+	}
+}
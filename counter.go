@@ -0,0 +1,72 @@
+package cmap
+
+// CounterMap is a concurrent map of int64 counters.
+//
+// The zero CounterMap is empty and ready for use. A CounterMap must not
+// be copied after first use.
+type CounterMap struct {
+	m CMap
+}
+
+// Add adds delta to the counter of key, which starts at 0, and returns
+// the new total.
+func (c *CounterMap) Add(key interface{}, delta int64) int64 {
+	v, _ := c.m.Update(key, func(value interface{}, _ bool) (interface{}, bool) {
+		n, _ := value.(int64)
+		return n + delta, false
+	})
+	return v.(int64)
+}
+
+// Load returns the counter of key, ok is false if key has no counter.
+func (c *CounterMap) Load(key interface{}) (n int64, ok bool) {
+	v, ok := c.m.Load(key)
+	if !ok {
+		return 0, false
+	}
+	return v.(int64), true
+}
+
+// Store sets the counter of key to n.
+func (c *CounterMap) Store(key interface{}, n int64) {
+	c.m.Store(key, n)
+}
+
+// LoadAndDelete deletes the counter of key, returning its value if any.
+// The loaded result reports whether key had a counter.
+func (c *CounterMap) LoadAndDelete(key interface{}) (n int64, loaded bool) {
+	v, loaded := c.m.LoadAndDelete(key)
+	if !loaded {
+		return 0, false
+	}
+	return v.(int64), true
+}
+
+// Delete deletes the counter of key.
+func (c *CounterMap) Delete(key interface{}) {
+	c.m.Delete(key)
+}
+
+// Len returns the number of counters.
+func (c *CounterMap) Len() int {
+	return c.m.Len()
+}
+
+// Range calls f sequentially for each key and counter present in the map.
+// If f returns false, range stops the iteration.
+func (c *CounterMap) Range(f func(key interface{}, n int64) bool) bool {
+	return c.m.Range(func(key, value interface{}) bool {
+		return f(key, value.(int64))
+	})
+}
+
+// Snapshot returns a copy of every counter, taken at one instant like
+// RangeSnapshot.
+func (c *CounterMap) Snapshot() map[interface{}]int64 {
+	s := make(map[interface{}]int64, c.m.Len())
+	c.m.RangeSnapshot(func(key, value interface{}) bool {
+		s[key] = value.(int64)
+		return true
+	})
+	return s
+}
@@ -0,0 +1,43 @@
+package cmap_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestCounterMap(t *testing.T) {
+	const goroutines, adds, keys = 8, 1000, 50
+	var c cmap.CounterMap
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < adds; i++ {
+				c.Add(i%keys, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if c.Len() != keys {
+		t.Fatalf("Len() = %d, want %d", c.Len(), keys)
+	}
+	s := c.Snapshot()
+	for k := 0; k < keys; k++ {
+		if s[k] != goroutines*adds/keys {
+			t.Fatalf("counter %d = %d, want %d", k, s[k], goroutines*adds/keys)
+		}
+	}
+	if n := c.Add(0, -5); n != goroutines*adds/keys-5 {
+		t.Fatalf("Add(0, -5) = %d", n)
+	}
+	if n, loaded := c.LoadAndDelete(0); !loaded || n != goroutines*adds/keys-5 {
+		t.Fatalf("LoadAndDelete(0) = %d, %v", n, loaded)
+	}
+	if n := c.Add(0, 3); n != 3 {
+		t.Fatalf("Add(0, 3) after delete = %d, want 3", n)
+	}
+}
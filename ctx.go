@@ -0,0 +1,34 @@
+package cmap
+
+import "context"
+
+// RangeCtx is like Range, but stops once ctx is done, checked between
+// buckets, and returns ctx.Err() then. The entries of a bucket are copied
+// before f is called on them, so f may use the map.
+func (m *CMap) RangeCtx(ctx context.Context, f func(key, value interface{}) bool) error {
+	_, err := m.rangeBucketsCtx(ctx, f)
+	return err
+}
+
+// RangeSnapshotCtx is like RangeSnapshot, but stops once ctx is done,
+// checked between buckets, and returns ctx.Err() then.
+func (m *CMap) RangeSnapshotCtx(ctx context.Context, f func(key, value interface{}) bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.Fork().RangeCtx(ctx, f)
+}
+
+// MergeCtx is like Merge, but stops once ctx is done, checked between the
+// buckets of src, and returns ctx.Err() then. The entries merged before
+// are left in m.
+func (m *CMap) MergeCtx(ctx context.Context, src *CMap, resolve func(key, dst, src interface{}) interface{}) error {
+	return m.merge(ctx, src, resolve)
+}
+
+// FilterCtx is like Filter, but stops once ctx is done, checked between
+// buckets, and returns ctx.Err() then, along with the number of entries
+// deleted before.
+func (m *CMap) FilterCtx(ctx context.Context, pred func(key, value interface{}) bool) (int, error) {
+	return m.filter(ctx, pred)
+}
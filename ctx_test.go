@@ -0,0 +1,48 @@
+package cmap_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestRangeCtx(t *testing.T) {
+	var m cmap.CMap
+	for i := 0; i < 1000; i++ {
+		m.Store(i, i)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n := 0
+	err := m.RangeCtx(ctx, func(key, value interface{}) bool {
+		if n++; n == 10 {
+			cancel()
+		}
+		return true
+	})
+	if err != context.Canceled || n >= 1000 {
+		t.Fatalf("RangeCtx() = %v after %d entries", err, n)
+	}
+	if err := m.RangeSnapshotCtx(ctx, func(key, value interface{}) bool { return true }); err != context.Canceled {
+		t.Fatalf("RangeSnapshotCtx() = %v", err)
+	}
+	var dst cmap.CMap
+	if err := dst.MergeCtx(ctx, &m, nil); err != context.Canceled || dst.Len() != 0 {
+		t.Fatalf("MergeCtx() = %v, merged %d", err, dst.Len())
+	}
+	if d, err := m.FilterCtx(ctx, func(key, value interface{}) bool { return false }); err != context.Canceled || d != 0 {
+		t.Fatalf("FilterCtx() = %d, %v", d, err)
+	}
+
+	n = 0
+	if err := m.RangeSnapshotCtx(context.Background(), func(key, value interface{}) bool {
+		n++
+		return true
+	}); err != nil || n != 1000 {
+		t.Fatalf("RangeSnapshotCtx() = %v after %d entries", err, n)
+	}
+	if err := dst.MergeCtx(context.Background(), &m, nil); err != nil || dst.Len() != 1000 {
+		t.Fatalf("MergeCtx() = %v, merged %d", err, dst.Len())
+	}
+}
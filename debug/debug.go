@@ -0,0 +1,129 @@
+// Package debug provides an http.Handler to inspect a cmap.CMap in a
+// running program.
+//
+//	http.Handle("/debug/cmap/sessions", &debug.Handler{Map: sessions, ListKeys: true})
+//
+// The page shows the stats of the map and a histogram of its bucket
+// sizes. With ?format=json it serves the cmap.Stats as JSON, and, if
+// enabled, ?keys=1&page=N lists the keys a page at a time.
+package debug
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gitee.com/absir_admin/cmap"
+)
+
+const (
+	defaultPageSize = 100
+	histogramBins   = 16
+	histogramWidth  = 60
+)
+
+// Handler serves the stats of Map over HTTP.
+type Handler struct {
+	Map *cmap.CMap
+
+	// ListKeys enables the key listing at ?keys=1.
+	ListKeys bool
+	// PageSize is the number of keys listed by page, 100 if zero.
+	PageSize int
+	// MaxListLen disables the key listing of maps holding more elements,
+	// zero means no limit.
+	MaxListLen int
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("keys") != "" {
+		h.serveKeys(w, q.Get("page"))
+		return
+	}
+	st := h.Map.Stats()
+	if q.Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(st)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	writeStats(w, st)
+}
+
+func (h *Handler) serveKeys(w http.ResponseWriter, page string) {
+	if !h.ListKeys {
+		http.Error(w, "key listing disabled", http.StatusForbidden)
+		return
+	}
+	n := h.Map.Len()
+	if h.MaxListLen > 0 && n > h.MaxListLen {
+		http.Error(w, fmt.Sprintf("map too large to list: %d > %d elements", n, h.MaxListLen), http.StatusForbidden)
+		return
+	}
+	p, err := strconv.Atoi(page)
+	if page != "" && (err != nil || p < 0) {
+		http.Error(w, "bad page", http.StatusBadRequest)
+		return
+	}
+	size := h.PageSize
+	if size <= 0 {
+		size = defaultPageSize
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	skip, listed := p*size, 0
+	h.Map.Range(func(key, _ interface{}) bool {
+		if skip > 0 {
+			skip--
+			return true
+		}
+		fmt.Fprintf(w, "%v\n", key)
+		listed++
+		return listed < size
+	})
+	if listed == size {
+		fmt.Fprintf(w, "\nnext: ?keys=1&page=%d\n", p+1)
+	}
+}
+
+func writeStats(w io.Writer, st cmap.Stats) {
+	fmt.Fprintf(w, "elements: %d\n", st.Len)
+	fmt.Fprintf(w, "buckets:  %d (B=%d)\n", len(st.Buckets), st.B)
+	fmt.Fprintf(w, "min/mean/max bucket: %d / %.2f / %d\n", st.Min, st.Mean, st.Max)
+	fmt.Fprintf(w, "stddev/p50/p99 bucket: %.2f / %d / %d, empty: %d\n", st.StdDev, st.P50, st.P99, st.Empty)
+	fmt.Fprintf(w, "hottest bucket: %d, %.1f%% of writes\n", st.HotBucket, st.HotShare*100)
+	fmt.Fprintf(w, "grows: %d, shrinks: %d, resizing: %v\n", st.Grows, st.Shrinks, st.Resizing)
+
+	fmt.Fprintf(w, "\nbucket sizes:\n")
+	lo, counts := histogram(st)
+	width := (st.Max - st.Min + histogramBins) / histogramBins
+	peak := 0
+	for _, c := range counts {
+		if c > peak {
+			peak = c
+		}
+	}
+	for i, c := range counts {
+		bar := 0
+		if peak > 0 {
+			bar = c * histogramWidth / peak
+		}
+		from := lo + i*width
+		fmt.Fprintf(w, "%8d-%-8d %8d %s\n", from, from+width-1, c, strings.Repeat("#", bar))
+	}
+}
+
+// histogram counts the buckets of st by size, in bins of equal width
+// starting at lo.
+func histogram(st cmap.Stats) (lo int, counts []int) {
+	width := (st.Max - st.Min + histogramBins) / histogramBins
+	counts = make([]int, (st.Max-st.Min)/width+1)
+	for _, c := range st.Buckets {
+		counts[(c-st.Min)/width]++
+	}
+	return st.Min, counts
+}
@@ -0,0 +1,53 @@
+package debug_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gitee.com/absir_admin/cmap"
+	"gitee.com/absir_admin/cmap/debug"
+)
+
+func get(h *debug.Handler, url string) (int, string) {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+	return rec.Code, rec.Body.String()
+}
+
+func TestHandler(t *testing.T) {
+	var m cmap.CMap
+	for i := 0; i < 250; i++ {
+		m.Store(i, i)
+	}
+	h := &debug.Handler{Map: &m}
+
+	code, body := get(h, "/")
+	if code != 200 || !strings.Contains(body, "elements: 250\n") || !strings.Contains(body, "bucket sizes:") {
+		t.Fatalf("GET / = %d:\n%s", code, body)
+	}
+
+	_, body = get(h, "/?format=json")
+	var st cmap.Stats
+	if err := json.Unmarshal([]byte(body), &st); err != nil || st.Len != 250 {
+		t.Fatalf("GET /?format=json = %v, %v", st, err)
+	}
+
+	if code, _ = get(h, "/?keys=1"); code != 403 {
+		t.Fatalf("key listing not disabled by default: %d", code)
+	}
+	h.ListKeys, h.PageSize = true, 100
+	seen := 0
+	for p := 0; p < 3; p++ {
+		_, body = get(h, "/?keys=1&page="+string(rune('0'+p)))
+		seen += len(strings.Fields(strings.Split(body, "\n\n")[0]))
+	}
+	if seen != 250 {
+		t.Fatalf("key listing showed %d keys, want 250", seen)
+	}
+	h.MaxListLen = 100
+	if code, _ = get(h, "/?keys=1"); code != 403 {
+		t.Fatalf("key listing not limited by MaxListLen: %d", code)
+	}
+}
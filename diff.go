@@ -0,0 +1,131 @@
+package cmap
+
+// Equal reports whether m and other hold the same keys, with values equal
+// by eq, or by == if eq is nil, values not comparable being different.
+//
+// Maps placing the keys in the same buckets, like a map and its Clone,
+// are compared bucket by bucket, without hashing the keys again. Like
+// Range, Equal does not correspond to any consistent snapshot of the
+// maps' contents.
+func (m *CMap) Equal(other *CMap, eq func(a, b interface{}) bool) bool {
+	if eq == nil {
+		eq = equal
+	}
+	same := true
+	if done := compareBuckets(m, other, func(key, a, b interface{}, inA, inB bool) bool {
+		same = inA && inB && eq(a, b)
+		return same
+	}); done || !same {
+		return same
+	}
+	// Len counts the keys expired, not removed yet
+	n := 0
+	ok := m.rangeBuckets(func(key, value interface{}) bool {
+		v, found := other.Load(key)
+		n++
+		return found && eq(value, v)
+	})
+	if !ok {
+		return false
+	}
+	other.RangeKeys(func(interface{}) bool {
+		n--
+		return n >= 0
+	})
+	return n == 0
+}
+
+// Diff returns the keys changed from m to other: the keys only in other,
+// the keys only in m, and the keys in both with values not equal by eq,
+// or by == if eq is nil, values not comparable being different.
+//
+// Like Equal, Diff compares bucket by bucket the maps placing the keys in
+// the same buckets. Like Range, Diff does not correspond to any consistent
+// snapshot of the maps' contents.
+func (m *CMap) Diff(other *CMap, eq func(a, b interface{}) bool) (added, removed, changed []interface{}) {
+	if eq == nil {
+		eq = equal
+	}
+	if compareBuckets(m, other, func(key, a, b interface{}, inA, inB bool) bool {
+		switch {
+		case !inA:
+			added = append(added, key)
+		case !inB:
+			removed = append(removed, key)
+		case !eq(a, b):
+			changed = append(changed, key)
+		}
+		return true
+	}) {
+		return added, removed, changed
+	}
+	added, removed, changed = nil, nil, nil
+	m.rangeBuckets(func(key, value interface{}) bool {
+		if v, ok := other.Load(key); !ok {
+			removed = append(removed, key)
+		} else if !eq(value, v) {
+			changed = append(changed, key)
+		}
+		return true
+	})
+	other.rangeBuckets(func(key, _ interface{}) bool {
+		if _, ok := m.Load(key); !ok {
+			added = append(added, key)
+		}
+		return true
+	})
+	return added, removed, changed
+}
+
+// compareBuckets calls f for each key live in m or other, with its value
+// in each, a and b, and whether it is in each, inA and inB, comparing the
+// bucket i of m with the bucket i of other. It stops once f returns false.
+//
+// It reports whether the comparison is done: false if the maps do not
+// place the keys in the same buckets, having different seeds, partitioners
+// or numbers of buckets, or if either was resized meanwhile.
+func compareBuckets(m, other *CMap, f func(key, a, b interface{}, inA, inB bool) bool) bool {
+	n, on := m.getNode(), other.getNode()
+	if m.seed != other.seed || m.part != nil || other.part != nil || n.B != on.B {
+		return false
+	}
+	for i := uintptr(0); i <= n.mask; i++ {
+		b, ob := n.loadBucket(i), on.loadBucket(i)
+		ok := b.rangeHash(func(key, raw interface{}, hash uintptr) bool {
+			a, inA := unwrap(raw)
+			if !inA {
+				return true
+			}
+			v, inB := ob.load(key, hash)
+			if inB {
+				v, inB = unwrap(v)
+			}
+			return f(key, a, v, true, inB)
+		}) && ob.rangeHash(func(key, raw interface{}, hash uintptr) bool {
+			v, inB := unwrap(raw)
+			if !inB {
+				return true
+			}
+			if a, inA := b.load(key, hash); inA {
+				if _, inA = unwrap(a); inA {
+					return true
+				}
+			}
+			return f(key, nil, v, false, true)
+		})
+		if !ok {
+			break
+		}
+	}
+	return m.getNode() == n && other.getNode() == on
+}
+
+// equal is ==, values not comparable being different.
+func equal(a, b interface{}) (eq bool) {
+	defer func() {
+		if recover() != nil {
+			eq = false
+		}
+	}()
+	return a == b
+}
@@ -0,0 +1,123 @@
+package cmap_test
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/min1324/cmap"
+)
+
+func TestEqualDiff(t *testing.T) {
+	var a, b cmap.CMap
+	for i := 0; i < 100; i++ {
+		a.Store(i, i)
+		b.Store(i, i)
+	}
+	if !a.Equal(&b, nil) {
+		t.Fatalf("equal maps are not Equal")
+	}
+	added, removed, changed := a.Diff(&b, nil)
+	if len(added)+len(removed)+len(changed) != 0 {
+		t.Fatalf("Diff of equal maps = %v, %v, %v", added, removed, changed)
+	}
+
+	a.Delete(1)
+	b.Store(2, -2)
+	b.Delete(3)
+	if a.Equal(&b, nil) {
+		t.Fatalf("different maps are Equal")
+	}
+	added, removed, changed = a.Diff(&b, nil)
+	if len(added) != 1 || added[0] != 1 {
+		t.Fatalf("added = %v, want [1]", added)
+	}
+	if len(removed) != 1 || removed[0] != 3 {
+		t.Fatalf("removed = %v, want [3]", removed)
+	}
+	if len(changed) != 1 || changed[0] != 2 {
+		t.Fatalf("changed = %v, want [2]", changed)
+	}
+
+	abs := func(a, b interface{}) bool {
+		x, y := a.(int), b.(int)
+		return x == y || x == -y
+	}
+	a.Store(1, 1)
+	a.Delete(3)
+	if !a.Equal(&b, abs) {
+		t.Fatalf("Equal ignored eq")
+	}
+}
+
+func TestDiffUncomparable(t *testing.T) {
+	a, b := cmap.New(), cmap.New()
+	a.Store("s", []int{1})
+	b.Store("s", []int{1})
+	a.Store("i", 1)
+	b.Store("i", 1)
+	if a.Equal(b, nil) {
+		t.Fatalf("maps of slices are Equal by ==")
+	}
+	_, _, changed := a.Diff(b, nil)
+	if len(changed) != 1 || changed[0] != "s" {
+		t.Fatalf("changed = %v, want [s]", changed)
+	}
+	eq := func(x, y interface{}) bool {
+		if s, ok := x.([]int); ok {
+			return reflect.DeepEqual(s, y)
+		}
+		return x == y
+	}
+	if !a.Equal(b, eq) {
+		t.Fatalf("Equal ignored eq")
+	}
+	if added, removed, changed := a.Diff(b, eq); len(added)+len(removed)+len(changed) != 0 {
+		t.Fatalf("Diff ignored eq: %v, %v, %v", added, removed, changed)
+	}
+}
+
+func TestDiffBuckets(t *testing.T) {
+	// a and its clone compare bucket by bucket, a and c key by key
+	a := cmap.New()
+	for i := 0; i < 1000; i++ {
+		a.Store(i, i)
+	}
+	b := a.Clone()
+	c := cmap.New()
+	a.Range(func(key, value interface{}) bool {
+		c.Store(key, value)
+		return true
+	})
+	for i := 0; i < 1000; i += 10 {
+		b.Store(i, -i)
+		c.Store(i, -i)
+		b.Delete(i + 1)
+		c.Delete(i + 1)
+		b.Store(-i-1, i)
+		c.Store(-i-1, i)
+		b.StoreWithTTL(i+2, i+2, time.Nanosecond)
+		c.StoreWithTTL(i+2, i+2, time.Nanosecond)
+	}
+	time.Sleep(time.Millisecond)
+	if a.Equal(b, nil) || a.Equal(c, nil) || !b.Equal(c, nil) || !c.Equal(b, nil) {
+		t.Fatalf("Equal of a, b, c is wrong")
+	}
+	sorted := func(keys []interface{}) []int {
+		s := make([]int, len(keys))
+		for i, k := range keys {
+			s[i] = k.(int)
+		}
+		sort.Ints(s)
+		return s
+	}
+	ab1, ab2, ab3 := a.Diff(b, nil)
+	ac1, ac2, ac3 := a.Diff(c, nil)
+	for i, d := range [][2][]interface{}{{ab1, ac1}, {ab2, ac2}, {ab3, ac3}} {
+		x, y := sorted(d[0]), sorted(d[1])
+		if len(x) == 0 || !reflect.DeepEqual(x, y) {
+			t.Fatalf("Diff %d of a clone = %v, of a copy = %v", i, x, y)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package cmap
+
+import "reflect"
+
+// StoreIfGreater stores value for key if key is absent, or if value is
+// greater than the value of key, and reports whether it stored value, as
+// one atomic step: concurrent calls keep the greatest value, for high-water
+// marks.
+//
+// The values must be of the same type, of a kind ordered by <: integers,
+// floats and strings. StoreIfGreater panics with a *TypeError otherwise.
+// Like Update, a ttl of the value is kept.
+func (m *CMap) StoreIfGreater(key, value interface{}) bool {
+	return m.storeIfGreater(key, value, orderedLess)
+}
+
+// StoreIfLess is like StoreIfGreater, but keeps the least value.
+func (m *CMap) StoreIfLess(key, value interface{}) bool {
+	return m.storeIfGreater(key, value, func(a, b interface{}) (less, ok bool) {
+		return orderedLess(b, a)
+	})
+}
+
+// StoreIfGreaterFunc is like StoreIfGreater, with the values ordered by
+// less, which is called with the bucket of key locked, so it must not use
+// the map.
+func (m *CMap) StoreIfGreaterFunc(key, value interface{}, less func(a, b interface{}) bool) bool {
+	return m.storeIfGreater(key, value, func(a, b interface{}) (bool, bool) {
+		return less(a, b), true
+	})
+}
+
+func (m *CMap) storeIfGreater(key, value interface{}, less func(a, b interface{}) (less, ok bool)) bool {
+	stored := false
+	var err error
+	m.compute(key, func(cur interface{}, loaded bool) (interface{}, action) {
+		if loaded {
+			lt, ok := less(cur, value)
+			if !ok {
+				// panics once the bucket is unlocked
+				err = &TypeError{Key: key, Value: cur, Want: reflect.TypeOf(value)}
+				return nil, actKeep
+			}
+			if !lt {
+				return nil, actKeep
+			}
+		}
+		stored = true
+		return value, actStore
+	})
+	if err != nil {
+		panic(err)
+	}
+	return stored
+}
+
+// orderedLess reports whether a < b. ok is false unless a and b are of the
+// same type, of an ordered kind.
+func orderedLess(a, b interface{}) (less, ok bool) {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !va.IsValid() || !vb.IsValid() || va.Type() != vb.Type() {
+		return false, false
+	}
+	switch k := va.Kind(); {
+	case isInt(k):
+		return va.Int() < vb.Int(), true
+	case isUint(k):
+		return va.Uint() < vb.Uint(), true
+	case isFloat(k):
+		return va.Float() < vb.Float(), true
+	case k == reflect.String:
+		return va.String() < vb.String(), true
+	}
+	return false, false
+}
@@ -0,0 +1,49 @@
+package cmap_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestStoreIfGreater(t *testing.T) {
+	var m cmap.CMap
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				v := int64((i*8 + g) % 5000)
+				m.StoreIfGreater("max", v)
+				m.StoreIfLess("min", v)
+			}
+		}(g)
+	}
+	wg.Wait()
+	if v, _ := m.Load("max"); v != int64(4999) {
+		t.Fatalf("max = %v, want 4999", v)
+	}
+	if v, _ := m.Load("min"); v != int64(0) {
+		t.Fatalf("min = %v, want 0", v)
+	}
+	if m.StoreIfGreater("max", int64(4999)) {
+		t.Fatalf("StoreIfGreater stored an equal value")
+	}
+
+	byLen := func(a, b interface{}) bool { return len(a.([]int)) < len(b.([]int)) }
+	if !m.StoreIfGreaterFunc("slice", []int{1}, byLen) || m.StoreIfGreaterFunc("slice", []int{2}, byLen) {
+		t.Fatalf("StoreIfGreaterFunc did not order by length")
+	}
+
+	defer func() {
+		var te *cmap.TypeError
+		if err, _ := recover().(error); !errors.As(err, &te) {
+			t.Fatalf("StoreIfGreater of mixed types panicked with %v, want a TypeError", err)
+		}
+		m.Store("max", 0)
+	}()
+	m.StoreIfGreater("max", 1)
+}
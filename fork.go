@@ -0,0 +1,59 @@
+package cmap
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// Fork returns a copy of the map at one instant, like RangeSnapshot, which
+// shares the buckets of m until they are written: a bucket shared by the
+// two maps is never changed, and the first write to it in either map
+// copies it for that map. Forking costs one pointer per bucket, however
+// large the map, so it suits point-in-time exports of large maps.
+//
+// Like Clone, keys keep their ttl, and options, callbacks and watchers are
+// not copied. Until a bucket is copied, the loads of its keys with an idle
+// ttl or metadata count as accesses in both maps.
+func (m *CMap) Fork() *CMap {
+	n := m.lockAll()
+	nn := &node{
+		mask:  n.mask,
+		B:     n.B,
+		data:  make([]unsafe.Pointer, n.mask+1),
+		swiss: n.swiss,
+	}
+	for i := uintptr(0); i <= n.mask; i++ {
+		b := n.getBucket(i)
+		atomic.StoreUint32(&b.shared, 1)
+		debugFreeze(b)
+		nn.data[i] = unsafe.Pointer(b)
+	}
+	n.unlockAll()
+	return &CMap{node: unsafe.Pointer(nn), maxB: m.maxB, swiss: m.swiss, seed: m.seed, part: m.part}
+}
+
+// unshare replaces the shared bucket b of hash in n by a copy, which only
+// n uses. Like Compact, the copy is published in place of b with b
+// locked, and writers of b find it is no longer current once they lock
+// it. b must not be locked by the caller.
+func (n *node) unshare(hash uintptr, b *bucket) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if n.getBucket(hash) != b {
+		// unshared meanwhile
+		return
+	}
+	debugFrozen(b)
+	nb := n.newBucket()
+	nb.reserve(int(atomic.LoadInt64(&b.count)))
+	b.rangeHash(func(key, value interface{}, hash uintptr) bool {
+		if e, ok := value.(*expiring); ok {
+			// Load changes the access time of e
+			value = e.with(e.value)
+		}
+		nb.store(key, value, hash)
+		return true
+	})
+	nb.count = atomic.LoadInt64(&b.count)
+	atomic.StorePointer(&n.data[hash&n.mask], unsafe.Pointer(nb))
+}
@@ -0,0 +1,100 @@
+package cmap_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/min1324/cmap"
+)
+
+func TestFork(t *testing.T) {
+	for _, opts := range [][]cmap.Option{nil, {cmap.WithSwissBuckets()}} {
+		m := cmap.New(opts...)
+		for i := 0; i < 1000; i++ {
+			m.Store(i, i)
+		}
+		m.StoreWithTTL("ttl", 0, time.Hour)
+		f := m.Fork()
+
+		m.Store(0, -1)
+		m.Delete(1)
+		f.Store(2, -2)
+		f.Update(3, func(interface{}, bool) (interface{}, bool) { return nil, true })
+		m.Store("m", 1)
+
+		if v, _ := f.Load(0); v != 0 {
+			t.Fatalf("fork sees a store of its parent: %v", v)
+		}
+		if _, ok := f.Load(1); !ok {
+			t.Fatalf("fork sees a delete of its parent")
+		}
+		if v, _ := m.Load(2); v != 2 {
+			t.Fatalf("parent sees a store of its fork: %v", v)
+		}
+		if _, ok := m.Load(3); !ok {
+			t.Fatalf("parent sees a delete of its fork")
+		}
+		if ttl, ok := f.GetTTL("ttl"); !ok || ttl == cmap.NoExpiration {
+			t.Fatalf("fork lost the ttl of a key")
+		}
+		if m.Len() != 1001 || f.Len() != 1000 {
+			t.Fatalf("Len() = %d, %d; want 1001, 1000", m.Len(), f.Len())
+		}
+		for i := 0; i < 2000; i++ {
+			m.Store(i, i)
+		}
+		if f.Len() != 1000 {
+			t.Fatalf("fork Len() = %d after its parent grew, want 1000", f.Len())
+		}
+	}
+}
+
+func TestForkConsistent(t *testing.T) {
+	var m cmap.CMap
+	const keys = 64
+	for i := 0; i < keys; i++ {
+		m.Store(i, 100)
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				// move one unit between two keys, keeping the sum
+				a, b := (g*7+i)%keys, (g*13+i*3+1)%keys
+				if a == b {
+					continue
+				}
+				m.DoAtomic([]interface{}{a, b}, func(tx cmap.TxView) error {
+					va, _ := tx.Get(a)
+					vb, _ := tx.Get(b)
+					tx.Set(a, va.(int)-1)
+					tx.Set(b, vb.(int)+1)
+					return nil
+				})
+			}
+		}(g)
+	}
+	for i := 0; i < 100; i++ {
+		f := m.Fork()
+		sum := 0
+		f.Range(func(_, v interface{}) bool {
+			sum += v.(int)
+			return true
+		})
+		if sum != keys*100 {
+			t.Errorf("fork %d has a sum of %d, want %d", i, sum, keys*100)
+		}
+		f.Store(i%keys, 0)
+	}
+	close(done)
+	wg.Wait()
+}
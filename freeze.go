@@ -0,0 +1,70 @@
+package cmap
+
+// ReadOnlyMap is an immutable copy of a CMap, made by Freeze. Its reads
+// are plain Go map lookups, without any lock or atomic operation.
+//
+// The zero ReadOnlyMap is empty.
+type ReadOnlyMap struct {
+	m   map[interface{}]interface{}
+	ttl int // # of values with a ttl
+}
+
+// Freeze returns an immutable copy of the map at one instant, like
+// RangeSnapshot. The map itself is unchanged and still writable, later
+// writes are not seen by the copy. Keys keep their ttl.
+func (m *CMap) Freeze() ReadOnlyMap {
+	n := m.lockAll()
+	r := ReadOnlyMap{m: make(map[interface{}]interface{}, n.count())}
+	now := nanotime()
+	for i := uintptr(0); i <= n.mask; i++ {
+		n.getBucket(i).rangeHash(func(key, value interface{}, _ uintptr) bool {
+			if _, ok := value.(*expiring); ok {
+				if isExpired(value, now) {
+					return true
+				}
+				r.ttl++
+			}
+			r.m[key] = value
+			return true
+		})
+	}
+	n.unlockAll()
+	return r
+}
+
+// Load returns the value stored in the map for a key, or nil if no
+// value is present.
+// The ok result indicates whether value was found in the map.
+func (r ReadOnlyMap) Load(key interface{}) (value interface{}, ok bool) {
+	value, ok = r.m[key]
+	if ok && r.ttl > 0 {
+		value, ok = unwrap(value)
+	}
+	return value, ok
+}
+
+// Len returns the number of elements within the map.
+func (r ReadOnlyMap) Len() int {
+	if r.ttl == 0 {
+		return len(r.m)
+	}
+	n := 0
+	now := nanotime()
+	for _, v := range r.m {
+		if !isExpired(v, now) {
+			n++
+		}
+	}
+	return n
+}
+
+// Range calls f sequentially for each key and value present in the map.
+// If f returns false, range stops the iteration.
+func (r ReadOnlyMap) Range(f func(key, value interface{}) bool) bool {
+	for k, v := range r.m {
+		if v, ok := unwrap(v); ok && !f(k, v) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,47 @@
+package cmap_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/min1324/cmap"
+)
+
+func TestFreeze(t *testing.T) {
+	var m cmap.CMap
+	for i := 0; i < 100; i++ {
+		m.Store(i, i)
+	}
+	m.StoreWithTTL("short", 1, time.Millisecond)
+
+	r := m.Freeze()
+	m.Store(100, 100)
+	m.Delete(0)
+	if r.Len() != 101 {
+		t.Fatalf("Len() = %d, want 101", r.Len())
+	}
+	if v, ok := r.Load(0); !ok || v != 0 {
+		t.Fatalf("Load(0) = %v, %v", v, ok)
+	}
+	if _, ok := r.Load(100); ok {
+		t.Fatalf("Freeze saw a later Store")
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	if _, ok := r.Load("short"); ok || r.Len() != 100 {
+		t.Fatalf("an expired key is still present")
+	}
+	n := 0
+	r.Range(func(key, value interface{}) bool {
+		n++
+		return true
+	})
+	if n != 100 {
+		t.Fatalf("Range saw %d keys, want 100", n)
+	}
+
+	var zero cmap.ReadOnlyMap
+	if _, ok := zero.Load(1); ok || zero.Len() != 0 {
+		t.Fatalf("zero ReadOnlyMap is not empty")
+	}
+}
@@ -0,0 +1,79 @@
+package cmap
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Filter deletes the entries for which pred returns false, and returns
+// the number of entries deleted. The buckets are filtered in parallel,
+// and pred is called with the bucket of the key locked, like Update, so
+// it must be safe for concurrent use and must not use the map.
+//
+// Entries stored while Filter is running may be missed.
+func (m *CMap) Filter(pred func(key, value interface{}) bool) int {
+	n, _ := m.filter(nil, pred)
+	return n
+}
+
+func (m *CMap) filter(ctx context.Context, pred func(key, value interface{}) bool) (int, error) {
+	var deleted int64
+	err := m.eachKey(ctx, func(key interface{}) {
+		m.compute(key, func(value interface{}, loaded bool) (interface{}, action) {
+			if !loaded || pred(key, value) {
+				return nil, actKeep
+			}
+			atomic.AddInt64(&deleted, 1)
+			return nil, actDelete
+		})
+	})
+	return int(deleted), err
+}
+
+// Transform replaces each value by fn(key, value). The buckets are
+// transformed in parallel, and fn is called with the bucket of the key
+// locked, like Update, so it must be safe for concurrent use and must not
+// use the map.
+//
+// Entries stored while Transform is running may be missed.
+func (m *CMap) Transform(fn func(key, value interface{}) interface{}) {
+	m.eachKey(nil, func(key interface{}) {
+		m.compute(key, func(value interface{}, loaded bool) (interface{}, action) {
+			if !loaded {
+				return nil, actKeep
+			}
+			return fn(key, value), actStore
+		})
+	})
+}
+
+// Reduce folds the entries of the map into an accumulator starting at
+// init, calling fn sequentially like Range, and returns the result.
+func (m *CMap) Reduce(init interface{}, fn func(acc, key, value interface{}) interface{}) interface{} {
+	acc := init
+	m.rangeBuckets(func(key, value interface{}) bool {
+		acc = fn(acc, key, value)
+		return true
+	})
+	return acc
+}
+
+// eachKey calls f with each key of the map, from GOMAXPROCS goroutines
+// taking one bucket at a time, whose keys are copied before f is called.
+// Once ctx is done, the remaining buckets are skipped and ctx.Err() is
+// returned. ctx may be nil.
+func (m *CMap) eachKey(ctx context.Context, f func(key interface{})) error {
+	n := m.getNode()
+	n.parallel(0, func(i uintptr) {
+		if ctx != nil && ctx.Err() != nil {
+			return
+		}
+		for _, k := range n.loadBucket(i).appendKeys(nil) {
+			f(k)
+		}
+	})
+	if ctx != nil {
+		return ctx.Err()
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package cmap_test
+
+import (
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestFilterTransformReduce(t *testing.T) {
+	var m cmap.CMap
+	for i := 0; i < 1000; i++ {
+		m.Store(i, i)
+	}
+	n := m.Filter(func(key, value interface{}) bool {
+		return value.(int)%2 == 0
+	})
+	if n != 500 || m.Len() != 500 {
+		t.Fatalf("Filter deleted %d, Len() = %d, want 500", n, m.Len())
+	}
+	if _, ok := m.Load(1); ok {
+		t.Fatalf("Filter kept an odd value")
+	}
+
+	m.Transform(func(key, value interface{}) interface{} {
+		return value.(int) * 10
+	})
+	if v, _ := m.Load(42); v != 420 {
+		t.Fatalf("Load(42) after Transform = %v, want 420", v)
+	}
+
+	sum := m.Reduce(0, func(acc, key, value interface{}) interface{} {
+		return acc.(int) + value.(int)
+	})
+	if sum != 2495000 {
+		t.Fatalf("Reduce() = %v, want 2495000", sum)
+	}
+}
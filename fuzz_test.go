@@ -0,0 +1,204 @@
+//go:build go1.18
+
+package cmap_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+// fuzz ops, decoded from 3 bytes each: op, key, value
+const (
+	fuzzStore = iota
+	fuzzLoad
+	fuzzLoadOrStore
+	fuzzLoadAndDelete
+	fuzzDelete
+	fuzzUpdate
+	fuzzForceResize
+	fuzzShrinkTo
+	fuzzWaitResize
+	fuzzCompact
+	fuzzRange
+	fuzzFilter
+	fuzzTransform
+	fuzzDoAtomic
+	numFuzzOps
+)
+
+// fuzzClients run the ops of a fuzz input: op i is run by client
+// i%fuzzClients, on keys of its own, so that each client can check its
+// keys against its own reference map while the others write theirs and
+// resize the map concurrently.
+const fuzzClients = 2
+
+// FuzzOps runs operation sequences decoded from the fuzz input on a CMap,
+// and checks them against a reference map.
+func FuzzOps(f *testing.F) {
+	f.Add([]byte{0, fuzzStore, 1, 1, fuzzStore, 2, 2, fuzzLoad, 1, 0, fuzzRange, 0, 0})
+	f.Add([]byte{1, fuzzStore, 1, 1, fuzzForceResize, 3, 0, fuzzDelete, 1, 0, fuzzShrinkTo, 0, 0, fuzzLoad, 1, 0})
+	f.Add([]byte{2, fuzzLoadOrStore, 7, 1, fuzzUpdate, 7, 2, fuzzFilter, 2, 0, fuzzTransform, 0, 0, fuzzDoAtomic, 7, 8})
+	seq := []byte{3}
+	for i := 0; i < 200; i++ {
+		seq = append(seq, fuzzStore, byte(i), byte(i))
+	}
+	for i := 0; i < 200; i++ {
+		seq = append(seq, fuzzLoadAndDelete, byte(i), 0)
+	}
+	f.Add(append(seq, fuzzWaitResize, 0, 0, fuzzCompact, 0, 0, fuzzRange, 0, 0))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) == 0 {
+			return
+		}
+		var opts []cmap.Option
+		if data[0]&1 != 0 {
+			opts = append(opts, cmap.WithSwissBuckets())
+		}
+		if data[0]&2 != 0 {
+			// resize as often as possible
+			opts = append(opts, cmap.WithLoadFactor(0.01, 0.005))
+		}
+		m := cmap.New(opts...)
+		data = data[1:]
+
+		refs := make([]map[int]int, fuzzClients)
+		var wg sync.WaitGroup
+		for c := range refs {
+			refs[c] = make(map[int]int)
+			wg.Add(1)
+			go func(c int) {
+				defer wg.Done()
+				fuzzClient(t, m, refs[c], c, data)
+			}(c)
+		}
+		wg.Wait()
+
+		want := make(map[interface{}]interface{})
+		for _, ref := range refs {
+			for k, v := range ref {
+				want[k] = v
+			}
+		}
+		if got := m.ToMap(); len(got) != len(want) || m.Len() != len(want) {
+			t.Fatalf("map has %d entries, Len() = %d, want %d", len(got), m.Len(), len(want))
+		}
+		m.Range(func(key, value interface{}) bool {
+			if want[key] != value {
+				t.Fatalf("Range: %v = %v, want %v", key, value, want[key])
+			}
+			return true
+		})
+	})
+}
+
+// fuzzClient runs the ops of client c in data on m, checking the keys of c
+// against ref.
+func fuzzClient(t *testing.T, m *cmap.CMap, ref map[int]int, c int, data []byte) {
+	mine := func(key interface{}) bool { return key.(int)%fuzzClients == c }
+	for i := 3 * c; i+2 < len(data); i += 3 * fuzzClients {
+		op, key, value := data[i]%numFuzzOps, int(data[i+1])*fuzzClients+c, int(data[i+2])
+		want, wantOK := ref[key]
+		switch op {
+		case fuzzStore:
+			m.Store(key, value)
+			ref[key] = value
+		case fuzzLoad:
+			if v, ok := m.Load(key); ok != wantOK || ok && v != want {
+				t.Errorf("Load(%d) = %v, %v; want %v, %v", key, v, ok, want, wantOK)
+			}
+		case fuzzLoadOrStore:
+			v, loaded := m.LoadOrStore(key, value)
+			if !wantOK {
+				want = value
+				ref[key] = value
+			}
+			if loaded != wantOK || v != want {
+				t.Errorf("LoadOrStore(%d, %d) = %v, %v; want %v, %v", key, value, v, loaded, want, wantOK)
+			}
+		case fuzzLoadAndDelete:
+			if v, ok := m.LoadAndDelete(key); ok != wantOK || ok && v != want {
+				t.Errorf("LoadAndDelete(%d) = %v, %v; want %v, %v", key, v, ok, want, wantOK)
+			}
+			delete(ref, key)
+		case fuzzDelete:
+			m.Delete(key)
+			delete(ref, key)
+		case fuzzUpdate:
+			// deletes even values, adds value to the others
+			m.Update(key, func(v interface{}, loaded bool) (interface{}, bool) {
+				if !loaded {
+					return value, false
+				}
+				return v.(int) + value, v.(int)%2 == 0
+			})
+			switch {
+			case !wantOK:
+				ref[key] = value
+			case want%2 == 0:
+				delete(ref, key)
+			default:
+				ref[key] = want + value
+			}
+		case fuzzForceResize:
+			m.ForceResize(uint8(4 + value%8))
+		case fuzzShrinkTo:
+			m.ShrinkTo(uint8(4 + value%8))
+		case fuzzWaitResize:
+			m.WaitResize(context.Background())
+		case fuzzCompact:
+			m.Compact()
+		case fuzzRange:
+			seen := 0
+			m.Range(func(k, v interface{}) bool {
+				if !mine(k) {
+					return true
+				}
+				seen++
+				if w, ok := ref[k.(int)]; !ok || v != w {
+					t.Errorf("Range: %v = %v, want %v, %v", k, v, w, ok)
+				}
+				return true
+			})
+			if seen != len(ref) {
+				t.Errorf("Range saw %d keys of client %d, want %d", seen, c, len(ref))
+			}
+		case fuzzFilter:
+			// keeps the values multiple of value+1
+			keep := func(v int) bool { return v%(value+1) == 0 }
+			m.Filter(func(k, v interface{}) bool { return !mine(k) || keep(v.(int)) })
+			for k, v := range ref {
+				if !keep(v) {
+					delete(ref, k)
+				}
+			}
+		case fuzzTransform:
+			m.Transform(func(k, v interface{}) interface{} {
+				if !mine(k) {
+					return v
+				}
+				return v.(int) + 1
+			})
+			for k := range ref {
+				ref[k]++
+			}
+		case fuzzDoAtomic:
+			// moves the value of key to other
+			other := value*fuzzClients + c
+			m.DoAtomic([]interface{}{key, other}, func(view cmap.TxView) error {
+				if v, ok := view.Get(key); ok {
+					view.Delete(key)
+					view.Set(other, v)
+				}
+				return nil
+			})
+			if wantOK {
+				delete(ref, key)
+				ref[other] = want
+			}
+		}
+	}
+}
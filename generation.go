@@ -0,0 +1,19 @@
+package cmap
+
+import "sync/atomic"
+
+// Generation returns the generation of the map, which starts at 0 and is
+// incremented by Invalidate, Restore and Close, the changes of the whole
+// map, not by the writes of single keys, RestoreEntry included: a
+// cache built from the map can be dropped at once when the generation it
+// was built at is no longer current, rather than compared to the map.
+func (m *CMap) Generation() uint64 {
+	return atomic.LoadUint64(&m.gen)
+}
+
+// Invalidate increments the generation of the map, to tell the users of
+// Generation that every entry may have changed, and returns the new
+// generation. The entries are unchanged.
+func (m *CMap) Invalidate() uint64 {
+	return atomic.AddUint64(&m.gen, 1)
+}
@@ -0,0 +1,50 @@
+package cmap_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestGeneration(t *testing.T) {
+	var m cmap.CMap
+	if g := m.Generation(); g != 0 {
+		t.Fatalf("Generation() = %d for a new map, want 0", g)
+	}
+	m.Store("a", 1)
+	m.Delete("a")
+	if g := m.Generation(); g != 0 {
+		t.Fatalf("Generation() = %d after writes of single keys, want 0", g)
+	}
+	if g := m.Invalidate(); g != 1 || m.Generation() != 1 {
+		t.Fatalf("Invalidate() = %d, Generation() = %d; want 1, 1", g, m.Generation())
+	}
+	m.Close()
+	if g := m.Generation(); g != 2 {
+		t.Fatalf("Generation() = %d after Close, want 2", g)
+	}
+}
+
+func TestGenerationRestore(t *testing.T) {
+	var m, r cmap.CMap
+	m.Store("a", 1)
+	var buf bytes.Buffer
+	if err := m.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if err := r.Restore(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if g := r.Generation(); g != 1 {
+		t.Fatalf("Generation() = %d after Restore, want 1", g)
+	}
+	// entries may be stored before the error
+	if err := r.Restore(bytes.NewReader(data[:len(data)-1])); err == nil {
+		t.Fatal("Restore of a truncated snapshot succeeded")
+	}
+	if g := r.Generation(); g != 2 {
+		t.Fatalf("Generation() = %d after a failed Restore, want 2", g)
+	}
+}
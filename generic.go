@@ -0,0 +1,299 @@
+//go:build go1.18
+
+package cmap
+
+import "sync"
+
+// The types of this file are typed versions of Set, Cache and CounterMap,
+// for Go 1.18 and later, which check the types of keys and values at
+// compile time. TypedSet and TypedCounterMap keep their keys in shards of
+// plain maps, like StringMap, so that keys are never boxed into interfaces
+// and their operations only allocate to grow a shard. TypedCache is a
+// typed front of a Cache, whose keys and values are still boxed.
+
+// TypedSet is a concurrent set of elements of type T.
+//
+// The zero TypedSet is empty and ready for use. A TypedSet must not be
+// copied after first use.
+type TypedSet[T comparable] struct {
+	m typedMap[T, struct{}]
+}
+
+// Add adds v to the set, and reports whether it was missing.
+func (s *TypedSet[T]) Add(v T) bool {
+	sh := s.m.getShard(v)
+	sh.mu.Lock()
+	_, found := sh.m[v]
+	if !found {
+		sh.set(v, struct{}{})
+	}
+	sh.mu.Unlock()
+	return !found
+}
+
+// Remove removes v from the set, and reports whether it was present.
+func (s *TypedSet[T]) Remove(v T) bool {
+	_, loaded := s.m.loadAndDelete(v)
+	return loaded
+}
+
+// Contains reports whether v is in the set.
+func (s *TypedSet[T]) Contains(v T) bool {
+	_, ok := s.m.load(v)
+	return ok
+}
+
+// Len returns the number of elements in the set.
+func (s *TypedSet[T]) Len() int {
+	return s.m.len()
+}
+
+// Range calls f sequentially for each element present in the set.
+// If f returns false, range stops the iteration.
+func (s *TypedSet[T]) Range(f func(v T) bool) bool {
+	return s.m.rangeShards(func(v T, _ struct{}) bool {
+		return f(v)
+	})
+}
+
+// Union returns a new set of the elements of s or o, see Set.Union.
+func (s *TypedSet[T]) Union(o *TypedSet[T]) *TypedSet[T] {
+	r := new(TypedSet[T])
+	r.addAll(s, nil)
+	r.addAll(o, nil)
+	return r
+}
+
+// Intersect returns a new set of the elements of both s and o.
+func (s *TypedSet[T]) Intersect(o *TypedSet[T]) *TypedSet[T] {
+	r := new(TypedSet[T])
+	a, b := s, o
+	if b.Len() < a.Len() {
+		a, b = b, a
+	}
+	r.addAll(a, b.Contains)
+	return r
+}
+
+// Difference returns a new set of the elements of s not in o.
+func (s *TypedSet[T]) Difference(o *TypedSet[T]) *TypedSet[T] {
+	r := new(TypedSet[T])
+	r.addAll(s, func(v T) bool { return !o.Contains(v) })
+	return r
+}
+
+// addAll adds the elements of o for which keep returns true, or all of
+// them if keep is nil.
+func (s *TypedSet[T]) addAll(o *TypedSet[T], keep func(v T) bool) {
+	o.Range(func(v T) bool {
+		if keep == nil || keep(v) {
+			s.Add(v)
+		}
+		return true
+	})
+}
+
+// TypedCache is a Cache of values of type V by keys of type K. Unlike
+// TypedSet, it keeps the storage of Cache: keys and values are boxed.
+type TypedCache[K comparable, V any] struct {
+	c *Cache
+}
+
+// NewTypedCache returns an empty TypedCache, see NewCache.
+func NewTypedCache[K comparable, V any](maxEntries int, opts ...CacheOption) *TypedCache[K, V] {
+	return &TypedCache[K, V]{c: NewCache(maxEntries, opts...)}
+}
+
+// Get returns the value stored in the cache for a key and marks it as
+// the most recently used.
+// The ok result indicates whether value was found in the cache.
+func (c *TypedCache[K, V]) Get(key K) (value V, ok bool) {
+	v, ok := c.c.Get(key)
+	if ok {
+		value = v.(V)
+	}
+	return value, ok
+}
+
+// Peek returns the value stored in the cache for a key without updating
+// its recency.
+func (c *TypedCache[K, V]) Peek(key K) (value V, ok bool) {
+	v, ok := c.c.Peek(key)
+	if ok {
+		value = v.(V)
+	}
+	return value, ok
+}
+
+// Set sets the value for a key and marks it as the most recently used,
+// evicting the least recently used entries if the cache is full.
+func (c *TypedCache[K, V]) Set(key K, value V) {
+	c.c.Set(key, value)
+}
+
+// Delete deletes the value for a key.
+func (c *TypedCache[K, V]) Delete(key K) {
+	c.c.Delete(key)
+}
+
+// Len returns the number of entries in the cache.
+func (c *TypedCache[K, V]) Len() int {
+	return c.c.Len()
+}
+
+// Weight returns the total weight of the entries in the cache, see
+// Cache.Weight.
+func (c *TypedCache[K, V]) Weight() int64 {
+	return c.c.Weight()
+}
+
+// TypedCounterMap is a concurrent map of int64 counters by keys of type K.
+//
+// The zero TypedCounterMap is empty and ready for use. A TypedCounterMap
+// must not be copied after first use.
+type TypedCounterMap[K comparable] struct {
+	m typedMap[K, int64]
+}
+
+// Add adds delta to the counter of key, which starts at 0, and returns
+// the new total.
+func (c *TypedCounterMap[K]) Add(key K, delta int64) int64 {
+	sh := c.m.getShard(key)
+	sh.mu.Lock()
+	n := sh.m[key] + delta
+	sh.set(key, n)
+	sh.mu.Unlock()
+	return n
+}
+
+// Load returns the counter of key, ok is false if key has no counter.
+func (c *TypedCounterMap[K]) Load(key K) (n int64, ok bool) {
+	return c.m.load(key)
+}
+
+// Store sets the counter of key to n.
+func (c *TypedCounterMap[K]) Store(key K, n int64) {
+	sh := c.m.getShard(key)
+	sh.mu.Lock()
+	sh.set(key, n)
+	sh.mu.Unlock()
+}
+
+// LoadAndDelete deletes the counter of key, returning its value if any.
+// The loaded result reports whether key had a counter.
+func (c *TypedCounterMap[K]) LoadAndDelete(key K) (n int64, loaded bool) {
+	return c.m.loadAndDelete(key)
+}
+
+// Delete deletes the counter of key.
+func (c *TypedCounterMap[K]) Delete(key K) {
+	c.m.loadAndDelete(key)
+}
+
+// Len returns the number of counters.
+func (c *TypedCounterMap[K]) Len() int {
+	return c.m.len()
+}
+
+// Range calls f sequentially for each key and counter present in the map.
+// If f returns false, range stops the iteration.
+func (c *TypedCounterMap[K]) Range(f func(key K, n int64) bool) bool {
+	return c.m.rangeShards(f)
+}
+
+// Snapshot returns a copy of every counter, taken at one instant with
+// every shard locked.
+func (c *TypedCounterMap[K]) Snapshot() map[K]int64 {
+	for i := range c.m.shards {
+		c.m.shards[i].mu.RLock()
+	}
+	n := 0
+	for i := range c.m.shards {
+		n += len(c.m.shards[i].m)
+	}
+	s := make(map[K]int64, n)
+	for i := range c.m.shards {
+		sh := &c.m.shards[i]
+		for k, v := range sh.m {
+			s[k] = v
+		}
+		sh.mu.RUnlock()
+	}
+	return s
+}
+
+// typedMap keeps the entries of TypedSet and TypedCounterMap in shards of
+// plain maps selected by the hash of the key, like StringMap.
+type typedMap[K comparable, V any] struct {
+	shards [1 << sBit]typedShard[K, V]
+}
+
+type typedShard[K comparable, V any] struct {
+	mu sync.RWMutex
+	m  map[K]V
+}
+
+func (m *typedMap[K, V]) getShard(key K) *typedShard[K, V] {
+	return &m.shards[thash(key)&(1<<sBit-1)]
+}
+
+func (m *typedMap[K, V]) load(key K) (value V, ok bool) {
+	s := m.getShard(key)
+	s.mu.RLock()
+	value, ok = s.m[key]
+	s.mu.RUnlock()
+	return value, ok
+}
+
+func (m *typedMap[K, V]) loadAndDelete(key K) (value V, loaded bool) {
+	s := m.getShard(key)
+	s.mu.Lock()
+	value, loaded = s.m[key]
+	if loaded {
+		delete(s.m, key)
+	}
+	s.mu.Unlock()
+	return value, loaded
+}
+
+func (m *typedMap[K, V]) len() int {
+	n := 0
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		n += len(s.m)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+// rangeShards calls f for each entry, copying the entries of a shard
+// before f is called on them, like StringMap.Range.
+func (m *typedMap[K, V]) rangeShards(f func(key K, value V) bool) bool {
+	var keys []K
+	var values []V
+	for i := range m.shards {
+		s := &m.shards[i]
+		keys, values = keys[:0], values[:0]
+		s.mu.RLock()
+		for k, v := range s.m {
+			keys = append(keys, k)
+			values = append(values, v)
+		}
+		s.mu.RUnlock()
+		for j, k := range keys {
+			if !f(k, values[j]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// set sets the value of key, s must be locked.
+func (s *typedShard[K, V]) set(key K, value V) {
+	if s.m == nil {
+		s.m = make(map[K]V)
+	}
+	s.m[key] = value
+}
@@ -0,0 +1,79 @@
+//go:build go1.18
+
+package cmap_test
+
+import (
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestTypedSet(t *testing.T) {
+	var a, b cmap.TypedSet[string]
+	a.Add("x")
+	a.Add("y")
+	b.Add("y")
+	if !a.Contains("x") || a.Add("x") {
+		t.Fatalf("TypedSet lost x")
+	}
+	if u := a.Union(&b); u.Len() != 2 {
+		t.Fatalf("Union Len() = %d, want 2", u.Len())
+	}
+	i := a.Intersect(&b)
+	i.Range(func(v string) bool {
+		if v != "y" {
+			t.Fatalf("Intersect has %q", v)
+		}
+		return true
+	})
+	if d := a.Difference(&b); d.Len() != 1 || !d.Contains("x") {
+		t.Fatalf("Difference is wrong")
+	}
+}
+
+func TestTypedCache(t *testing.T) {
+	c := cmap.NewTypedCache[int, string](2)
+	c.Set(1, "a")
+	if v, ok := c.Get(1); !ok || v != "a" {
+		t.Fatalf("Get(1) = %q, %v", v, ok)
+	}
+	if v, ok := c.Peek(2); ok || v != "" {
+		t.Fatalf("Peek(2) = %q, %v; want zero, false", v, ok)
+	}
+	c.Delete(1)
+	if c.Len() != 0 {
+		t.Fatalf("Len() = %d after Delete", c.Len())
+	}
+}
+
+func TestTypedCounterMap(t *testing.T) {
+	var c cmap.TypedCounterMap[string]
+	c.Add("a", 2)
+	c.Add("a", 3)
+	c.Store("b", 1)
+	if n, _ := c.Load("a"); n != 5 {
+		t.Fatalf("Load(a) = %d, want 5", n)
+	}
+	s := c.Snapshot()
+	if len(s) != 2 || s["a"] != 5 || s["b"] != 1 {
+		t.Fatalf("Snapshot() = %v", s)
+	}
+}
+
+func TestTypedAllocs(t *testing.T) {
+	var s cmap.TypedSet[[2]int]
+	var c cmap.TypedCounterMap[string]
+	s.Add([2]int{1, 2})
+	c.Add("a", 1)
+	if n := testing.AllocsPerRun(100, func() {
+		s.Add([2]int{1, 2})
+		s.Contains([2]int{3, 4})
+		c.Add("a", 1)
+		c.Load("b")
+	}); n != 0 {
+		t.Fatalf("typed operations allocate %v times", n)
+	}
+	if n, _ := c.Load("a"); n != 102 {
+		t.Fatalf("Load(a) = %d, want 102", n)
+	}
+}
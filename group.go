@@ -0,0 +1,85 @@
+package cmap
+
+import (
+	"errors"
+	"sync"
+)
+
+// errCallPanic is the error of the callers waiting for a Group call whose
+// function panicked.
+var errCallPanic = errors.New("cmap: call panicked")
+
+// groupShards is the # of shards holding the calls in flight of a Group.
+const groupShards = 64
+
+// Group deduplicates concurrent calls by key: while a call for a key is in
+// flight, the callers of Do with the same key wait for it and share its
+// result, like singleflight. The calls in flight are spread over shards
+// by the hash of their key, so that calls for other keys do not contend.
+//
+// The zero Group is empty and ready for use. A Group must not be copied
+// after first use.
+type Group struct {
+	shards [groupShards]groupShard
+}
+
+type groupShard struct {
+	mu    sync.Mutex
+	calls map[interface{}]*groupCall
+}
+
+type groupCall struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+	dups  int // # of callers sharing the call, guarded by the shard
+}
+
+func (g *Group) shard(key interface{}) *groupShard {
+	return &g.shards[chash(key, procSeed)%groupShards]
+}
+
+// Do calls fn and returns its results, unless a call for key is in flight,
+// in which case Do waits for it and returns its results instead. shared
+// reports whether the results were given to several callers.
+//
+// If fn panics, the panic goes on in the caller of fn, and the callers
+// waiting for it get an error.
+func (g *Group) Do(key interface{}, fn func() (interface{}, error)) (value interface{}, err error, shared bool) {
+	s := g.shard(key)
+	s.mu.Lock()
+	if c, ok := s.calls[key]; ok {
+		c.dups++
+		s.mu.Unlock()
+		c.wg.Wait()
+		return c.value, c.err, true
+	}
+	if s.calls == nil {
+		s.calls = make(map[interface{}]*groupCall)
+	}
+	c := &groupCall{err: errCallPanic}
+	c.wg.Add(1)
+	s.calls[key] = c
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		if s.calls[key] == c {
+			delete(s.calls, key)
+		}
+		shared = c.dups > 0
+		s.mu.Unlock()
+		c.wg.Done()
+	}()
+	c.value, c.err = fn()
+	return c.value, c.err, false
+}
+
+// Forget makes the next calls of Do for key call their function, rather
+// than wait for a call in flight.
+func (g *Group) Forget(key interface{}) {
+	s := g.shard(key)
+	s.mu.Lock()
+	delete(s.calls, key)
+	s.mu.Unlock()
+}
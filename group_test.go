@@ -0,0 +1,96 @@
+package cmap_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/min1324/cmap"
+)
+
+func TestGroup(t *testing.T) {
+	var g cmap.Group
+	var calls, shared int32
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err, s := g.Do("a", func() (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				<-release
+				return 1, nil
+			})
+			if v != 1 || err != nil {
+				t.Errorf("Do(a) = %v, %v", v, err)
+			}
+			if s {
+				atomic.AddInt32(&shared, 1)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	// other keys do not wait for a
+	if v, _, s := g.Do("b", func() (interface{}, error) { return 2, nil }); v != 2 || s {
+		t.Fatalf("Do(b) = %v, shared %v", v, s)
+	}
+	close(release)
+	wg.Wait()
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("fn called %d times for concurrent calls, want 1", n)
+	}
+	if n := atomic.LoadInt32(&shared); n != 10 {
+		t.Fatalf("%d callers got shared results, want 10", n)
+	}
+
+	// calls after the first one are not deduplicated
+	v, _, _ := g.Do("a", func() (interface{}, error) { return 3, nil })
+	if v != 3 {
+		t.Fatalf("Do(a) after the call = %v, want 3", v)
+	}
+}
+
+func TestGroupForget(t *testing.T) {
+	var g cmap.Group
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		g.Do("a", func() (interface{}, error) {
+			close(started)
+			<-release
+			return 1, nil
+		})
+	}()
+	<-started
+	g.Forget("a")
+	if v, _, s := g.Do("a", func() (interface{}, error) { return 2, nil }); v != 2 || s {
+		t.Fatalf("Do(a) after Forget = %v, shared %v; want 2, false", v, s)
+	}
+	close(release)
+	<-done
+}
+
+func TestGroupPanic(t *testing.T) {
+	var g cmap.Group
+	started := make(chan struct{})
+	waiter := make(chan error)
+	go func() {
+		defer func() { recover() }()
+		g.Do("a", func() (interface{}, error) {
+			close(started)
+			time.Sleep(50 * time.Millisecond)
+			panic("boom")
+		})
+	}()
+	<-started
+	go func() {
+		_, err, _ := g.Do("a", func() (interface{}, error) { return 1, nil })
+		waiter <- err
+	}()
+	if err := <-waiter; err == nil {
+		t.Fatalf("caller waiting for a panicking call got no error")
+	}
+}
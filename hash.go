@@ -1,19 +1,49 @@
 package cmap
 
-import "unsafe"
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"time"
+	"unsafe"
+)
 
-func chash(i interface{}) uintptr {
-	return nilinterhash(noescape(unsafe.Pointer(&i)), 0xdeadbeef)
+// The keys are hashed by chash, and strings by shash. Since Go 1.24 they
+// are built on hash/maphash, which hashes any comparable key like a Go
+// map does, see hash_maphash.go. Older Go versions call the runtime hash
+// functions directly, see hash_runtime.go.
+
+// procSeed seeds the hashes of the types without a seed of their own.
+var procSeed = newSeed()
+
+// newSeed returns a random hash seed.
+func newSeed() uintptr {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return uintptr(time.Now().UnixNano()) | 1
+	}
+	return uintptr(binary.LittleEndian.Uint64(b[:]))
 }
 
-// in runtime/alg.go
-//
-//go:linkname nilinterhash runtime.nilinterhash
-func nilinterhash(p unsafe.Pointer, h uintptr) uintptr
+// scramble is the finalizer of splitmix64, a bijection which spreads
+// every bit of x over the result.
+func scramble(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	return x ^ x>>31
+}
 
-//go:nocheckptr
+// noescape hides p from escape analysis, for the values which don't
+// outlive a call, like the noescape of the runtime. p is read back from
+// a uintptr in memory, not converted from it, which vet accepts.
+//
+// It is only used by CMap.Load, to hash the key without boxing it on the
+// heap: the other hashes don't need it, see thash, shash and the
+// go:noescape functions of hash_runtime.go.
+//
 //go:nosplit
 func noescape(p unsafe.Pointer) unsafe.Pointer {
 	x := uintptr(p)
-	return unsafe.Pointer(x ^ 0)
+	return *(*unsafe.Pointer)(unsafe.Pointer(&x))
 }
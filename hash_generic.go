@@ -0,0 +1,9 @@
+//go:build go1.18 && !go1.24
+
+package cmap
+
+// thash hashes the keys of the typed maps, with procSeed. key is boxed on
+// the stack, nilinterhash doesn't keep it.
+func thash[K comparable](key K) uintptr {
+	return chash(key, procSeed)
+}
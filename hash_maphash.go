@@ -0,0 +1,23 @@
+//go:build go1.24
+
+package cmap
+
+import "hash/maphash"
+
+// keySeed seeds maphash, the seeds of the maps are mixed in afterwards
+// since a maphash.Seed can't be set.
+var keySeed = maphash.MakeSeed()
+
+func chash(i interface{}, seed uintptr) uintptr {
+	return uintptr(scramble(maphash.Comparable(keySeed, i) ^ uint64(seed)))
+}
+
+func shash(s string) uintptr {
+	return uintptr(maphash.String(keySeed, s) ^ uint64(procSeed))
+}
+
+// thash hashes the keys of the typed maps, with procSeed. Unlike chash,
+// it doesn't box key, which maphash would move to the heap.
+func thash[K comparable](key K) uintptr {
+	return uintptr(maphash.Comparable(keySeed, key) ^ uint64(procSeed))
+}
@@ -0,0 +1,25 @@
+//go:build !go1.24
+
+package cmap
+
+import "unsafe"
+
+func chash(i interface{}, seed uintptr) uintptr {
+	return nilinterhash(unsafe.Pointer(&i), seed)
+}
+
+// in runtime/alg.go
+//
+//go:linkname nilinterhash runtime.nilinterhash
+//go:noescape
+func nilinterhash(p unsafe.Pointer, h uintptr) uintptr
+
+func shash(s string) uintptr {
+	return strhash(unsafe.Pointer(&s), procSeed)
+}
+
+// in runtime/alg.go
+//
+//go:linkname strhash runtime.strhash
+//go:noescape
+func strhash(p unsafe.Pointer, h uintptr) uintptr
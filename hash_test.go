@@ -0,0 +1,63 @@
+package cmap_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func bucketsOf(m *cmap.CMap) []int {
+	for i := 0; i < 1000; i++ {
+		m.Store(i, i)
+	}
+	return m.Stats().Buckets
+}
+
+func TestWithHashSeed(t *testing.T) {
+	a := bucketsOf(cmap.New(cmap.WithHashSeed(42)))
+	b := bucketsOf(cmap.New(cmap.WithHashSeed(42)))
+	if !reflect.DeepEqual(a, b) {
+		t.Fatalf("maps with the same seed have buckets %v and %v", a, b)
+	}
+
+	// the odds of two random seeds spreading 1000 keys the same way are nil
+	if c := bucketsOf(new(cmap.CMap)); reflect.DeepEqual(a, c) {
+		t.Fatalf("map with a random seed has the buckets of seed 42: %v", c)
+	}
+}
+
+func TestHashKeyTypes(t *testing.T) {
+	type pair struct {
+		a, b interface{}
+	}
+	ch := make(chan int)
+	keys := []interface{}{
+		pair{1, "a"},
+		pair{1, pair{2, "b"}},
+		[3]interface{}{1, "a", 2.5},
+		[2]string{"x", "y"},
+		ch,
+		&ch,
+		1.5,
+		complex(1, 2),
+		struct{}{},
+		"",
+		true,
+	}
+	var m cmap.CMap
+	for i, k := range keys {
+		m.Store(k, i)
+	}
+	for i, k := range keys {
+		if v, ok := m.Load(k); !ok || v != i {
+			t.Fatalf("Load(%v) = %v, %v, want %d", k, v, ok, i)
+		}
+	}
+	// equal keys built apart must hash the same
+	for i, k := range []interface{}{pair{1, "a"}, [3]interface{}{1, "a", 2.5}, [2]string{"x", "y"}} {
+		if _, ok := m.Load(k); !ok {
+			t.Fatalf("key %d: copy of %v not found", i, k)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package cmap
+
+// Interface is the method set shared by Map, CMap and sync.Map, so that
+// code can switch between them, or benchmark them, behind one interface.
+// AdaptMap, AdaptCMap and AdaptSyncMap return them as an Interface.
+type Interface interface {
+	Load(key interface{}) (value interface{}, ok bool)
+	Store(key, value interface{})
+	LoadOrStore(key, value interface{}) (actual interface{}, loaded bool)
+	LoadAndDelete(key interface{}) (value interface{}, loaded bool)
+	Delete(key interface{})
+	Range(f func(key, value interface{}) bool)
+	Swap(key, value interface{}) (previous interface{}, loaded bool)
+	CompareAndSwap(key, old, new interface{}) (swapped bool)
+}
+
+// AdaptMap returns m as an Interface. It only differs from m by its Range,
+// which has no result like the one of sync.Map.
+func AdaptMap(m *Map) Interface {
+	return (*mapAdapter)(m)
+}
+
+type mapAdapter Map
+
+func (a *mapAdapter) Load(key interface{}) (value interface{}, ok bool) {
+	return (*Map)(a).Load(key)
+}
+
+func (a *mapAdapter) Store(key, value interface{}) {
+	(*Map)(a).Store(key, value)
+}
+
+func (a *mapAdapter) LoadOrStore(key, value interface{}) (actual interface{}, loaded bool) {
+	return (*Map)(a).LoadOrStore(key, value)
+}
+
+func (a *mapAdapter) LoadAndDelete(key interface{}) (value interface{}, loaded bool) {
+	return (*Map)(a).LoadAndDelete(key)
+}
+
+func (a *mapAdapter) Delete(key interface{}) {
+	(*Map)(a).Delete(key)
+}
+
+func (a *mapAdapter) Range(f func(key, value interface{}) bool) {
+	(*Map)(a).Range(f)
+}
+
+func (a *mapAdapter) Swap(key, value interface{}) (previous interface{}, loaded bool) {
+	return (*Map)(a).Swap(key, value)
+}
+
+func (a *mapAdapter) CompareAndSwap(key, old, new interface{}) bool {
+	return (*Map)(a).CompareAndSwap(key, old, new)
+}
+
+// AdaptCMap returns m as an Interface. Like AdaptMap, it only differs
+// from m by its Range, which has no result.
+func AdaptCMap(m *CMap) Interface {
+	return (*cmapAdapter)(m)
+}
+
+// cmapAdapter is a CMap but for Range, which is Interface's.
+type cmapAdapter CMap
+
+var _ Interface = (*cmapAdapter)(nil)
+
+func (a *cmapAdapter) Load(key interface{}) (value interface{}, ok bool) {
+	return (*CMap)(a).Load(key)
+}
+
+func (a *cmapAdapter) Store(key, value interface{}) {
+	(*CMap)(a).Store(key, value)
+}
+
+func (a *cmapAdapter) LoadOrStore(key, value interface{}) (actual interface{}, loaded bool) {
+	return (*CMap)(a).LoadOrStore(key, value)
+}
+
+func (a *cmapAdapter) LoadAndDelete(key interface{}) (value interface{}, loaded bool) {
+	return (*CMap)(a).LoadAndDelete(key)
+}
+
+func (a *cmapAdapter) Delete(key interface{}) {
+	(*CMap)(a).Delete(key)
+}
+
+func (a *cmapAdapter) Range(f func(key, value interface{}) bool) {
+	(*CMap)(a).Range(f)
+}
+
+func (a *cmapAdapter) Swap(key, value interface{}) (previous interface{}, loaded bool) {
+	return (*CMap)(a).Swap(key, value)
+}
+
+func (a *cmapAdapter) CompareAndSwap(key, old, new interface{}) bool {
+	return (*CMap)(a).CompareAndSwap(key, old, new)
+}
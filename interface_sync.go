@@ -0,0 +1,11 @@
+//go:build go1.20
+
+package cmap
+
+import "sync"
+
+// AdaptSyncMap returns m as an Interface. Since Go 1.20, a *sync.Map
+// implements Interface as it is.
+func AdaptSyncMap(m *sync.Map) Interface {
+	return m
+}
@@ -0,0 +1,28 @@
+//go:build go1.20
+
+package cmap_test
+
+import (
+	"sync"
+	"testing"
+	"testing/quick"
+
+	"github.com/min1324/cmap"
+)
+
+// The tests of this file need a *sync.Map implementing Interface, since
+// Go 1.20, see AdaptSyncMap.
+
+func TestInterfaceSyncMap(t *testing.T) {
+	testInterface(t, cmap.AdaptSyncMap(new(sync.Map)))
+}
+
+func applySyncMap(calls []mapCall) ([]mapResult, map[interface{}]interface{}) {
+	return applyCalls(cmap.AdaptSyncMap(new(sync.Map)), calls)
+}
+
+func TestMapMatchesSync(t *testing.T) {
+	if err := quick.CheckEqual(applyMap, applySyncMap, nil); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,40 @@
+package cmap_test
+
+import (
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+// testInterface checks the methods of m, an empty map.
+func testInterface(t *testing.T, m cmap.Interface) {
+	m.Store("a", 1)
+	if v, loaded := m.Swap("a", 2); !loaded || v != 1 {
+		t.Fatalf("%T: Swap(a) = %v, %v; want 1, true", m, v, loaded)
+	}
+	if m.CompareAndSwap("a", 1, 3) {
+		t.Fatalf("%T: CompareAndSwap(a, 1) swapped a value of 2", m)
+	}
+	if !m.CompareAndSwap("a", 2, 3) {
+		t.Fatalf("%T: CompareAndSwap(a, 2) did not swap", m)
+	}
+	if v, loaded := m.Swap("b", 4); loaded || v != nil {
+		t.Fatalf("%T: Swap(b) = %v, %v; want nil, false", m, v, loaded)
+	}
+	n := 0
+	m.Range(func(_, _ interface{}) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Fatalf("%T: Range called f %d times after false, want 1", m, n)
+	}
+	if v, ok := m.Load("a"); !ok || v != 3 {
+		t.Fatalf("%T: Load(a) = %v, %v; want 3, true", m, v, ok)
+	}
+}
+
+func TestInterface(t *testing.T) {
+	testInterface(t, cmap.AdaptMap(new(cmap.Map)))
+	testInterface(t, cmap.AdaptCMap(new(cmap.CMap)))
+}
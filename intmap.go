@@ -0,0 +1,173 @@
+package cmap
+
+import "sync"
+
+// UintMap is like a CMap keyed by uint64, its shards are plain
+// map[uint64]interface{} selected by the mixed bits of the key, so keys
+// are neither boxed nor hashed by the runtime.
+//
+// The zero UintMap is empty and ready for use. A UintMap must not be
+// copied after first use.
+type UintMap struct {
+	shards [1 << sBit]uintShard
+}
+
+type uintShard struct {
+	mu sync.RWMutex
+	m  map[uint64]interface{}
+}
+
+// mix spreads the bits of k over the top bits of the result, so that
+// sequential keys land in different shards (fibonacci hashing).
+func mix(k uint64) uint64 {
+	return (k ^ k>>32) * 0x9e3779b97f4a7c15
+}
+
+func (m *UintMap) getShard(key uint64) *uintShard {
+	return &m.shards[mix(key)>>(64-sBit)]
+}
+
+// Load returns the value stored in the map for a key, or nil if no
+// value is present.
+// The ok result indicates whether value was found in the map.
+func (m *UintMap) Load(key uint64) (value interface{}, ok bool) {
+	s := m.getShard(key)
+	s.mu.RLock()
+	value, ok = s.m[key]
+	s.mu.RUnlock()
+	return value, ok
+}
+
+// Store sets the value for a key.
+func (m *UintMap) Store(key uint64, value interface{}) {
+	s := m.getShard(key)
+	s.mu.Lock()
+	if s.m == nil {
+		s.m = make(map[uint64]interface{})
+	}
+	s.m[key] = value
+	s.mu.Unlock()
+}
+
+// LoadOrStore returns the existing value for the key if present.
+// Otherwise, it stores and returns the given value.
+// The loaded result is true if the value was loaded, false if stored.
+func (m *UintMap) LoadOrStore(key uint64, value interface{}) (actual interface{}, loaded bool) {
+	s := m.getShard(key)
+	s.mu.Lock()
+	actual, loaded = s.m[key]
+	if !loaded {
+		if s.m == nil {
+			s.m = make(map[uint64]interface{})
+		}
+		s.m[key] = value
+		actual = value
+	}
+	s.mu.Unlock()
+	return actual, loaded
+}
+
+// LoadAndDelete deletes the value for a key, returning the previous value if any.
+// The loaded result reports whether the key was present.
+func (m *UintMap) LoadAndDelete(key uint64) (value interface{}, loaded bool) {
+	s := m.getShard(key)
+	s.mu.Lock()
+	value, loaded = s.m[key]
+	delete(s.m, key)
+	s.mu.Unlock()
+	return value, loaded
+}
+
+// Delete deletes the value for a key.
+func (m *UintMap) Delete(key uint64) {
+	m.LoadAndDelete(key)
+}
+
+// Len returns the number of elements within the map.
+func (m *UintMap) Len() int {
+	n := 0
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		n += len(s.m)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+// Range calls f sequentially for each key and value present in the map.
+// If f returns false, range stops the iteration.
+//
+// The entries of a shard are copied before f is called on them, so f may
+// use the map.
+func (m *UintMap) Range(f func(key uint64, value interface{}) bool) bool {
+	var keys []uint64
+	var values []interface{}
+	for i := range m.shards {
+		s := &m.shards[i]
+		keys, values = keys[:0], values[:0]
+		s.mu.RLock()
+		for k, v := range s.m {
+			keys = append(keys, k)
+			values = append(values, v)
+		}
+		s.mu.RUnlock()
+		for j, k := range keys {
+			if !f(k, values[j]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Int64Map is a UintMap keyed by int64.
+//
+// The zero Int64Map is empty and ready for use. An Int64Map must not be
+// copied after first use.
+type Int64Map struct {
+	m UintMap
+}
+
+// Load returns the value stored in the map for a key, or nil if no
+// value is present.
+// The ok result indicates whether value was found in the map.
+func (m *Int64Map) Load(key int64) (value interface{}, ok bool) {
+	return m.m.Load(uint64(key))
+}
+
+// Store sets the value for a key.
+func (m *Int64Map) Store(key int64, value interface{}) {
+	m.m.Store(uint64(key), value)
+}
+
+// LoadOrStore returns the existing value for the key if present.
+// Otherwise, it stores and returns the given value.
+// The loaded result is true if the value was loaded, false if stored.
+func (m *Int64Map) LoadOrStore(key int64, value interface{}) (actual interface{}, loaded bool) {
+	return m.m.LoadOrStore(uint64(key), value)
+}
+
+// LoadAndDelete deletes the value for a key, returning the previous value if any.
+// The loaded result reports whether the key was present.
+func (m *Int64Map) LoadAndDelete(key int64) (value interface{}, loaded bool) {
+	return m.m.LoadAndDelete(uint64(key))
+}
+
+// Delete deletes the value for a key.
+func (m *Int64Map) Delete(key int64) {
+	m.m.Delete(uint64(key))
+}
+
+// Len returns the number of elements within the map.
+func (m *Int64Map) Len() int {
+	return m.m.Len()
+}
+
+// Range calls f sequentially for each key and value present in the map.
+// If f returns false, range stops the iteration.
+func (m *Int64Map) Range(f func(key int64, value interface{}) bool) bool {
+	return m.m.Range(func(key uint64, value interface{}) bool {
+		return f(int64(key), value)
+	})
+}
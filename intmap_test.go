@@ -0,0 +1,65 @@
+package cmap_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestInt64Map(t *testing.T) {
+	var m cmap.Int64Map
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := int64(g); i < 1000; i += 4 {
+				m.Store(i-500, i)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if m.Len() != 1000 {
+		t.Fatalf("Len() = %d, want 1000", m.Len())
+	}
+	if v, ok := m.Load(-42); !ok || v != int64(458) {
+		t.Fatalf("Load(-42) = %v, %v", v, ok)
+	}
+	if v, loaded := m.LoadOrStore(-42, 0); !loaded || v != int64(458) {
+		t.Fatalf("LoadOrStore(-42) = %v, %v", v, loaded)
+	}
+	if v, loaded := m.LoadAndDelete(-42); !loaded || v != int64(458) {
+		t.Fatalf("LoadAndDelete(-42) = %v, %v", v, loaded)
+	}
+	if _, ok := m.Load(-42); ok {
+		t.Fatalf("Load(-42) after delete")
+	}
+	n := 0
+	m.Range(func(key int64, value interface{}) bool {
+		if key+500 != value.(int64) {
+			t.Fatalf("Range saw %d: %v", key, value)
+		}
+		n++
+		return true
+	})
+	if n != 999 {
+		t.Fatalf("Range saw %d keys, want 999", n)
+	}
+}
+
+func TestUintMapSpread(t *testing.T) {
+	var m cmap.UintMap
+	for i := uint64(0); i < 1<<12; i++ {
+		m.Store(i<<16, i)
+	}
+	if m.Len() != 1<<12 {
+		t.Fatalf("Len() = %d, want %d", m.Len(), 1<<12)
+	}
+	for i := uint64(0); i < 1<<12; i++ {
+		if v, ok := m.Load(i << 16); !ok || v != i {
+			t.Fatalf("Load(%d) = %v, %v", i<<16, v, ok)
+		}
+	}
+}
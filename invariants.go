@@ -0,0 +1,12 @@
+//go:build !cmapdebug
+
+package cmap
+
+// The invariants of the buckets are only checked by the builds with the
+// cmapdebug tag, see invariants_debug.go. The hooks are no-ops here.
+
+func (n *node) debugCheck(b *bucket) {}
+
+func debugFreeze(b *bucket) {}
+
+func debugFrozen(b *bucket) {}
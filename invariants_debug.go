@@ -0,0 +1,86 @@
+//go:build cmapdebug
+
+package cmap
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// With the cmapdebug build tag, the map checks the invariants of its
+// buckets as it goes, and panics on the first violation:
+//
+//   - after a write, the count of the bucket written is its number of
+//     entries, and every key of the bucket has its index in the node;
+//   - a bucket frozen by a resize, Compact or Fork is never changed.
+//
+// A bucket is only checked after its writes numbered by a power of two
+// not less than its count, and once more when it is frozen, so that the
+// checks of a bucket cost about as much as its writes. They still lock
+// the bucket exclusively, and are too slow for production.
+
+// frozen holds the # of entries of the frozen buckets, by address. A
+// bucket is removed by its finalizer once unreachable.
+var frozen sync.Map // uintptr -> int
+
+func entries(b *bucket) int {
+	n := 0
+	b.rangeHash(func(_, _ interface{}, _ uintptr) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// debugCheck checks b, written as a bucket of n.
+func (n *node) debugCheck(b *bucket) {
+	if w := atomic.LoadUint64(&b.writes); w&(w-1) != 0 || int64(w) < atomic.LoadInt64(&b.count) {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := frozen.Load(uintptr(unsafe.Pointer(b))); ok {
+		// frozen since the write, checked by debugFrozen
+		return
+	}
+	count, i, first := 0, uintptr(0), true
+	b.rangeHash(func(key, _ interface{}, hash uintptr) bool {
+		count++
+		if first {
+			i, first = hash&n.mask, false
+		} else if hash&n.mask != i {
+			panic(fmt.Sprintf("cmap: invariant violated: key %v of bucket %d belongs to bucket %d (B=%d)", key, i, hash&n.mask, n.B))
+		}
+		return true
+	})
+	if c := b.count; c != int64(count) {
+		panic(fmt.Sprintf("cmap: invariant violated: bucket %d counts %d elements, holds %d (B=%d)", i, c, count, n.B))
+	}
+}
+
+// debugFreeze records that b must not change anymore, and checks its
+// count. It is called with b locked.
+func debugFreeze(b *bucket) {
+	n := entries(b)
+	if c := atomic.LoadInt64(&b.count); c != int64(n) {
+		panic(fmt.Sprintf("cmap: invariant violated: frozen bucket counts %d elements, holds %d", c, n))
+	}
+	p := uintptr(unsafe.Pointer(b))
+	if _, loaded := frozen.LoadOrStore(p, n); !loaded {
+		runtime.SetFinalizer(b, func(*bucket) { frozen.Delete(p) })
+	}
+}
+
+// debugFrozen checks that the frozen bucket b is unchanged.
+func debugFrozen(b *bucket) {
+	want, ok := frozen.Load(uintptr(unsafe.Pointer(b)))
+	if !ok {
+		return
+	}
+	if got := entries(b); got != want.(int) {
+		panic(fmt.Sprintf("cmap: invariant violated: frozen bucket changed from %d to %d entries", want, got))
+	}
+}
@@ -0,0 +1,44 @@
+//go:build cmapdebug
+
+package cmap_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+// TestInvariants runs writes racing with resizes, Compact and Fork, which
+// panic in the cmapdebug builds on a broken invariant of the buckets.
+func TestInvariants(t *testing.T) {
+	m := cmap.New(cmap.WithLoadFactor(1, 0.25))
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 5000; i++ {
+				k := (g*5000 + i) % 3000
+				switch i % 4 {
+				case 0, 1:
+					m.Store(k, i)
+				case 2:
+					m.Delete(k)
+				case 3:
+					m.Update(k, func(v interface{}, _ bool) (interface{}, bool) { return i, false })
+				}
+			}
+		}(g)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			m.Compact()
+			f := m.Fork()
+			f.Store(i, i)
+		}
+	}()
+	wg.Wait()
+}
@@ -0,0 +1,21 @@
+package cmap
+
+// IterBuffered returns a channel receiving the entries of the map, copied
+// one bucket at a time by a goroutine while they are received. The
+// channel is buffered for the elements of the map at the call, and closed
+// once every bucket is sent.
+//
+// The channel must be drained, or the goroutine is leaked if the map grew
+// meanwhile. Like Range, IterBuffered does not correspond to any
+// consistent snapshot of the map's contents.
+func (m *CMap) IterBuffered() <-chan Entry {
+	ch := make(chan Entry, m.Len())
+	go func() {
+		defer close(ch)
+		m.rangeBuckets(func(key, value interface{}) bool {
+			ch <- Entry{key, value}
+			return true
+		})
+	}()
+	return ch
+}
@@ -0,0 +1,24 @@
+package cmap_test
+
+import (
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestIterBuffered(t *testing.T) {
+	var m cmap.CMap
+	for i := 0; i < 1000; i++ {
+		m.Store(i, i)
+	}
+	seen := make(map[interface{}]bool)
+	for e := range m.IterBuffered() {
+		if e.Key != e.Value || seen[e.Key] {
+			t.Fatalf("IterBuffered sent %v: %v", e.Key, e.Value)
+		}
+		seen[e.Key] = true
+	}
+	if len(seen) != 1000 {
+		t.Fatalf("IterBuffered sent %d entries, want 1000", len(seen))
+	}
+}
@@ -0,0 +1,126 @@
+package cmap
+
+import (
+	"bufio"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// EncodeJSON writes the entries of m to w as a JSON object, copying them
+// out of one bucket at a time, so that a large map is never copied whole,
+// unlike with json.Marshal of ToMap. The keys are encoded like the keys
+// of a map by encoding/json: strings, encoding.TextMarshaler and integers
+// are supported, in the order of the buckets. The values are encoded by
+// json.Marshal.
+//
+// Like Range, EncodeJSON does not correspond to any consistent snapshot
+// of the map's contents: use it on a Fork for that.
+func (m *CMap) EncodeJSON(w io.Writer) error {
+	enc := newJSONWriter(w)
+	var err error
+	m.rangeChunks(nil, maxPooledEntries, false, func(key, value interface{}) bool {
+		var name string
+		if name, err = jsonKey(key); err == nil {
+			err = enc.entry(name, value)
+		}
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	return enc.close()
+}
+
+// EncodeJSONSorted is EncodeJSON writing the keys in ascending order of
+// their encoding, like json.Marshal of a map, so that equal maps encode
+// the same. It copies the keys of the map first, but not the values,
+// which are loaded as they are written: keys deleted meanwhile are
+// skipped.
+func (m *CMap) EncodeJSONSorted(w io.Writer) error {
+	type named struct {
+		name string
+		key  interface{}
+	}
+	var keys []named
+	var err error
+	m.RangeKeys(func(key interface{}) bool {
+		var name string
+		name, err = jsonKey(key)
+		keys = append(keys, named{name, key})
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].name < keys[j].name })
+	enc := newJSONWriter(w)
+	for _, k := range keys {
+		if v, ok := m.Load(k.key); ok {
+			if err := enc.entry(k.name, v); err != nil {
+				return err
+			}
+		}
+	}
+	return enc.close()
+}
+
+// jsonKey returns the name of key in a JSON object.
+func jsonKey(key interface{}) (string, error) {
+	v := reflect.ValueOf(key)
+	if v.Kind() == reflect.String {
+		return v.String(), nil
+	}
+	if t, ok := key.(encoding.TextMarshaler); ok {
+		b, err := t.MarshalText()
+		return string(b), err
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("cmap: unsupported JSON key type %T", key)
+}
+
+// jsonWriter writes a JSON object one entry at a time.
+type jsonWriter struct {
+	w     *bufio.Writer
+	first bool
+}
+
+func newJSONWriter(w io.Writer) *jsonWriter {
+	bw := bufio.NewWriter(w)
+	bw.WriteByte('{')
+	return &jsonWriter{w: bw, first: true}
+}
+
+func (j *jsonWriter) entry(name string, value interface{}) error {
+	k, err := json.Marshal(name)
+	if err != nil {
+		return err
+	}
+	v, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if !j.first {
+		j.w.WriteByte(',')
+	}
+	j.first = false
+	j.w.Write(k)
+	j.w.WriteByte(':')
+	_, err = j.w.Write(v)
+	return err
+}
+
+// close ends the object and flushes it.
+func (j *jsonWriter) close() error {
+	j.w.WriteByte('}')
+	return j.w.Flush()
+}
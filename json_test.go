@@ -0,0 +1,63 @@
+package cmap_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+// version is a key encoding to text.
+type version struct{ major, minor int }
+
+func (v version) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("v%d.%d", v.major, v.minor)), nil
+}
+
+func TestEncodeJSON(t *testing.T) {
+	var m cmap.CMap
+	want := make(map[string]interface{})
+	for i := 0; i < 10000; i++ {
+		m.Store(i, []interface{}{"v", float64(i)})
+		want[strconv.Itoa(i)] = []interface{}{"v", float64(i)}
+	}
+	m.Store("a<b", map[string]interface{}{"x": true})
+	want["a<b"] = map[string]interface{}{"x": true}
+	m.Store(uint64(1<<40), nil)
+	want["1099511627776"] = nil
+	m.Store(version{1, 2}, "text")
+	want["v1.2"] = "text"
+
+	var buf bytes.Buffer
+	if err := m.EncodeJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("EncodeJSON wrote invalid JSON: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("EncodeJSON decoded to %d entries, want %d equal ones", len(got), len(want))
+	}
+
+	// sorted like json.Marshal sorts a map
+	buf.Reset()
+	if err := m.EncodeJSONSorted(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := json.Marshal(want); !bytes.Equal(buf.Bytes(), b) {
+		t.Fatalf("EncodeJSONSorted = %.100s..., want %.100s...", buf.Bytes(), b)
+	}
+
+	m.Store(struct{}{}, 1)
+	if err := m.EncodeJSON(new(bytes.Buffer)); err == nil {
+		t.Fatal("EncodeJSON of a struct key succeeded")
+	}
+	if err := m.EncodeJSONSorted(new(bytes.Buffer)); err == nil {
+		t.Fatal("EncodeJSONSorted of a struct key succeeded")
+	}
+}
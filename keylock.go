@@ -0,0 +1,43 @@
+package cmap
+
+import "sync"
+
+// keyStripes is the # of mutexes shared by the keys for LockKey.
+const keyStripes = 256
+
+type keyLocks [keyStripes]sync.Mutex
+
+// LockKey locks key for the caller until the returned unlock func is
+// called, so that a multi-step operation on key runs atomically with
+// respect to the other holders of key.
+//
+// LockKey does not block the other operations of the map: every writer
+// of key taking part in such operations must hold LockKey. Keys share a
+// fixed set of mutexes, so a holder may block another key, and must not
+// lock a second key, unlike DoAtomic.
+func (m *CMap) LockKey(key interface{}) (unlock func()) {
+	mu := &m.getKeyLocks()[chash(key, procSeed)%keyStripes]
+	mu.Lock()
+	return mu.Unlock
+}
+
+// WithKeyLocked calls fn with key locked as by LockKey.
+func (m *CMap) WithKeyLocked(key interface{}, fn func()) {
+	unlock := m.LockKey(key)
+	defer unlock()
+	fn()
+}
+
+func (m *CMap) getKeyLocks() *keyLocks {
+	if l, _ := m.keyLocks.Load().(*keyLocks); l != nil {
+		return l
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, _ := m.keyLocks.Load().(*keyLocks)
+	if l == nil {
+		l = new(keyLocks)
+		m.keyLocks.Store(l)
+	}
+	return l
+}
@@ -0,0 +1,31 @@
+package cmap_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestLockKey(t *testing.T) {
+	var m cmap.CMap
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				m.WithKeyLocked("k", func() {
+					// a racy read-modify-write made atomic by the key lock
+					v, _ := m.Load("k")
+					n, _ := v.(int)
+					m.Store("k", n+1)
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _ := m.Load("k"); v != 4000 {
+		t.Fatalf("Load(k) = %v, want 4000", v)
+	}
+}
@@ -0,0 +1,295 @@
+package cmap
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// The accesses of each key are counted, approximately, by count-min
+// sketches: each key increments a counter of every row of the sketch of
+// its stripe, and its count is the least of them, which only overestimates
+// the count when other keys share all its counters. Each stripe also keeps
+// its keysTop most counted keys as candidates for TopKeys.
+const (
+	sketchRows       = 4
+	sketchBits       = 10
+	sketchWidth      = 1 << sketchBits
+	sketchStripeBits = 4
+	sketchStripes    = 1 << sketchStripeBits
+	keysTop          = 16
+)
+
+// KeyCount is the approximate number of accesses of a key, see TopKeys.
+type KeyCount struct {
+	Key    interface{}
+	Count  uint64 // operations on the key
+	Misses uint64 // Loads which found no value
+}
+
+type keyStats struct {
+	stripe [sketchStripes]sketch
+}
+
+type sketch struct {
+	count [sketchRows][sketchWidth]uint32
+	miss  [sketchRows][sketchWidth]uint32
+
+	mu  sync.Mutex
+	top []KeyCount // candidates, Count is the count when last seen
+	min uint32     // least count of top once full, read atomically
+}
+
+// the odd multipliers spreading a hash over the rows of a sketch
+var sketchSeeds = [sketchRows]uint64{
+	0x9e3779b97f4a7c15, 0xc2b2ae3d27d4eb4f, 0x165667b19e3779f9, 0xd6e8feb86659fd93,
+}
+
+// WithKeyStats makes the map count the operations on each key, and the
+// Loads finding no value, for TopKeys. The counts are approximate, kept in
+// fixed-size sketches, so tracking costs no allocation per key.
+func WithKeyStats() Option {
+	return func(m *CMap) {
+		m.keyStats = new(keyStats)
+	}
+}
+
+// TopKeys returns up to n of the most accessed keys since the map was
+// created or ResetKeyStats was called, with their approximate counts, most
+// accessed first. Keys accessed often but not lately may be missing. It
+// returns nil if the map was not created WithKeyStats.
+func (m *CMap) TopKeys(n int) []KeyCount {
+	ks := m.keyStats
+	if ks == nil || n <= 0 {
+		return nil
+	}
+	var top []KeyCount
+	for i := range ks.stripe {
+		s := &ks.stripe[i]
+		s.mu.Lock()
+		for _, kc := range s.top {
+			idx := sketchIndexes(chash(kc.Key, m.seed))
+			top = append(top, KeyCount{
+				Key:    kc.Key,
+				Count:  uint64(s.estimate(&s.count, idx)),
+				Misses: uint64(s.estimate(&s.miss, idx)),
+			})
+		}
+		s.mu.Unlock()
+	}
+	sort.Slice(top, func(i, j int) bool { return top[i].Count > top[j].Count })
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// ResetKeyStats zeroes the counts of TopKeys.
+func (m *CMap) ResetKeyStats() {
+	ks := m.keyStats
+	if ks == nil {
+		return
+	}
+	for i := range ks.stripe {
+		s := &ks.stripe[i]
+		s.mu.Lock()
+		for r := range s.count {
+			for c := range s.count[r] {
+				atomic.StoreUint32(&s.count[r][c], 0)
+				atomic.StoreUint32(&s.miss[r][c], 0)
+			}
+		}
+		s.top = s.top[:0]
+		atomic.StoreUint32(&s.min, 0)
+		s.mu.Unlock()
+	}
+}
+
+func (ks *keyStats) stripeOf(hash uintptr) *sketch {
+	return &ks.stripe[mix(uint64(hash))>>(64-sketchStripeBits)]
+}
+
+// access counts an operation on key.
+func (ks *keyStats) access(key interface{}, hash uintptr) {
+	s, est := ks.count(hash)
+	if est > atomic.LoadUint32(&s.min) {
+		s.promote(key, est, nil)
+	}
+}
+
+// loaded counts a Load of key, found in bucket b if found. key may be on
+// the stack of the caller of Load, so a new candidate is the key stored
+// in b, or a copy of key if it was not found, see heapKey.
+func (ks *keyStats) loaded(key interface{}, hash uintptr, b *bucket, found bool) {
+	if !found {
+		ks.missed(hash)
+	}
+	s, est := ks.count(hash)
+	if est <= atomic.LoadUint32(&s.min) {
+		return
+	}
+	s.promote(key, est, func() (interface{}, bool) {
+		if found {
+			return b.storedKey(key, hash)
+		}
+		return heapKey(key)
+	})
+}
+
+// count counts an operation on a key of hash, and returns its stripe and
+// estimated count.
+func (ks *keyStats) count(hash uintptr) (s *sketch, est uint32) {
+	s = ks.stripeOf(hash)
+	est = ^uint32(0)
+	for r, c := range sketchIndexes(hash) {
+		if v := atomic.AddUint32(&s.count[r][c], 1); v < est {
+			est = v
+		}
+	}
+	return s, est
+}
+
+// missed counts a Load of key finding no value.
+func (ks *keyStats) missed(hash uintptr) {
+	s := ks.stripeOf(hash)
+	for r, c := range sketchIndexes(hash) {
+		atomic.AddUint32(&s.miss[r][c], 1)
+	}
+}
+
+// promote makes key, counted est times, a candidate of s if it is above
+// the least one. If keep is not nil, a new candidate is the key it
+// returns instead of key, and none if it returns false.
+func (s *sketch) promote(key interface{}, est uint32, keep func() (interface{}, bool)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	least := -1
+	for i := range s.top {
+		if s.top[i].Key == key {
+			s.top[i].Count = uint64(est)
+			s.updateMin()
+			return
+		}
+		if least < 0 || s.top[i].Count < s.top[least].Count {
+			least = i
+		}
+	}
+	if len(s.top) == keysTop && uint64(est) <= s.top[least].Count {
+		return
+	}
+	if keep != nil {
+		var ok bool
+		if key, ok = keep(); !ok {
+			return
+		}
+	}
+	if len(s.top) < keysTop {
+		s.top = append(s.top, KeyCount{Key: key, Count: uint64(est)})
+	} else {
+		s.top[least] = KeyCount{Key: key, Count: uint64(est)}
+	}
+	s.updateMin()
+}
+
+// storedKey returns the key of b equal to key, as stored in b.
+func (b *bucket) storedKey(key interface{}, hash uintptr) (stored interface{}, ok bool) {
+	b.rangeHash(func(k, _ interface{}, h uintptr) bool {
+		if h == hash && k == key {
+			stored, ok = k, true
+		}
+		return !ok
+	})
+	return stored, ok
+}
+
+// heapKey returns a copy of key sharing no memory with it, for a key
+// which may be on the stack of a caller. ok is false if key holds
+// pointers, chans, funcs or maps, which can't be copied.
+func heapKey(key interface{}) (dup interface{}, ok bool) {
+	if key == nil {
+		return nil, true
+	}
+	v := reflect.ValueOf(key)
+	c := reflect.New(v.Type()).Elem()
+	c.Set(v)
+	if !deepenKey(c) {
+		return nil, false
+	}
+	// c is addressable, Interface copies it to the heap
+	return c.Interface(), true
+}
+
+// deepenKey replaces the strings and interfaces of v, addressable, by
+// copies. It reports false if v holds a value which can't be copied.
+func deepenKey(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String:
+		v = settable(v)
+		v.SetString(string(append([]byte(nil), v.String()...)))
+	case reflect.Interface:
+		if v.IsNil() {
+			return true
+		}
+		v = settable(v)
+		c, ok := heapKey(v.Elem().Interface())
+		if !ok {
+			return false
+		}
+		v.Set(reflect.ValueOf(c))
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !deepenKey(v.Field(i)) {
+				return false
+			}
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if !deepenKey(v.Index(i)) {
+				return false
+			}
+		}
+	case reflect.Ptr, reflect.UnsafePointer, reflect.Chan, reflect.Func, reflect.Map, reflect.Slice:
+		return false
+	}
+	return true
+}
+
+// settable returns v, addressable, without the restrictions on the
+// unexported fields.
+func settable(v reflect.Value) reflect.Value {
+	return reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem()
+}
+
+// updateMin sets the least count of the full candidates, or 0.
+func (s *sketch) updateMin() {
+	if len(s.top) < keysTop {
+		return
+	}
+	least := s.top[0].Count
+	for _, kc := range s.top[1:] {
+		if kc.Count < least {
+			least = kc.Count
+		}
+	}
+	atomic.StoreUint32(&s.min, uint32(least))
+}
+
+func (s *sketch) estimate(rows *[sketchRows][sketchWidth]uint32, idx [sketchRows]uint32) uint32 {
+	est := ^uint32(0)
+	for r, c := range idx {
+		if v := atomic.LoadUint32(&rows[r][c]); v < est {
+			est = v
+		}
+	}
+	return est
+}
+
+// sketchIndexes returns the counter of each row for a key of hash.
+func sketchIndexes(hash uintptr) (idx [sketchRows]uint32) {
+	for r, seed := range sketchSeeds {
+		idx[r] = uint32((uint64(hash) * seed) >> (64 - sketchBits))
+	}
+	return idx
+}
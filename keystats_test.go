@@ -0,0 +1,74 @@
+package cmap_test
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestTopKeys(t *testing.T) {
+	m := cmap.New(cmap.WithKeyStats())
+	for i := 0; i < 10000; i++ {
+		m.Store(i, i)
+	}
+	for i := 0; i < 1000; i++ {
+		m.Load("hot")
+		m.Load(fmt.Sprint("warm", i%2))
+		m.Load(i)
+	}
+	m.Store("hot", 1)
+
+	top := m.TopKeys(3)
+	if len(top) != 3 || top[0].Key != "hot" {
+		t.Fatalf("TopKeys(3) = %v, want hot first", top)
+	}
+	if top[0].Count < 1001 || top[0].Misses < 1000 {
+		t.Fatalf("hot key counted %d times, %d misses", top[0].Count, top[0].Misses)
+	}
+	for _, kc := range top[1:] {
+		if kc.Key != "warm0" && kc.Key != "warm1" {
+			t.Fatalf("TopKeys(3) = %v, want the warm keys next", top)
+		}
+	}
+
+	m.ResetKeyStats()
+	if top := m.TopKeys(3); len(top) != 0 {
+		t.Fatalf("TopKeys = %v after ResetKeyStats", top)
+	}
+	if top := new(cmap.CMap).TopKeys(3); top != nil {
+		t.Fatalf("TopKeys = %v without WithKeyStats", top)
+	}
+}
+
+func TestTopKeysLoadCopies(t *testing.T) {
+	type key struct {
+		name string
+		v    interface{}
+	}
+	m := cmap.New(cmap.WithKeyStats())
+	m.Store(key{"stored", 1}, 1)
+	buf := []byte("missed")
+	for i := 0; i < 100; i++ {
+		// keys boxed on the stack, with strings in a stack buffer
+		m.Load(key{string(buf), string(buf[:3])})
+		m.Load(key{"stored", 1})
+	}
+	x := 0
+	for i := 0; i < 50; i++ {
+		m.Load(&x) // a pointer missed can't be copied
+	}
+	copy(buf, "XXXXXX")
+	runtime.GC()
+
+	top := m.TopKeys(3)
+	if len(top) != 2 {
+		t.Fatalf("TopKeys(3) = %v, want 2 keys", top)
+	}
+	for _, kc := range top {
+		if kc.Key != (key{"missed", "mis"}) && kc.Key != (key{"stored", 1}) {
+			t.Fatalf("TopKeys(3) = %v", top)
+		}
+	}
+}
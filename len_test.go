@@ -0,0 +1,31 @@
+package cmap_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/min1324/cmap"
+)
+
+func TestApproximateLen(t *testing.T) {
+	m := cmap.New(cmap.WithApproximateLen(time.Hour))
+	m.Store(1, 1)
+	if m.Len() != 1 {
+		t.Fatalf("Len() = %d, want 1", m.Len())
+	}
+	m.Store(2, 2)
+	if m.Len() != 1 {
+		t.Fatalf("Len() = %d, want the cached 1", m.Len())
+	}
+	if m.LenExact() != 2 {
+		t.Fatalf("LenExact() = %d, want 2", m.LenExact())
+	}
+
+	m = cmap.New(cmap.WithApproximateLen(time.Millisecond))
+	m.Len()
+	m.Store(1, 1)
+	time.Sleep(2 * time.Millisecond)
+	if m.Len() != 1 {
+		t.Fatalf("Len() = %d after the cache expired, want 1", m.Len())
+	}
+}
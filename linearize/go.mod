@@ -0,0 +1,10 @@
+module gitee.com/absir_admin/cmap/linearize
+
+go 1.18
+
+require (
+	gitee.com/absir_admin/cmap v0.0.0
+	github.com/anishathalye/porcupine v1.3.0
+)
+
+replace gitee.com/absir_admin/cmap => ../
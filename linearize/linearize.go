@@ -0,0 +1,221 @@
+// Package linearize records histories of concurrent operations on a map
+// and checks that they are linearizable: that every operation appears to
+// take effect at once, at some point between its call and its return.
+//
+// It is a test helper, in a module of its own so that the cmap module
+// keeps no dependencies. Check splits the history by key, each key being
+// an independent register, and checks the operations of each key with
+// porcupine.
+package linearize
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/anishathalye/porcupine"
+)
+
+// Map is the method set of the maps whose operations are recorded,
+// implemented by cmap.CMap, cmap.Map and sync.Map.
+type Map interface {
+	Load(key interface{}) (value interface{}, ok bool)
+	Store(key, value interface{})
+	LoadOrStore(key, value interface{}) (actual interface{}, loaded bool)
+	LoadAndDelete(key interface{}) (value interface{}, loaded bool)
+	Delete(key interface{})
+}
+
+// Kind is the kind of an operation.
+type Kind uint8
+
+const (
+	Load Kind = iota
+	Store
+	LoadOrStore
+	LoadAndDelete
+	Delete
+)
+
+var kindNames = [...]string{"Load", "Store", "LoadOrStore", "LoadAndDelete", "Delete"}
+
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return fmt.Sprintf("Kind(%d)", k)
+}
+
+// Input is an operation called on a map. Value is only used by Store and
+// LoadOrStore. Keys and values must be comparable.
+type Input struct {
+	Kind  Kind
+	Key   interface{}
+	Value interface{}
+}
+
+// Output is the result of an operation: the value and the ok or loaded
+// result, if the operation has them.
+type Output struct {
+	Value interface{}
+	OK    bool
+}
+
+// Operation is an operation of a history. Call and Return order the
+// operations in real time: an operation whose Return is less than the
+// Call of another one happened before it.
+type Operation struct {
+	Client int
+	Input  Input
+	Output Output
+	Call   int64
+	Return int64
+}
+
+func (op Operation) String() string {
+	return fmt.Sprintf("client %d [%d, %d]: %v(%v, %v) = %v, %v", op.Client, op.Call, op.Return,
+		op.Input.Kind, op.Input.Key, op.Input.Value, op.Output.Value, op.Output.OK)
+}
+
+// Exec calls the operation in on m.
+func Exec(m Map, in Input) (out Output) {
+	switch in.Kind {
+	case Load:
+		out.Value, out.OK = m.Load(in.Key)
+	case Store:
+		m.Store(in.Key, in.Value)
+	case LoadOrStore:
+		out.Value, out.OK = m.LoadOrStore(in.Key, in.Value)
+	case LoadAndDelete:
+		out.Value, out.OK = m.LoadAndDelete(in.Key)
+	case Delete:
+		m.Delete(in.Key)
+	default:
+		panic("linearize: unknown kind " + in.Kind.String())
+	}
+	return out
+}
+
+// A Recorder records the operations called on a map by concurrent
+// clients. The zero Recorder is ready to use.
+type Recorder struct {
+	clock int64
+	mu    sync.Mutex
+	ops   []Operation
+}
+
+// Exec calls in on m for client, and records it.
+func (r *Recorder) Exec(client int, m Map, in Input) Output {
+	call := atomic.AddInt64(&r.clock, 1)
+	out := Exec(m, in)
+	ret := atomic.AddInt64(&r.clock, 1)
+	r.mu.Lock()
+	r.ops = append(r.ops, Operation{client, in, out, call, ret})
+	r.mu.Unlock()
+	return out
+}
+
+// History returns the operations recorded so far.
+func (r *Recorder) History() []Operation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Operation(nil), r.ops...)
+}
+
+// Error is the error of Check for a history which is not linearizable.
+type Error struct {
+	Key interface{}
+	Ops []Operation // the operations on Key, by call time
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("linearize: %d operations on key %v are not linearizable", len(e.Ops), e.Key)
+}
+
+// Check returns nil if history is linearizable for a map starting empty,
+// and an *Error for a key whose operations are not otherwise.
+func Check(history []Operation) error {
+	var keys []interface{}
+	byKey := make(map[interface{}][]Operation)
+	for _, op := range history {
+		if _, ok := byKey[op.Input.Key]; !ok {
+			keys = append(keys, op.Input.Key)
+		}
+		byKey[op.Input.Key] = append(byKey[op.Input.Key], op)
+	}
+	for _, k := range keys {
+		ops := byKey[k]
+		sort.Slice(ops, func(i, j int) bool { return ops[i].Call < ops[j].Call })
+		if !checkKey(ops) {
+			return &Error{Key: k, Ops: ops}
+		}
+	}
+	return nil
+}
+
+// register is the state of a key.
+type register struct {
+	value   interface{}
+	present bool
+}
+
+// model is the porcupine model of a key: a register, which is absent
+// until stored.
+var model = porcupine.Model{
+	Init: func() interface{} { return register{} },
+	Step: func(state, input, output interface{}) (bool, interface{}) {
+		next, ok := step(state.(register), input.(Input), output.(Output))
+		return ok, next
+	},
+	DescribeOperation: func(input, output interface{}) string {
+		in, out := input.(Input), output.(Output)
+		return fmt.Sprintf("%v(%v, %v) = %v, %v", in.Kind, in.Key, in.Value, out.Value, out.OK)
+	},
+	DescribeState: func(state interface{}) string {
+		r := state.(register)
+		if !r.present {
+			return "absent"
+		}
+		return fmt.Sprint(r.value)
+	},
+}
+
+// step applies the operation in, out to r, ok is false if it could not
+// have returned out from r.
+func step(r register, in Input, out Output) (next register, ok bool) {
+	switch in.Kind {
+	case Load:
+		return r, out.OK == r.present && (!r.present || out.Value == r.value)
+	case Store:
+		return register{in.Value, true}, true
+	case LoadOrStore:
+		if r.present {
+			return r, out.OK && out.Value == r.value
+		}
+		return register{in.Value, true}, !out.OK && out.Value == in.Value
+	case LoadAndDelete:
+		if r.present {
+			return register{}, out.OK && out.Value == r.value
+		}
+		return r, !out.OK
+	case Delete:
+		return register{}, true
+	}
+	return r, false
+}
+
+// checkKey reports whether the operations on one key are linearizable.
+func checkKey(ops []Operation) bool {
+	history := make([]porcupine.Operation, len(ops))
+	for i, op := range ops {
+		history[i] = porcupine.Operation{
+			ClientId: op.Client,
+			Input:    op.Input,
+			Call:     op.Call,
+			Output:   op.Output,
+			Return:   op.Return,
+		}
+	}
+	return porcupine.CheckOperations(model, history)
+}
@@ -0,0 +1,212 @@
+package linearize_test
+
+import (
+	"errors"
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"gitee.com/absir_admin/cmap"
+	"gitee.com/absir_admin/cmap/linearize"
+)
+
+func op(client int, kind linearize.Kind, key, value interface{}, out interface{}, ok bool, call, ret int64) linearize.Operation {
+	return linearize.Operation{
+		Client: client,
+		Input:  linearize.Input{Kind: kind, Key: key, Value: value},
+		Output: linearize.Output{Value: out, OK: ok},
+		Call:   call,
+		Return: ret,
+	}
+}
+
+func TestCheck(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		ops  []linearize.Operation
+		ok   bool
+	}{
+		{"sequential", []linearize.Operation{
+			op(0, linearize.Store, "a", 1, nil, false, 1, 2),
+			op(0, linearize.Load, "a", nil, 1, true, 3, 4),
+			op(0, linearize.LoadAndDelete, "a", nil, 1, true, 5, 6),
+			op(0, linearize.LoadOrStore, "a", 2, 2, false, 7, 8),
+		}, true},
+		{"concurrent", []linearize.Operation{
+			// the load overlaps both stores, it can see either
+			op(0, linearize.Store, "a", 1, nil, false, 1, 4),
+			op(1, linearize.Store, "a", 2, nil, false, 2, 5),
+			op(2, linearize.Load, "a", nil, 1, true, 3, 6),
+		}, true},
+		{"stale load", []linearize.Operation{
+			op(0, linearize.Store, "a", 1, nil, false, 1, 2),
+			op(0, linearize.Store, "a", 2, nil, false, 3, 4),
+			op(1, linearize.Load, "a", nil, 1, true, 5, 6),
+		}, false},
+		{"lost delete", []linearize.Operation{
+			op(0, linearize.Store, "a", 1, nil, false, 1, 2),
+			op(0, linearize.Delete, "a", nil, nil, false, 3, 4),
+			op(1, linearize.Load, "b", nil, nil, false, 3, 4),
+			op(1, linearize.LoadOrStore, "a", 2, 1, true, 5, 6),
+		}, false},
+		{"double delete", []linearize.Operation{
+			op(0, linearize.Store, "a", 1, nil, false, 1, 2),
+			op(0, linearize.LoadAndDelete, "a", nil, 1, true, 3, 5),
+			op(1, linearize.LoadAndDelete, "a", nil, 1, true, 4, 6),
+		}, false},
+		{"load from the future", []linearize.Operation{
+			// the store is called after the load returned
+			op(0, linearize.Load, "a", nil, 1, true, 1, 2),
+			op(1, linearize.Store, "a", 1, nil, false, 3, 4),
+		}, false},
+		{"value never stored", []linearize.Operation{
+			op(0, linearize.Store, "a", 1, nil, false, 1, 3),
+			op(1, linearize.Load, "a", nil, 2, true, 2, 4),
+		}, false},
+		{"double store", []linearize.Operation{
+			// overlapping, but only one can store
+			op(0, linearize.LoadOrStore, "a", 1, 1, false, 1, 3),
+			op(1, linearize.LoadOrStore, "a", 2, 2, false, 2, 4),
+		}, false},
+		{"flickering", []linearize.Operation{
+			// a store of 2 overlaps both loads, but once seen, 2 stays
+			op(0, linearize.Store, "a", 1, nil, false, 1, 2),
+			op(1, linearize.Store, "a", 2, nil, false, 3, 10),
+			op(2, linearize.Load, "a", nil, 2, true, 4, 5),
+			op(2, linearize.Load, "a", nil, 1, true, 6, 7),
+		}, false},
+	} {
+		err := linearize.Check(tc.ops)
+		if (err == nil) != tc.ok {
+			t.Errorf("%s: Check() = %v, want ok %v", tc.name, err, tc.ok)
+		}
+		var lerr *linearize.Error
+		if err != nil && (!errors.As(err, &lerr) || lerr.Key != "a") {
+			t.Errorf("%s: Check() = %#v, want an *Error for key a", tc.name, err)
+		}
+	}
+}
+
+// run checks the history of ops operations of each of 8 goroutines on m,
+// on a few keys, while resize is called in a loop.
+func run(t *testing.T, m linearize.Map, resize func(i int)) {
+	ops := 500
+	if testing.Short() {
+		ops = 100
+	}
+	if err := linearize.Check(record(m, 8, ops, resize)); err != nil {
+		for _, op := range err.(*linearize.Error).Ops {
+			t.Log(op)
+		}
+		t.Fatal(err)
+	}
+}
+
+// record returns the history of ops operations of each of clients
+// goroutines on m, on a few keys, while resize is called in a loop.
+func record(m linearize.Map, clients, ops int, resize func(i int)) []linearize.Operation {
+	var r linearize.Recorder
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	if resize != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+					resize(i)
+				}
+			}
+		}()
+	}
+	var cwg sync.WaitGroup
+	for c := 0; c < clients; c++ {
+		cwg.Add(1)
+		go func(c int) {
+			defer cwg.Done()
+			rnd := rand.New(rand.NewSource(int64(c)))
+			for i := 0; i < ops; i++ {
+				in := linearize.Input{
+					Kind:  linearize.Kind(rnd.Intn(5)),
+					Key:   rnd.Intn(4),
+					Value: c*ops + i, // unique, a value seen is tied to its store
+				}
+				r.Exec(c, m, in)
+			}
+		}(c)
+	}
+	cwg.Wait()
+	close(done)
+	wg.Wait()
+	return r.History()
+}
+
+// lossyMap drops one Store in 8, like a resize losing a write.
+type lossyMap struct {
+	sync.Map
+	n int64
+}
+
+func (m *lossyMap) Store(key, value interface{}) {
+	if atomic.AddInt64(&m.n, 1)%8 != 0 {
+		m.Map.Store(key, value)
+	}
+}
+
+// racyMap's LoadOrStore is a Load then a Store, so that racing calls may
+// both store.
+type racyMap struct {
+	sync.Map
+}
+
+func (m *racyMap) LoadOrStore(key, value interface{}) (actual interface{}, loaded bool) {
+	if v, ok := m.Load(key); ok {
+		return v, true
+	}
+	runtime.Gosched()
+	m.Store(key, value)
+	return value, false
+}
+
+// TestCheckBroken checks that Check rejects the histories of broken maps,
+// recorded like those of the maps tested.
+func TestCheckBroken(t *testing.T) {
+	// one client: the lost writes are seen by the next loads
+	if err := linearize.Check(record(new(lossyMap), 1, 500, nil)); err == nil {
+		t.Errorf("Check accepted a map losing writes")
+	}
+	// the races are not certain, but frequent
+	for i := 0; ; i++ {
+		if linearize.Check(record(new(racyMap), 8, 200, nil)) != nil {
+			break
+		}
+		if i == 20 {
+			t.Fatalf("Check accepted %d histories of a racy LoadOrStore", i+1)
+		}
+	}
+}
+
+func TestCMap(t *testing.T) {
+	t.Run("resizing", func(t *testing.T) {
+		var m cmap.CMap
+		// filler keys, so that every resize moves entries
+		for i := 0; i < 1000; i++ {
+			m.Store(-i-1, i)
+		}
+		run(t, &m, func(i int) {
+			m.ForceResize(uint8(4 + i%4))
+			m.Store(-1, i) // evacuates a group
+		})
+	})
+	t.Run("swiss", func(t *testing.T) {
+		run(t, cmap.New(cmap.WithSwissBuckets()), nil)
+	})
+	t.Run("Map", func(t *testing.T) {
+		run(t, new(cmap.Map), nil)
+	})
+}
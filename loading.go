@@ -0,0 +1,165 @@
+package cmap
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNotFound is returned by a loader for a key without value, see
+// WithNegativeTTL, and by the typed loads of an absent key, see LoadAs.
+var ErrNotFound = errors.New("cmap: key not found")
+
+// LoadingMap is a CMap filled on demand by a loader function, which is
+// called once for concurrent misses of the same key.
+type LoadingMap struct {
+	m      CMap
+	calls  Group // loader calls in flight
+	loads  CMap  // key -> *loadCall, the loader calls in flight
+	loader func(key interface{}) (interface{}, error)
+
+	misses *CMap // keys not found, see WithNegativeTTL
+	negTTL time.Duration
+}
+
+// loadCall is a loader call in flight, made stale by a Store or Delete of
+// its key meanwhile: its value is then returned, but not stored.
+type loadCall struct {
+	stale uint32
+}
+
+// LoadingOption configures a LoadingMap created by NewLoadingMap.
+type LoadingOption func(*LoadingMap)
+
+// WithNegativeTTL makes the map remember for d the keys for which the
+// loader returned ErrNotFound, or an error wrapping it: Get returns
+// ErrNotFound for them without calling the loader again until d elapsed,
+// or the key is stored or deleted. Call Close to release the expired
+// keys.
+func WithNegativeTTL(d time.Duration) LoadingOption {
+	return func(l *LoadingMap) {
+		if d > 0 {
+			l.negTTL = d
+		}
+	}
+}
+
+// NewLoadingMap returns an empty LoadingMap using loader to load the
+// value of missing keys.
+func NewLoadingMap(loader func(key interface{}) (interface{}, error), opts ...LoadingOption) *LoadingMap {
+	l := &LoadingMap{loader: loader}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if l.negTTL > 0 {
+		l.misses = New(WithJanitor(l.negTTL))
+	}
+	return l
+}
+
+// Get returns the value of key, calling the loader and storing its result
+// if key is missing. Concurrent Gets of a missing key wait for a single
+// loader call and share its result. An error of the loader is returned,
+// and nothing is stored.
+func (l *LoadingMap) Get(key interface{}) (value interface{}, err error) {
+	if v, ok := l.m.Load(key); ok {
+		return v, nil
+	}
+	if l.missed(key) {
+		return nil, ErrNotFound
+	}
+	value, err, _ = l.calls.Do(key, func() (interface{}, error) {
+		return l.load(key)
+	})
+	return value, err
+}
+
+// load calls the loader for key, in a call of l.calls.
+func (l *LoadingMap) load(key interface{}) (value interface{}, err error) {
+	// another call may have stored key before we got in
+	if v, ok := l.m.Load(key); ok {
+		return v, nil
+	}
+	c := new(loadCall)
+	l.loads.Store(key, c)
+	defer l.loads.CompareAndDelete(key, c)
+	value, err = l.loader(key)
+	if err == nil {
+		// stored before the call leaves, so later Gets find it, unless
+		// key was stored or deleted meanwhile. stale is checked with the
+		// bucket of key locked, so a Delete marking it stale deletes the
+		// value stored otherwise.
+		l.m.compute(key, func(cur interface{}, loaded bool) (interface{}, action) {
+			if loaded {
+				value = cur
+				return nil, actKeep
+			}
+			if atomic.LoadUint32(&c.stale) != 0 {
+				return nil, actKeep
+			}
+			return value, actStore
+		})
+	} else if l.misses != nil && errors.Is(err, ErrNotFound) {
+		l.misses.StoreWithTTL(key, struct{}{}, l.negTTL)
+	}
+	return value, err
+}
+
+// invalidate makes the loader call in flight for key stale, and the next
+// Gets of key call the loader again.
+func (l *LoadingMap) invalidate(key interface{}) {
+	l.calls.Forget(key)
+	if c, ok := l.loads.Load(key); ok {
+		atomic.StoreUint32(&c.(*loadCall).stale, 1)
+	}
+	if l.misses != nil {
+		l.misses.Delete(key)
+	}
+}
+
+// missed reports whether key was not found by the loader less than the
+// negative ttl ago.
+func (l *LoadingMap) missed(key interface{}) bool {
+	if l.misses == nil {
+		return false
+	}
+	_, ok := l.misses.Load(key)
+	return ok
+}
+
+// Load returns the value of key if present, without loading it.
+func (l *LoadingMap) Load(key interface{}) (value interface{}, ok bool) {
+	return l.m.Load(key)
+}
+
+// Store sets the value for a key. A loader call in flight for key is
+// not stored.
+func (l *LoadingMap) Store(key, value interface{}) {
+	l.invalidate(key)
+	l.m.Store(key, value)
+}
+
+// Delete deletes the value for a key, the next Get loads it again. A
+// loader call in flight for key is not stored.
+func (l *LoadingMap) Delete(key interface{}) {
+	l.invalidate(key)
+	l.m.Delete(key)
+}
+
+// Close stops the janitor removing the expired keys of WithNegativeTTL,
+// and drops them. The map is still usable, without negative caching.
+func (l *LoadingMap) Close() {
+	if l.misses != nil {
+		l.misses.Close()
+	}
+}
+
+// Len returns the number of loaded elements.
+func (l *LoadingMap) Len() int {
+	return l.m.Len()
+}
+
+// Map returns the map holding the loaded values.
+func (l *LoadingMap) Map() *CMap {
+	return &l.m
+}
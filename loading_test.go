@@ -0,0 +1,132 @@
+package cmap_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/min1324/cmap"
+)
+
+func TestLoadingMap(t *testing.T) {
+	var calls int32
+	errOdd := errors.New("odd key")
+	l := cmap.NewLoadingMap(func(key interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(10 * time.Millisecond)
+		if key.(int)%2 != 0 {
+			return nil, errOdd
+		}
+		return key.(int) * 10, nil
+	})
+
+	var wg sync.WaitGroup
+	for g := 0; g < 10; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := l.Get(2); err != nil || v != 20 {
+				t.Errorf("Get(2) = %v, %v", v, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("loader called %d times for concurrent misses, want 1", n)
+	}
+	if v, ok := l.Load(2); !ok || v != 20 {
+		t.Fatalf("Load(2) = %v, %v", v, ok)
+	}
+
+	if _, err := l.Get(3); err != errOdd {
+		t.Fatalf("Get(3) error = %v, want %v", err, errOdd)
+	}
+	if _, ok := l.Load(3); ok || l.Len() != 1 {
+		t.Fatalf("a failed load was stored")
+	}
+}
+
+func TestLoadingMapNegativeTTL(t *testing.T) {
+	var calls int32
+	l := cmap.NewLoadingMap(func(key interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, fmt.Errorf("no row for %v: %w", key, cmap.ErrNotFound)
+	}, cmap.WithNegativeTTL(50*time.Millisecond))
+	defer l.Close()
+
+	for i := 0; i < 10; i++ {
+		if _, err := l.Get("missing"); !errors.Is(err, cmap.ErrNotFound) {
+			t.Fatalf("Get = %v, want ErrNotFound", err)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("loader called %d times for a cached miss, want 1", n)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	l.Get("missing")
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf("loader called %d times once the miss expired, want 2", n)
+	}
+
+	l.Store("missing", 1)
+	if v, err := l.Get("missing"); err != nil || v != 1 {
+		t.Fatalf("Get = %v, %v after Store", v, err)
+	}
+	l.Delete("missing")
+	l.Get("missing")
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Fatalf("loader called %d times after Delete, want 3", n)
+	}
+}
+
+func TestLoadingMapDeleteDuringLoad(t *testing.T) {
+	var calls int32
+	started, release := make(chan struct{}), make(chan struct{})
+	l := cmap.NewLoadingMap(func(key interface{}) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+			<-release
+			return "stale", nil
+		}
+		return "fresh", nil
+	})
+
+	done := make(chan interface{})
+	go func() {
+		v, _ := l.Get("a")
+		done <- v
+	}()
+	<-started
+	l.Delete("a")
+	// a new call, not waiting for the stale one
+	if v, err := l.Get("a"); err != nil || v != "fresh" {
+		t.Fatalf("Get(a) after Delete = %v, %v; want fresh", v, err)
+	}
+	l.Delete("a")
+	close(release)
+	if v := <-done; v != "stale" {
+		t.Fatalf("Get(a) = %v, want stale", v)
+	}
+	if v, ok := l.Load("a"); ok {
+		t.Fatalf("Load(a) = %v, the stale value was stored after Delete", v)
+	}
+
+	// a Store during a load wins over it
+	started, release = make(chan struct{}), make(chan struct{})
+	atomic.StoreInt32(&calls, 0)
+	go func() {
+		v, _ := l.Get("b")
+		done <- v
+	}()
+	<-started
+	l.Store("b", "stored")
+	close(release)
+	<-done
+	if v, ok := l.Load("b"); !ok || v != "stored" {
+		t.Fatalf("Load(b) = %v, %v; want stored", v, ok)
+	}
+}
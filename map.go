@@ -5,7 +5,6 @@ package cmap
 // license that can be found in the LICENSE file.
 
 import (
-	"runtime"
 	"sync"
 	"sync/atomic"
 	"unsafe"
@@ -59,9 +58,6 @@ type Map struct {
 	// map, the dirty map will be promoted to the read map (in the unamended
 	// state) and the next store to the map will make a new dirty copy.
 	misses int
-
-	// frozen map,can't store or delete et.
-	freeze uint32
 }
 
 // readOnly is an immutable struct stored atomically in the Map.read field.
@@ -95,10 +91,14 @@ type entry struct {
 	// only after first setting m.dirty[key] = e so that lookups using the dirty
 	// map find the entry.
 	p unsafe.Pointer // *interface{}
+
+	// hash of the key, given by the buckets of a CMap so that resizing
+	// doesn't hash the keys again. It never changes, like the key.
+	hash uintptr
 }
 
-func newEntry(i interface{}) *entry {
-	return &entry{p: unsafe.Pointer(&i)}
+func newEntry(i interface{}, hash uintptr) *entry {
+	return &entry{p: unsafe.Pointer(&i), hash: hash}
 }
 
 // Load returns the value stored in the map for a key, or nil if no
@@ -139,11 +139,15 @@ func (e *entry) load() (value interface{}, ok bool) {
 
 // Store sets the value for a key.
 func (m *Map) Store(key, value interface{}) {
+	m.storeHash(key, value, 0)
+}
+
+// storeHash is Store, recording hash as the hash of key if it is new.
+func (m *Map) storeHash(key, value interface{}, hash uintptr) {
 	read, _ := m.read.Load().(readOnly)
 	if e, ok := read.m[key]; ok && e.tryStore(&value) {
 		return
 	}
-	m.waitUnfreeze()
 	m.mu.Lock()
 	read, _ = m.read.Load().(readOnly)
 	if e, ok := read.m[key]; ok {
@@ -162,7 +166,7 @@ func (m *Map) Store(key, value interface{}) {
 			m.dirtyLocked()
 			m.read.Store(readOnly{m: read.m, amended: true})
 		}
-		m.dirty[key] = newEntry(value)
+		m.dirty[key] = newEntry(value, hash)
 	}
 	m.mu.Unlock()
 }
@@ -202,6 +206,12 @@ func (e *entry) storeLocked(i *interface{}) {
 // Otherwise, it stores and returns the given value.
 // The loaded result is true if the value was loaded, false if stored.
 func (m *Map) LoadOrStore(key, value interface{}) (actual interface{}, loaded bool) {
+	return m.loadOrStoreHash(key, value, 0)
+}
+
+// loadOrStoreHash is LoadOrStore, recording hash as the hash of key if it
+// is new.
+func (m *Map) loadOrStoreHash(key, value interface{}, hash uintptr) (actual interface{}, loaded bool) {
 	// Avoid locking if it's a clean hit.
 	read, _ := m.read.Load().(readOnly)
 	if e, ok := read.m[key]; ok {
@@ -210,7 +220,6 @@ func (m *Map) LoadOrStore(key, value interface{}) (actual interface{}, loaded bo
 			return actual, loaded
 		}
 	}
-	m.waitUnfreeze()
 	m.mu.Lock()
 	read, _ = m.read.Load().(readOnly)
 	if e, ok := read.m[key]; ok {
@@ -228,7 +237,7 @@ func (m *Map) LoadOrStore(key, value interface{}) (actual interface{}, loaded bo
 			m.dirtyLocked()
 			m.read.Store(readOnly{m: read.m, amended: true})
 		}
-		m.dirty[key] = newEntry(value)
+		m.dirty[key] = newEntry(value, hash)
 		actual, loaded = value, false
 	}
 	m.mu.Unlock()
@@ -274,7 +283,6 @@ func (m *Map) LoadAndDelete(key interface{}) (value interface{}, loaded bool) {
 	read, _ := m.read.Load().(readOnly)
 	e, ok := read.m[key]
 	if !ok && read.amended {
-		m.waitUnfreeze()
 		m.mu.Lock()
 		read, _ = m.read.Load().(readOnly)
 		e, ok = read.m[key]
@@ -311,6 +319,169 @@ func (e *entry) delete() (value interface{}, ok bool) {
 	}
 }
 
+// Swap swaps the value for a key and returns the previous value if any.
+// The loaded result reports whether the key was present.
+func (m *Map) Swap(key, value interface{}) (previous interface{}, loaded bool) {
+	return m.swapHash(key, value, 0)
+}
+
+// swapHash is Swap, recording hash as the hash of key if it is new.
+func (m *Map) swapHash(key, value interface{}, hash uintptr) (previous interface{}, loaded bool) {
+	read, _ := m.read.Load().(readOnly)
+	if e, ok := read.m[key]; ok {
+		if v, ok := e.trySwap(&value); ok {
+			if v == nil {
+				return nil, false
+			}
+			return *v, true
+		}
+	}
+
+	m.mu.Lock()
+	read, _ = m.read.Load().(readOnly)
+	if e, ok := read.m[key]; ok {
+		if e.unexpungeLocked() {
+			m.dirty[key] = e
+		}
+		if v := e.swapLocked(&value); v != nil {
+			loaded = true
+			previous = *v
+		}
+	} else if e, ok := m.dirty[key]; ok {
+		if v := e.swapLocked(&value); v != nil {
+			loaded = true
+			previous = *v
+		}
+	} else {
+		if !read.amended {
+			// We're adding the first new key to the dirty map.
+			// Make sure it is allocated and mark the read-only map as incomplete.
+			m.dirtyLocked()
+			m.read.Store(readOnly{m: read.m, amended: true})
+		}
+		m.dirty[key] = newEntry(value, hash)
+	}
+	m.mu.Unlock()
+	return previous, loaded
+}
+
+// trySwap swaps a value if the entry has not been expunged.
+//
+// If the entry is expunged, trySwap returns false and leaves the entry
+// unchanged.
+func (e *entry) trySwap(i *interface{}) (*interface{}, bool) {
+	for {
+		p := atomic.LoadPointer(&e.p)
+		if p == expunged {
+			return nil, false
+		}
+		if atomic.CompareAndSwapPointer(&e.p, p, unsafe.Pointer(i)) {
+			return (*interface{})(p), true
+		}
+	}
+}
+
+// swapLocked unconditionally swaps a value into the entry.
+//
+// The entry must be known not to be expunged.
+func (e *entry) swapLocked(i *interface{}) *interface{} {
+	return (*interface{})(atomic.SwapPointer(&e.p, unsafe.Pointer(i)))
+}
+
+// CompareAndSwap swaps the old and new values for key
+// if the value stored in the map is equal to old.
+// The old value must be of a comparable type.
+func (m *Map) CompareAndSwap(key, old, new interface{}) bool {
+	read, _ := m.read.Load().(readOnly)
+	if e, ok := read.m[key]; ok {
+		return e.tryCompareAndSwap(old, new)
+	} else if !read.amended {
+		return false // No existing value for key.
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	read, _ = m.read.Load().(readOnly)
+	swapped := false
+	if e, ok := read.m[key]; ok {
+		swapped = e.tryCompareAndSwap(old, new)
+	} else if e, ok := m.dirty[key]; ok {
+		swapped = e.tryCompareAndSwap(old, new)
+		// We needed to lock mu in order to load the entry for key,
+		// and the operation didn't change the set of keys in the map
+		// (so it would be made more efficient by promoting the dirty
+		// map to read-only).
+		// Count it as a miss so that we will eventually switch to the
+		// more efficient steady state.
+		m.missLocked()
+	}
+	return swapped
+}
+
+// tryCompareAndSwap compare the entry with the given old value and swaps
+// it with a new value if the entry is equal to the old value, and the entry
+// has not been expunged.
+//
+// If the entry is expunged, tryCompareAndSwap returns false and leaves
+// the entry unchanged.
+func (e *entry) tryCompareAndSwap(old, new interface{}) bool {
+	p := atomic.LoadPointer(&e.p)
+	if p == nil || p == expunged || *(*interface{})(p) != old {
+		return false
+	}
+
+	// Copy the interface after the first load to make this method more amenable
+	// to escape analysis: if the comparison fails from the start, we shouldn't
+	// bother heap-allocating an interface value to store.
+	nc := new
+	for {
+		if atomic.CompareAndSwapPointer(&e.p, p, unsafe.Pointer(&nc)) {
+			return true
+		}
+		p = atomic.LoadPointer(&e.p)
+		if p == nil || p == expunged || *(*interface{})(p) != old {
+			return false
+		}
+	}
+}
+
+// CompareAndDelete deletes the entry for key if its value is equal to old.
+// The old value must be of a comparable type.
+//
+// If there is no current value for key in the map, CompareAndDelete
+// returns false (even if the old value is the nil interface value).
+func (m *Map) CompareAndDelete(key, old interface{}) (deleted bool) {
+	read, _ := m.read.Load().(readOnly)
+	e, ok := read.m[key]
+	if !ok && read.amended {
+		m.mu.Lock()
+		read, _ = m.read.Load().(readOnly)
+		e, ok = read.m[key]
+		if !ok && read.amended {
+			e, ok = m.dirty[key]
+			// Don't delete key from m.dirty: we still need to do the “compare” part
+			// of the operation. The entry will eventually be expunged when the
+			// dirty map is promoted to the read map.
+			//
+			// Regardless of whether the entry was present, record a miss: this key
+			// will take the slow path until the dirty map is promoted to the read
+			// map.
+			m.missLocked()
+		}
+		m.mu.Unlock()
+	}
+	for ok {
+		p := atomic.LoadPointer(&e.p)
+		if p == nil || p == expunged || *(*interface{})(p) != old {
+			return false
+		}
+		if atomic.CompareAndSwapPointer(&e.p, p, nil) {
+			return true
+		}
+	}
+	return false
+}
+
 // Range calls f sequentially for each key and value present in the map.
 // If f returns false, range stops the iteration.
 //
@@ -322,6 +493,13 @@ func (e *entry) delete() (value interface{}, ok bool) {
 // Range may be O(N) with the number of elements in the map even if f returns
 // false after a constant number of calls.
 func (m *Map) Range(f func(key, value interface{}) bool) bool {
+	return m.rangeHash(func(key, value interface{}, _ uintptr) bool {
+		return f(key, value)
+	})
+}
+
+// rangeHash is Range, passing f the hash recorded for each key.
+func (m *Map) rangeHash(f func(key, value interface{}, hash uintptr) bool) bool {
 	// We need to be able to iterate over all of the keys that were already
 	// present at the start of the call to Range.
 	// If read.amended is false, then read.m satisfies that property without
@@ -348,7 +526,7 @@ func (m *Map) Range(f func(key, value interface{}) bool) bool {
 		if !ok {
 			continue
 		}
-		if !f(k, v) {
+		if !f(k, v, e.hash) {
 			return false
 		}
 	}
@@ -366,6 +544,24 @@ func (m *Map) missLocked() {
 	m.misses = 0
 }
 
+// reserve sizes the dirty map for n entries, if it is not allocated yet.
+func (m *Map) reserve(n int) {
+	m.mu.Lock()
+	if m.dirty == nil {
+		read, _ := m.read.Load().(readOnly)
+		if n < len(read.m) {
+			n = len(read.m)
+		}
+		m.dirty = make(map[interface{}]*entry, n)
+		for k, e := range read.m {
+			if !e.tryExpungeLocked() {
+				m.dirty[k] = e
+			}
+		}
+	}
+	m.mu.Unlock()
+}
+
 func (m *Map) dirtyLocked() {
 	if m.dirty != nil {
 		return
@@ -390,49 +586,3 @@ func (e *entry) tryExpungeLocked() (isExpunged bool) {
 	}
 	return p == expunged
 }
-
-func (m *Map) frozen() bool {
-	return atomic.LoadUint32(&m.freeze) == 1
-}
-
-func (m *Map) waitUnfreeze() {
-	for m.frozen() {
-		runtime.Gosched()
-	}
-}
-
-func (m *Map) walkLocketInFreeze(f func(key, value interface{}) bool) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	atomic.StoreUint32(&m.freeze, 1)
-	defer atomic.StoreUint32(&m.freeze, 0)
-
-	read, _ := m.read.Load().(readOnly)
-	if read.amended {
-		read, _ = m.read.Load().(readOnly)
-		if read.amended {
-			read = readOnly{m: m.dirty}
-			m.read.Store(read)
-			m.dirty = nil
-			m.misses = 0
-		}
-	}
-
-	for k, e := range read.m {
-		v, ok := e.load()
-		if !ok {
-			continue
-		}
-		if !f(k, v) {
-			break
-		}
-	}
-}
-
-func (m *Map) deleteLocked(key interface{}) {
-	e, ok := m.dirty[key]
-	delete(m.dirty, key)
-	if ok {
-		e.delete()
-	}
-}
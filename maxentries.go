@@ -0,0 +1,48 @@
+package cmap
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrFull is returned by StoreErr for a new key once the map holds the
+// entries allowed by WithMaxEntries.
+var ErrFull = errors.New("cmap: map full")
+
+// WithMaxEntries limits the map to n entries: once it holds n, the writes
+// of new keys fail, while the keys present can still be written. StoreErr
+// returns ErrFull, LoadOrStore, Update and TryStore leave the key absent,
+// DoAtomic returns ErrFull without applying its writes, and Store and the
+// other writes do nothing. Expired entries count until they are removed.
+//
+// Unlike a limit checked against Len, the entries are counted as they are
+// written, so that concurrent writers never go past n. The map does not
+// evict to make room: use Cache for that.
+func WithMaxEntries(n int) Option {
+	return func(m *CMap) {
+		if n > 0 {
+			m.maxEntries = int64(n)
+		}
+	}
+}
+
+// admit takes n slots for new entries, and reports whether the map had
+// room for them.
+func (m *CMap) admit(n int64) bool {
+	if m.maxEntries == 0 || n <= 0 {
+		return true
+	}
+	if atomic.AddInt64(&m.entries, n) <= m.maxEntries {
+		return true
+	}
+	atomic.AddInt64(&m.entries, -n)
+	return false
+}
+
+// release gives back n slots taken by admit, for entries removed or never
+// stored.
+func (m *CMap) release(n int64) {
+	if m.maxEntries != 0 && n > 0 {
+		atomic.AddInt64(&m.entries, -n)
+	}
+}
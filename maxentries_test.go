@@ -0,0 +1,111 @@
+package cmap_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/min1324/cmap"
+)
+
+func TestMaxEntries(t *testing.T) {
+	m := cmap.New(cmap.WithMaxEntries(3))
+	for i := 0; i < 3; i++ {
+		if err := m.StoreErr(i, i); err != nil {
+			t.Fatalf("StoreErr(%d) = %v", i, err)
+		}
+	}
+	if err := m.StoreErr(3, 3); err != cmap.ErrFull {
+		t.Fatalf("StoreErr on a full map = %v, want ErrFull", err)
+	}
+	if err := m.StoreErr(0, 10); err != nil {
+		t.Fatalf("StoreErr of a present key = %v, want nil", err)
+	}
+	m.Store(3, 3)
+	if v, loaded := m.LoadOrStore(3, 3); loaded || v != nil {
+		t.Fatalf("LoadOrStore(3) = %v, %v on a full map; want nil, false", v, loaded)
+	}
+	if v, ok := m.LoadOrStore(1, 11); !ok || v != 1 {
+		t.Fatalf("LoadOrStore(1) = %v, %v; want 1, true", v, ok)
+	}
+	if _, ok := m.Update(3, func(interface{}, bool) (interface{}, bool) { return 3, false }); ok {
+		t.Fatal("Update stored a new key into a full map")
+	}
+	if m.TryStore(3, 3) {
+		t.Fatal("TryStore stored a new key into a full map")
+	}
+	err := m.DoAtomic([]interface{}{2, 3, 4}, func(tx cmap.TxView) error {
+		tx.Set(3, 3)
+		tx.Set(4, 4)
+		return nil
+	})
+	if err != cmap.ErrFull {
+		t.Fatalf("DoAtomic = %v, want ErrFull", err)
+	}
+	if n := m.Len(); n != 3 {
+		t.Fatalf("Len() = %d, want 3", n)
+	}
+
+	// a transaction freeing as many entries as it adds fits
+	err = m.DoAtomic([]interface{}{2, 3}, func(tx cmap.TxView) error {
+		tx.Delete(2)
+		tx.Set(3, 3)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("DoAtomic = %v, want nil", err)
+	}
+	m.Delete(3)
+	if err := m.StoreErr(4, 4); err != nil {
+		t.Fatalf("StoreErr after a delete = %v, want nil", err)
+	}
+
+	m.Delete(4)
+	m.StoreWithTTL(5, 5, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if err := m.StoreErr(6, 6); err != cmap.ErrFull {
+		t.Fatalf("StoreErr = %v, want ErrFull until the expired key is removed", err)
+	}
+	m.DeleteExpired()
+	if err := m.StoreErr(6, 6); err != nil {
+		t.Fatalf("StoreErr after DeleteExpired = %v, want nil", err)
+	}
+}
+
+func TestMaxEntriesConcurrent(t *testing.T) {
+	const max, goroutines, keys = 100, 8, 1000
+	m := cmap.New(cmap.WithMaxEntries(max))
+	var stored int64
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < keys; i++ {
+				if m.StoreErr(g*keys+i, i) == nil {
+					atomic.AddInt64(&stored, 1)
+				}
+				if i%3 == 0 {
+					m.Delete(g*keys + i - 1)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if n := m.Len(); n > max {
+		t.Fatalf("Len() = %d, want at most %d", n, max)
+	}
+	m.Range(func(key, _ interface{}) bool {
+		m.Delete(key)
+		return true
+	})
+	for i := 0; i < max; i++ {
+		if err := m.StoreErr(i, i); err != nil {
+			t.Fatalf("StoreErr(%d) = %v into an emptied map", i, err)
+		}
+	}
+	if stored < max {
+		t.Fatalf("%d stores succeeded, want at least %d", stored, max)
+	}
+}
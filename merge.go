@@ -0,0 +1,30 @@
+package cmap
+
+import "context"
+
+// Merge stores the entries of src into m, copying one bucket of src at a
+// time. For a key present in both maps, the value stored is
+// resolve(key, dst, src) with dst the value in m, or the value in src if
+// resolve is nil.
+//
+// Each entry is applied under the lock of its bucket in m, like Update,
+// so resolve must not use m.
+func (m *CMap) Merge(src *CMap, resolve func(key, dst, src interface{}) interface{}) {
+	m.merge(nil, src, resolve)
+}
+
+func (m *CMap) merge(ctx context.Context, src *CMap, resolve func(key, dst, src interface{}) interface{}) error {
+	if err := m.checkOpen(); err != nil {
+		return err
+	}
+	_, err := src.rangeBucketsCtx(ctx, func(key, value interface{}) bool {
+		m.Update(key, func(dst interface{}, loaded bool) (interface{}, bool) {
+			if loaded && resolve != nil {
+				return resolve(key, dst, value), false
+			}
+			return value, false
+		})
+		return true
+	})
+	return err
+}
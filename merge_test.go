@@ -0,0 +1,44 @@
+package cmap_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestMerge(t *testing.T) {
+	var dst cmap.CMap
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var part cmap.CMap
+			for i := 0; i < 100; i++ {
+				part.Store(i, 1)
+			}
+			dst.Merge(&part, func(key, dst, src interface{}) interface{} {
+				return dst.(int) + src.(int)
+			})
+		}()
+	}
+	wg.Wait()
+
+	if dst.Len() != 100 {
+		t.Fatalf("Len() = %d, want 100", dst.Len())
+	}
+	dst.Range(func(key, value interface{}) bool {
+		if value != 4 {
+			t.Fatalf("merged %v = %v, want 4", key, value)
+		}
+		return true
+	})
+
+	var src cmap.CMap
+	src.Store(1, "src")
+	dst.Merge(&src, nil)
+	if v, _ := dst.Load(1); v != "src" {
+		t.Fatalf("Merge with nil resolve kept %v", v)
+	}
+}
@@ -0,0 +1,58 @@
+package cmap
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Meta is the metadata of an entry, see WithEntryMeta.
+type Meta struct {
+	Created  time.Time // when the value was stored
+	Accessed time.Time // when the value was last loaded, or stored
+}
+
+// WithEntryMeta makes the map track when each value was stored and last
+// loaded, as reported by LoadMeta. It costs an allocation per write, and
+// Load writes the access time of the entry, so the maps without it don't
+// pay for either.
+func WithEntryMeta() Option {
+	return func(m *CMap) {
+		m.meta = true
+	}
+}
+
+// LoadMeta is like Load, but also returns the metadata of the entry. The
+// Meta is zero if the map was not created WithEntryMeta. LoadMeta counts
+// as an access of the entry.
+func (m *CMap) LoadMeta(key interface{}) (value interface{}, meta Meta, ok bool) {
+	hash := m.hash(key)
+	raw, ok := m.getNode().readBucket(hash).load(key, hash)
+	if !ok {
+		return nil, Meta{}, false
+	}
+	e, _ := raw.(*expiring)
+	if e != nil && e.created != 0 {
+		meta = Meta{
+			Created:  time.Unix(0, unixNano(e.created)),
+			Accessed: time.Unix(0, unixNano(atomic.LoadInt64(&e.accessed))),
+		}
+	}
+	if value, ok = access(raw); !ok {
+		return nil, Meta{}, false
+	}
+	return value, meta, true
+}
+
+// access is unwrap for a load of v, which records the access in v.
+func access(v interface{}) (value interface{}, ok bool) {
+	e, isExp := v.(*expiring)
+	if !isExp {
+		return v, true
+	}
+	now := nanotime()
+	if e.expired(now) {
+		return nil, false
+	}
+	e.touch(now)
+	return e.value, true
+}
@@ -0,0 +1,58 @@
+package cmap_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/min1324/cmap"
+)
+
+func TestLoadMeta(t *testing.T) {
+	m := cmap.New(cmap.WithEntryMeta())
+	before := time.Now()
+	m.Store("k", 1)
+	_, meta, ok := m.LoadMeta("k")
+	if !ok || meta.Created.Before(before) || meta.Accessed.Before(meta.Created) {
+		t.Fatalf("LoadMeta = %+v, %v, want Created after %v", meta, ok, before)
+	}
+
+	time.Sleep(time.Millisecond)
+	if v, ok := m.Load("k"); !ok || v != 1 {
+		t.Fatalf("Load = %v, %v", v, ok)
+	}
+	_, meta2, _ := m.LoadMeta("k")
+	if !meta2.Created.Equal(meta.Created) || !meta2.Accessed.After(meta.Accessed) {
+		t.Fatalf("LoadMeta = %+v after Load, was %+v", meta2, meta)
+	}
+	if ttl, ok := m.GetTTL("k"); !ok || ttl != cmap.NoExpiration {
+		t.Fatalf("GetTTL = %v, %v, want NoExpiration", ttl, ok)
+	}
+
+	m.StoreWithTTL("t", 2, time.Hour)
+	if v, meta, ok := m.LoadMeta("t"); !ok || v != 2 || meta.Created.IsZero() {
+		t.Fatalf("LoadMeta of a ttl key = %v, %+v, %v", v, meta, ok)
+	}
+	if v, loaded := m.LoadOrStore("n", 3); loaded || v != 3 {
+		t.Fatalf("LoadOrStore = %v, %v", v, loaded)
+	}
+	m.Update("n", func(v interface{}, _ bool) (interface{}, bool) { return v.(int) + 1, false })
+	sum := 0
+	m.Range(func(_, v interface{}) bool {
+		sum += v.(int)
+		return true
+	})
+	if sum != 7 {
+		t.Fatalf("Range summed %d, want the plain values", sum)
+	}
+}
+
+func TestLoadMetaUntracked(t *testing.T) {
+	var m cmap.CMap
+	m.Store("k", 1)
+	if v, meta, ok := m.LoadMeta("k"); !ok || v != 1 || meta != (cmap.Meta{}) {
+		t.Fatalf("LoadMeta = %v, %+v, %v, want a zero Meta", v, meta, ok)
+	}
+	if _, _, ok := m.LoadMeta("absent"); ok {
+		t.Fatalf("LoadMeta found an absent key")
+	}
+}
@@ -0,0 +1,248 @@
+// Package metrics instruments a cmap.CMap with operation counters and
+// latency histograms, exported through expvar and in the Prometheus text
+// format. The module gitee.com/absir_admin/cmap/prometheus turns them into
+// a prometheus.Collector.
+package metrics
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"gitee.com/absir_admin/cmap"
+)
+
+// op is an instrumented operation.
+type op int
+
+const (
+	opLoad op = iota
+	opStore
+	opLoadOrStore
+	opLoadAndDelete
+	opDelete
+	opRange
+	numOps
+)
+
+var opNames = [numOps]string{"load", "store", "load_or_store", "load_and_delete", "delete", "range"}
+
+// LatencyBounds are the upper bounds of the buckets of the latency
+// histograms, see Snapshot.Latency.
+var LatencyBounds = [...]time.Duration{
+	100 * time.Nanosecond, 250 * time.Nanosecond, 500 * time.Nanosecond,
+	time.Microsecond, 2500 * time.Nanosecond, 5 * time.Microsecond,
+	10 * time.Microsecond, 25 * time.Microsecond, 50 * time.Microsecond,
+	100 * time.Microsecond, 250 * time.Microsecond, 500 * time.Microsecond,
+	time.Millisecond, 2500 * time.Microsecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second,
+}
+
+const numBounds = len(LatencyBounds)
+
+// Instrumented wraps a CMap, counting the hits and misses of its loads and
+// the number and duration of its operations.
+//
+// Operations made on the CMap directly are not counted, but are reflected
+// in the size and resize metrics.
+type Instrumented struct {
+	m    *cmap.CMap
+	name string
+
+	hits   uint64
+	misses uint64
+	calls  [numOps]uint64
+	nanos  [numOps]uint64
+	// calls by operation and bucket of LatencyBounds, the slower ones
+	// counted by calls only
+	buckets [numOps][numBounds]uint64
+}
+
+// New returns an Instrumented wrapping m, name identifies it in the
+// exported metrics.
+func New(name string, m *cmap.CMap) *Instrumented {
+	return &Instrumented{m: m, name: name}
+}
+
+// Name returns the name of i in the exported metrics.
+func (i *Instrumented) Name() string {
+	return i.name
+}
+
+// Map returns the wrapped CMap.
+func (i *Instrumented) Map() *cmap.CMap {
+	return i.m
+}
+
+func (i *Instrumented) done(o op, start time.Time) {
+	d := time.Since(start)
+	atomic.AddUint64(&i.calls[o], 1)
+	atomic.AddUint64(&i.nanos[o], uint64(d))
+	if b := sort.Search(numBounds, func(b int) bool { return d <= LatencyBounds[b] }); b < numBounds {
+		atomic.AddUint64(&i.buckets[o][b], 1)
+	}
+}
+
+func (i *Instrumented) hit(ok bool) {
+	if ok {
+		atomic.AddUint64(&i.hits, 1)
+	} else {
+		atomic.AddUint64(&i.misses, 1)
+	}
+}
+
+// Load is CMap.Load, counting a hit or a miss.
+func (i *Instrumented) Load(key interface{}) (value interface{}, ok bool) {
+	defer i.done(opLoad, time.Now())
+	value, ok = i.m.Load(key)
+	i.hit(ok)
+	return value, ok
+}
+
+// Store is CMap.Store.
+func (i *Instrumented) Store(key, value interface{}) {
+	defer i.done(opStore, time.Now())
+	i.m.Store(key, value)
+}
+
+// LoadOrStore is CMap.LoadOrStore, counting a hit if the value was loaded,
+// a miss if stored.
+func (i *Instrumented) LoadOrStore(key, value interface{}) (actual interface{}, loaded bool) {
+	defer i.done(opLoadOrStore, time.Now())
+	actual, loaded = i.m.LoadOrStore(key, value)
+	i.hit(loaded)
+	return actual, loaded
+}
+
+// LoadAndDelete is CMap.LoadAndDelete.
+func (i *Instrumented) LoadAndDelete(key interface{}) (value interface{}, loaded bool) {
+	defer i.done(opLoadAndDelete, time.Now())
+	return i.m.LoadAndDelete(key)
+}
+
+// Delete is CMap.Delete.
+func (i *Instrumented) Delete(key interface{}) {
+	defer i.done(opDelete, time.Now())
+	i.m.Delete(key)
+}
+
+// Range is CMap.Range.
+func (i *Instrumented) Range(f func(key, value interface{}) bool) bool {
+	defer i.done(opRange, time.Now())
+	return i.m.Range(f)
+}
+
+// Snapshot is a copy of the metrics of an Instrumented.
+type Snapshot struct {
+	Hits     uint64
+	Misses   uint64
+	Calls    map[string]uint64        // number of calls by operation
+	Duration map[string]time.Duration // total duration by operation
+	// Latency is the number of calls by operation which took at most each
+	// of LatencyBounds, cumulative like the buckets of Prometheus.
+	Latency  map[string][]uint64
+	Len      int
+	Grows    uint64
+	Shrinks  uint64
+	Resizing bool
+}
+
+// Snapshot returns the current metrics.
+func (i *Instrumented) Snapshot() Snapshot {
+	s := Snapshot{
+		Hits:     atomic.LoadUint64(&i.hits),
+		Misses:   atomic.LoadUint64(&i.misses),
+		Calls:    make(map[string]uint64, numOps),
+		Duration: make(map[string]time.Duration, numOps),
+		Latency:  make(map[string][]uint64, numOps),
+	}
+	for o := op(0); o < numOps; o++ {
+		s.Calls[opNames[o]] = atomic.LoadUint64(&i.calls[o])
+		s.Duration[opNames[o]] = time.Duration(atomic.LoadUint64(&i.nanos[o]))
+		latency := make([]uint64, numBounds)
+		var n uint64
+		for b := range latency {
+			n += atomic.LoadUint64(&i.buckets[o][b])
+			latency[b] = n
+		}
+		s.Latency[opNames[o]] = latency
+	}
+	st := i.m.Stats()
+	s.Len, s.Grows, s.Shrinks, s.Resizing = st.Len, st.Grows, st.Shrinks, st.Resizing
+	return s
+}
+
+// Var returns an expvar.Var whose value is the current Snapshot as JSON.
+func (i *Instrumented) Var() expvar.Var {
+	return expvar.Func(func() interface{} {
+		return i.Snapshot()
+	})
+}
+
+// Publish publishes the metrics in expvar under the name of i.
+// Like expvar.Publish, it panics if the name is already registered.
+func (i *Instrumented) Publish() {
+	expvar.Publish(i.name, i.Var())
+}
+
+// WritePrometheus writes the metrics to w in the Prometheus text
+// exposition format, labeled with map="name".
+func (i *Instrumented) WritePrometheus(w io.Writer) error {
+	s := i.Snapshot()
+	ew := &errWriter{w: w}
+	ew.metric("cmap_loads_total", "counter", "Number of lookups by result.")
+	ew.printf("cmap_loads_total{map=%q,result=\"hit\"} %d\n", i.name, s.Hits)
+	ew.printf("cmap_loads_total{map=%q,result=\"miss\"} %d\n", i.name, s.Misses)
+	ew.metric("cmap_op_duration_seconds", "histogram", "Duration of the operations.")
+	for o := op(0); o < numOps; o++ {
+		name := opNames[o]
+		for b, n := range s.Latency[name] {
+			le := strconv.FormatFloat(LatencyBounds[b].Seconds(), 'g', -1, 64)
+			ew.printf("cmap_op_duration_seconds_bucket{map=%q,op=%q,le=%q} %d\n", i.name, name, le, n)
+		}
+		ew.printf("cmap_op_duration_seconds_bucket{map=%q,op=%q,le=\"+Inf\"} %d\n", i.name, name, s.Calls[name])
+		ew.printf("cmap_op_duration_seconds_sum{map=%q,op=%q} %g\n", i.name, name, s.Duration[name].Seconds())
+		ew.printf("cmap_op_duration_seconds_count{map=%q,op=%q} %d\n", i.name, name, s.Calls[name])
+	}
+	ew.metric("cmap_entries", "gauge", "Number of elements in the map.")
+	ew.printf("cmap_entries{map=%q} %d\n", i.name, s.Len)
+	ew.metric("cmap_resizes_total", "counter", "Number of resizes by direction.")
+	ew.printf("cmap_resizes_total{map=%q,direction=\"grow\"} %d\n", i.name, s.Grows)
+	ew.printf("cmap_resizes_total{map=%q,direction=\"shrink\"} %d\n", i.name, s.Shrinks)
+	ew.metric("cmap_resizing", "gauge", "Whether the map is being resized.")
+	resizing := 0
+	if s.Resizing {
+		resizing = 1
+	}
+	ew.printf("cmap_resizing{map=%q} %d\n", i.name, resizing)
+	return ew.err
+}
+
+// ServeHTTP serves the metrics in the Prometheus text exposition format.
+func (i *Instrumented) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	i.WritePrometheus(w)
+}
+
+// errWriter keeps the first error of a sequence of writes.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...interface{}) {
+	if ew.err == nil {
+		_, ew.err = fmt.Fprintf(ew.w, format, args...)
+	}
+}
+
+func (ew *errWriter) metric(name, typ, help string) {
+	ew.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
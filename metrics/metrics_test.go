@@ -0,0 +1,56 @@
+package metrics_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gitee.com/absir_admin/cmap"
+	"gitee.com/absir_admin/cmap/metrics"
+)
+
+func TestInstrumented(t *testing.T) {
+	m := metrics.New("test", new(cmap.CMap))
+	m.Store("a", 1)
+	m.Load("a")
+	m.Load("b")
+	m.LoadOrStore("b", 2)
+	m.Delete("a")
+
+	s := m.Snapshot()
+	if s.Hits != 1 || s.Misses != 2 {
+		t.Fatalf("Snapshot has %d hits, %d misses; want 1, 2", s.Hits, s.Misses)
+	}
+	if s.Calls["load"] != 2 || s.Calls["store"] != 1 || s.Len != 1 {
+		t.Fatalf("Snapshot = %+v", s)
+	}
+	if l := s.Latency["load"]; len(l) != len(metrics.LatencyBounds) || l[len(l)-1] != 2 {
+		t.Fatalf("Snapshot has load latency %v, want 2 calls under %v", l, metrics.LatencyBounds[len(metrics.LatencyBounds)-1])
+	}
+
+	var v map[string]interface{}
+	if err := json.Unmarshal([]byte(m.Var().String()), &v); err != nil {
+		t.Fatalf("Var is not JSON: %v", err)
+	}
+	if v["Hits"] != 1.0 {
+		t.Fatalf("Var has Hits %v", v["Hits"])
+	}
+
+	var sb strings.Builder
+	if err := m.WritePrometheus(&sb); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`cmap_loads_total{map="test",result="miss"} 2`,
+		`cmap_op_duration_seconds_count{map="test",op="load"} 2`,
+		`cmap_op_duration_seconds_bucket{map="test",op="load",le="+Inf"} 2`,
+		`cmap_op_duration_seconds_bucket{map="test",op="store",le="1"} 1`,
+		`# TYPE cmap_op_duration_seconds histogram`,
+		`cmap_entries{map="test"} 1`,
+		`# TYPE cmap_resizes_total counter`,
+	} {
+		if !strings.Contains(sb.String(), line+"\n") {
+			t.Errorf("WritePrometheus output lacks %q:\n%s", line, sb.String())
+		}
+	}
+}
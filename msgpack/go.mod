@@ -0,0 +1,12 @@
+module gitee.com/absir_admin/cmap/msgpack
+
+go 1.18
+
+require (
+	gitee.com/absir_admin/cmap v0.0.0
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+)
+
+require github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+
+replace gitee.com/absir_admin/cmap => ../
@@ -0,0 +1,123 @@
+// Package msgpack writes and reads the snapshots of a cmap.CMap with
+// MessagePack, a compact binary format implemented in most languages, for
+// warm starts and exchanges with other programs.
+//
+// It is a module of its own, so that cmap does not depend on a msgpack
+// implementation. Like cmap.CMap.Snapshot, it streams the entries one
+// bucket at a time, with cmap.CMap.RangeSnapshotEntries.
+//
+// A snapshot is the version of the format, 1, followed by an array of 4
+// items per entry: the key, the value, the deadline of the key in unix
+// nanoseconds, 0 if it never expires, and its idle ttl in nanoseconds, 0
+// for a fixed deadline. A nil ends the entries, so that a snapshot cut
+// short between two entries is detected.
+package msgpack
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	"gitee.com/absir_admin/cmap"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// version is the version of the format written by Snapshot.
+const version = 1
+
+// Snapshot writes the entries of m to w. The keys and values are encoded
+// like msgpack.Marshal encodes them.
+func Snapshot(w io.Writer, m *cmap.CMap) error {
+	bw := bufio.NewWriter(w)
+	enc := msgpack.NewEncoder(bw)
+	if err := enc.EncodeInt(version); err != nil {
+		return err
+	}
+	err := m.RangeSnapshotEntries(func(e cmap.SnapshotEntry) error {
+		if err := enc.EncodeArrayLen(4); err != nil {
+			return err
+		}
+		if err := enc.Encode(e.Key); err != nil {
+			return err
+		}
+		if err := enc.Encode(e.Value); err != nil {
+			return err
+		}
+		if err := enc.EncodeInt(e.Deadline); err != nil {
+			return err
+		}
+		return enc.EncodeInt(e.Idle)
+	})
+	if err != nil {
+		return err
+	}
+	if err := enc.EncodeNil(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Restore stores the entries of a snapshot written by Snapshot into m,
+// like cmap.CMap.Restore: keys with a ttl get back their deadline, and
+// are skipped if they expired meanwhile. Once the version is read, it
+// increments the generation of m, even on an error. A snapshot cut short
+// returns io.ErrUnexpectedEOF, even between two entries.
+//
+// The keys and values are decoded to the generic types of msgpack:
+// integers to int64 or uint64, floats to float64, arrays to
+// []interface{} and maps to map[string]interface{}. If convert is not
+// nil, it is called with each key and value decoded, and returns those to
+// store, like an int key for an int64 one.
+func Restore(r io.Reader, m *cmap.CMap, convert func(key, value interface{}) (interface{}, interface{}, error)) (err error) {
+	defer func() {
+		// the end of r before the end of the entries
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+	}()
+	dec := msgpack.NewDecoder(bufio.NewReader(r))
+	dec.UseLooseInterfaceDecoding(true)
+	v, err := dec.DecodeInt()
+	if err != nil {
+		return err
+	}
+	if v != version {
+		return fmt.Errorf("msgpack: snapshot version %d, want %d", v, version)
+	}
+	defer m.Invalidate()
+	for {
+		n, err := dec.DecodeArrayLen()
+		if err != nil {
+			return err
+		}
+		if n == -1 {
+			// the nil ending the entries
+			return nil
+		}
+		if n != 4 {
+			return fmt.Errorf("msgpack: snapshot entry of %d items, want 4", n)
+		}
+		var e cmap.SnapshotEntry
+		if e.Key, err = dec.DecodeInterfaceLoose(); err != nil {
+			return err
+		}
+		if e.Value, err = dec.DecodeInterfaceLoose(); err != nil {
+			return err
+		}
+		if e.Deadline, err = dec.DecodeInt64(); err != nil {
+			return err
+		}
+		if e.Idle, err = dec.DecodeInt64(); err != nil {
+			return err
+		}
+		if convert != nil {
+			if e.Key, e.Value, err = convert(e.Key, e.Value); err != nil {
+				return err
+			}
+		}
+		if err := m.RestoreEntry(e); err != nil {
+			return err
+		}
+	}
+}
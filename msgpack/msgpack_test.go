@@ -0,0 +1,80 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"gitee.com/absir_admin/cmap"
+	"gitee.com/absir_admin/cmap/msgpack"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	var m cmap.CMap
+	for i := 0; i < 1000; i++ {
+		m.Store(i, []interface{}{"v", strconv.Itoa(i)})
+	}
+	m.Store("map", map[string]interface{}{"a": 1.5})
+	m.StoreWithTTL("ttl", "v", time.Hour)
+	m.StoreWithTTL("short", "v", 100*time.Millisecond)
+
+	var buf bytes.Buffer
+	if err := msgpack.Snapshot(&buf, &m); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(150 * time.Millisecond)
+
+	var r cmap.CMap
+	err := msgpack.Restore(&buf, &r, func(key, value interface{}) (interface{}, interface{}, error) {
+		switch k := key.(type) {
+		case int64:
+			key = int(k)
+		case uint64:
+			key = int(k)
+		}
+		return key, value, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := r.Generation(); g != 1 {
+		t.Fatalf("Generation() = %d after Restore, want 1", g)
+	}
+	if n := r.Len(); n != 1002 {
+		t.Fatalf("Len() = %d after Restore, want 1002", n)
+	}
+	m.Delete("short")
+	if !r.Equal(&m, reflect.DeepEqual) {
+		t.Fatal("restored map differs")
+	}
+	if ttl, ok := r.GetTTL("ttl"); !ok || ttl <= 59*time.Minute {
+		t.Fatalf("GetTTL(ttl) = %v, %v after Restore; want about 1h", ttl, ok)
+	}
+
+	if err := msgpack.Restore(bytes.NewReader([]byte{2}), &r, nil); err == nil {
+		t.Fatal("Restore of version 2 succeeded")
+	}
+}
+
+func TestRestoreTruncated(t *testing.T) {
+	var m cmap.CMap
+	for i := 0; i < 10; i++ {
+		m.Store(i, i)
+	}
+	var buf bytes.Buffer
+	if err := msgpack.Snapshot(&buf, &m); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	// every cut is detected, between two entries and before the end
+	// marker included
+	for n := 0; n < len(data); n++ {
+		var r cmap.CMap
+		if err := msgpack.Restore(bytes.NewReader(data[:n]), &r, nil); err != io.ErrUnexpectedEOF {
+			t.Fatalf("Restore of %d bytes of %d = %v, want io.ErrUnexpectedEOF", n, len(data), err)
+		}
+	}
+}
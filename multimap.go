@@ -0,0 +1,68 @@
+package cmap
+
+// MultiMap is a concurrent map of keys to lists of values.
+//
+// The lists are never modified once returned: every change of a key
+// replaces its list, so LoadAll and Range can return them without copy.
+//
+// The zero MultiMap is empty and ready for use. A MultiMap must not be
+// copied after first use.
+type MultiMap struct {
+	m CMap
+}
+
+// Append appends v to the values of key.
+func (mm *MultiMap) Append(key, v interface{}) {
+	mm.m.Update(key, func(value interface{}, _ bool) (interface{}, bool) {
+		vs, _ := value.([]interface{})
+		return append(vs[:len(vs):len(vs)], v), false
+	})
+}
+
+// RemoveValue removes the first value of key equal to v, and reports
+// whether there was one. A key left without value is deleted.
+func (mm *MultiMap) RemoveValue(key, v interface{}) bool {
+	removed := false
+	mm.m.compute(key, func(value interface{}, _ bool) (interface{}, action) {
+		vs, _ := value.([]interface{})
+		for i := range vs {
+			if vs[i] == v {
+				removed = true
+				if len(vs) == 1 {
+					return nil, actDelete
+				}
+				rest := make([]interface{}, 0, len(vs)-1)
+				return append(append(rest, vs[:i]...), vs[i+1:]...), actStore
+			}
+		}
+		return nil, actKeep
+	})
+	return removed
+}
+
+// LoadAll returns the values of key, in the order they were appended.
+// The slice must not be modified.
+func (mm *MultiMap) LoadAll(key interface{}) []interface{} {
+	v, _ := mm.m.Load(key)
+	vs, _ := v.([]interface{})
+	return vs
+}
+
+// Delete deletes every value of key.
+func (mm *MultiMap) Delete(key interface{}) {
+	mm.m.Delete(key)
+}
+
+// Len returns the number of keys with at least one value.
+func (mm *MultiMap) Len() int {
+	return mm.m.Len()
+}
+
+// Range calls f sequentially for each key and its values.
+// If f returns false, range stops the iteration. The slices must not be
+// modified.
+func (mm *MultiMap) Range(f func(key interface{}, values []interface{}) bool) bool {
+	return mm.m.Range(func(key, value interface{}) bool {
+		return f(key, value.([]interface{}))
+	})
+}
@@ -0,0 +1,45 @@
+package cmap_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestMultiMap(t *testing.T) {
+	var mm cmap.MultiMap
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				mm.Append(i%10, g*100+i)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if mm.Len() != 10 {
+		t.Fatalf("Len() = %d, want 10", mm.Len())
+	}
+	vs := mm.LoadAll(3)
+	if len(vs) != 40 {
+		t.Fatalf("LoadAll(3) has %d values, want 40", len(vs))
+	}
+	if !mm.RemoveValue(3, 103) || mm.RemoveValue(3, 103) {
+		t.Fatalf("RemoveValue(3, 103) failed")
+	}
+	if len(mm.LoadAll(3)) != 39 || len(vs) != 40 {
+		t.Fatalf("RemoveValue changed a returned slice")
+	}
+	if mm.RemoveValue(42, 1) || mm.Len() != 10 {
+		t.Fatalf("RemoveValue of a missing key added it")
+	}
+
+	mm.Append("k", 1)
+	if !mm.RemoveValue("k", 1) || mm.LoadAll("k") != nil || mm.Len() != 10 {
+		t.Fatalf("key left without value")
+	}
+}
@@ -0,0 +1,134 @@
+package cmap
+
+import "sync/atomic"
+
+// nsKey is the key stored in a CMap for key in namespace ns.
+type nsKey struct {
+	ns  string
+	key interface{}
+}
+
+// View is a namespace of a CMap, see Namespace. Its keys are stored in the
+// map wrapped with the name of the namespace, so that they never collide
+// with the keys of the map or of other namespaces, even when the names of
+// namespaces are prefixes of one another.
+type View struct {
+	m    *CMap
+	path []string // names of the namespaces, outermost first
+}
+
+// Namespace returns the namespace prefix of the map. Its keys only show
+// in the map wrapped: Range over the map calls f with them, but they can
+// only be used through the view.
+func (m *CMap) Namespace(prefix string) *View {
+	return &View{m: m, path: []string{prefix}}
+}
+
+// DeleteNamespace deletes the keys of the namespace prefix, and of the
+// namespaces nested in it, and returns the number of keys deleted. The
+// buckets are scanned in parallel, and only the keys of the namespace
+// are locked and deleted.
+//
+// Keys stored while DeleteNamespace is running may be missed.
+func (m *CMap) DeleteNamespace(prefix string) int {
+	return m.deleteView([]string{prefix})
+}
+
+// deleteView deletes the keys of the namespace at path.
+func (m *CMap) deleteView(path []string) int {
+	v := View{m: m, path: path}
+	var deleted int64
+	m.eachKey(nil, func(key interface{}) {
+		if _, ok := v.unwrap(key); ok {
+			if _, loaded := m.LoadAndDelete(key); loaded {
+				atomic.AddInt64(&deleted, 1)
+			}
+		}
+	})
+	return int(deleted)
+}
+
+func (v *View) wrap(key interface{}) interface{} {
+	for i := len(v.path) - 1; i >= 0; i-- {
+		key = nsKey{v.path[i], key}
+	}
+	return key
+}
+
+// unwrap returns the key of v stored as key, ok is false if key is not
+// in v.
+func (v *View) unwrap(key interface{}) (inner interface{}, ok bool) {
+	for _, ns := range v.path {
+		k, ok := key.(nsKey)
+		if !ok || k.ns != ns {
+			return nil, false
+		}
+		key = k.key
+	}
+	return key, true
+}
+
+// Namespace returns the namespace prefix nested in v.
+func (v *View) Namespace(prefix string) *View {
+	path := make([]string, len(v.path)+1)
+	copy(path, v.path)
+	path[len(v.path)] = prefix
+	return &View{m: v.m, path: path}
+}
+
+// DeleteNamespace deletes the keys of the namespace prefix nested in v,
+// like CMap.DeleteNamespace.
+func (v *View) DeleteNamespace(prefix string) int {
+	return v.m.deleteView(v.Namespace(prefix).path)
+}
+
+// Load returns the value stored in the namespace for a key.
+func (v *View) Load(key interface{}) (value interface{}, ok bool) {
+	return v.m.Load(v.wrap(key))
+}
+
+// Store sets the value for a key in the namespace.
+func (v *View) Store(key, value interface{}) {
+	v.m.Store(v.wrap(key), value)
+}
+
+// LoadOrStore returns the existing value for the key if present.
+// Otherwise, it stores and returns the given value.
+func (v *View) LoadOrStore(key, value interface{}) (actual interface{}, loaded bool) {
+	return v.m.LoadOrStore(v.wrap(key), value)
+}
+
+// LoadAndDelete deletes the value for a key, returning the previous value
+// if any.
+func (v *View) LoadAndDelete(key interface{}) (value interface{}, loaded bool) {
+	return v.m.LoadAndDelete(v.wrap(key))
+}
+
+// Delete deletes the value for a key in the namespace.
+func (v *View) Delete(key interface{}) {
+	v.m.Delete(v.wrap(key))
+}
+
+// Range calls f sequentially for each key and value of the namespace,
+// including the keys of nested namespaces, which are passed wrapped.
+// If f returns false, range stops the iteration.
+//
+// Range goes over the whole map.
+func (v *View) Range(f func(key, value interface{}) bool) bool {
+	return v.m.Range(func(key, value interface{}) bool {
+		if k, ok := v.unwrap(key); ok {
+			return f(k, value)
+		}
+		return true
+	})
+}
+
+// Len returns the number of keys of the namespace, counted by Range.
+func (v *View) Len() int {
+	n := 0
+	v.Range(func(_, _ interface{}) bool {
+		n++
+		return true
+	})
+	return n
+}
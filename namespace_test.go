@@ -0,0 +1,54 @@
+package cmap_test
+
+import (
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestNamespace(t *testing.T) {
+	var m cmap.CMap
+	a, ab := m.Namespace("a"), m.Namespace("ab")
+	m.Store("x", 0)
+	a.Store("bx", 1)
+	ab.Store("x", 2)
+	a.Namespace("n").Store("x", 3)
+
+	if v, ok := a.Load("bx"); !ok || v != 1 {
+		t.Fatalf("a.Load(bx) = %v, %v; want 1, true", v, ok)
+	}
+	if v, ok := ab.Load("x"); !ok || v != 2 {
+		t.Fatalf("ab.Load(x) = %v, %v; want 2, true", v, ok)
+	}
+	if v, ok := m.Load("x"); !ok || v != 0 {
+		t.Fatalf("Load(x) = %v, %v; want 0, true", v, ok)
+	}
+	if _, ok := a.Load("x"); ok {
+		t.Fatalf("a.Load(x) found a key of a nested namespace")
+	}
+	if n := a.Len(); n != 2 {
+		t.Fatalf("a.Len() = %d, want 2", n)
+	}
+	if n := a.Namespace("n").Len(); n != 1 {
+		t.Fatalf("a/n Len() = %d, want 1", n)
+	}
+
+	if n := m.DeleteNamespace("a"); n != 2 {
+		t.Fatalf("DeleteNamespace(a) = %d, want 2", n)
+	}
+	if n := m.Len(); n != 2 {
+		t.Fatalf("Len() = %d after DeleteNamespace, want 2", n)
+	}
+	if v, ok := ab.Load("x"); !ok || v != 2 {
+		t.Fatalf("DeleteNamespace(a) deleted ab/x")
+	}
+
+	ab.Namespace("n").Store(1, 1)
+	ab.Namespace("o").Store(1, 1)
+	if n := ab.DeleteNamespace("n"); n != 1 {
+		t.Fatalf("ab.DeleteNamespace(n) = %d, want 1", n)
+	}
+	if n := ab.Len(); n != 2 {
+		t.Fatalf("ab.Len() = %d, want 2", n)
+	}
+}
@@ -0,0 +1,47 @@
+package cmap
+
+import "errors"
+
+// ErrNilKey is returned by StoreErr and DoAtomic for a nil key with
+// NilKeysRejected, which makes TryStore return false, and is the panic
+// value of the writes of a nil key with NilKeysPanic.
+var ErrNilKey = errors.New("cmap: nil key")
+
+// NilKeyPolicy is what a CMap does with a nil key, see WithNilKeys. A key
+// is nil if it is the nil interface: a typed nil pointer is a key like
+// any other.
+type NilKeyPolicy uint8
+
+const (
+	// NilKeysAllowed makes nil a key like any other, the default.
+	NilKeysAllowed NilKeyPolicy = iota
+	// NilKeysRejected drops the writes of a nil key: StoreErr and
+	// DoAtomic return ErrNilKey, TryStore returns false, and the other
+	// writes do nothing.
+	NilKeysRejected
+	// NilKeysPanic makes the writes of a nil key panic with ErrNilKey.
+	NilKeysPanic
+)
+
+// WithNilKeys sets the policy of the map for nil keys. Only the writes
+// check it: the writes are Store, the StoreWith variants, TryStore,
+// LoadOrStore, the operations built on Update, like CompareAndSwapFunc,
+// Merge and Append, DoAtomic, like Rename, and RestoreEntry. Since a nil key can't be stored,
+// reading or deleting it just finds nothing.
+func WithNilKeys(policy NilKeyPolicy) Option {
+	return func(m *CMap) {
+		m.nilKeys = policy
+	}
+}
+
+// checkKey returns ErrNilKey if key may not be written in m, or panics
+// with it with NilKeysPanic.
+func (m *CMap) checkKey(key interface{}) error {
+	if key != nil || m.nilKeys == NilKeysAllowed {
+		return nil
+	}
+	if m.nilKeys == NilKeysPanic {
+		panic(ErrNilKey)
+	}
+	return ErrNilKey
+}
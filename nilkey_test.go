@@ -0,0 +1,64 @@
+package cmap_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/min1324/cmap"
+)
+
+func TestNilKeys(t *testing.T) {
+	var m cmap.CMap
+	if err := m.StoreErr(nil, 1); err != nil {
+		t.Fatalf("StoreErr(nil) = %v, want nil by default", err)
+	}
+	if v, ok := m.Load(nil); !ok || v != 1 {
+		t.Fatalf("Load(nil) = %v, %v; want 1, true", v, ok)
+	}
+
+	r := cmap.New(cmap.WithNilKeys(cmap.NilKeysRejected))
+	if err := r.StoreErr(nil, 1); err != cmap.ErrNilKey {
+		t.Fatalf("StoreErr(nil) = %v, want ErrNilKey", err)
+	}
+	r.Store(nil, 1)
+	r.LoadOrStore(nil, 1)
+	r.Update(nil, func(interface{}, bool) (interface{}, bool) { return 1, false })
+	err := r.DoAtomic([]interface{}{1, nil}, func(tx cmap.TxView) error {
+		t.Fatal("DoAtomic called fn with a nil key")
+		return nil
+	})
+	if !errors.Is(err, cmap.ErrNilKey) {
+		t.Fatalf("DoAtomic = %v, want ErrNilKey", err)
+	}
+	var typed *int
+	if err := r.StoreErr(typed, 2); err != nil {
+		t.Fatalf("StoreErr of a typed nil = %v, want nil", err)
+	}
+	if _, ok := r.Load(nil); ok || r.Len() != 1 {
+		t.Fatalf("Len() = %d after writes of nil, want only the typed nil", r.Len())
+	}
+	r.Delete(nil)
+
+	p := cmap.New(cmap.WithNilKeys(cmap.NilKeysPanic))
+	for name, f := range map[string]func(){
+		"Store":              func() { p.Store(nil, 1) },
+		"StoreErr":           func() { p.StoreErr(nil, 1) },
+		"StoreWithTTL":       func() { p.StoreWithTTL(nil, 1, time.Hour) },
+		"LoadOrStore":        func() { p.LoadOrStore(nil, 1) },
+		"CompareAndSwapFunc": func() { p.CompareAndSwapFunc(nil, 1, 2, nil) },
+		"Rename":             func() { p.Rename(1, nil) },
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r != cmap.ErrNilKey {
+					t.Errorf("%s: recovered %v, want ErrNilKey", name, r)
+				}
+			}()
+			f()
+		}()
+	}
+	if _, ok := p.Load(nil); ok {
+		t.Fatal("Load(nil) found a key")
+	}
+}
@@ -0,0 +1,143 @@
+package cmap
+
+import "time"
+
+// Option configures a CMap created by New.
+type Option func(*CMap)
+
+// New returns an empty CMap configured by opts.
+//
+// The zero CMap is empty and ready for use as well, New is only needed
+// when some Option is required.
+func New(opts ...Option) *CMap {
+	m := new(CMap)
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.start()
+	return m
+}
+
+// WithJanitor makes the map remove expired entries in the background,
+// every interval. Call Close to stop it.
+//
+// The janitor keeps the keys stored with a ttl in timer wheels, so each
+// run only visits the keys due since the last one, instead of scanning
+// the map like DeleteExpired. A key deleted before its ttl stays in the
+// wheels until then.
+func WithJanitor(interval time.Duration) Option {
+	return func(m *CMap) {
+		if interval > 0 {
+			m.janitor = &janitor{interval: interval, wheels: newWheels(int64(interval))}
+		}
+	}
+}
+
+// WithMaxShardBits limits the map to 1<<bits buckets, bits is limited to
+// the range [4, 31], and defaults to 31.
+//
+// Once every bucket is used, a bucket grows past the load factor as a
+// plain Go map, so a lower limit trades contention for memory.
+func WithMaxShardBits(bits uint8) Option {
+	return func(m *CMap) {
+		if bits < mInitBit {
+			bits = mInitBit
+		}
+		if bits > mMaxBit {
+			bits = mMaxBit
+		}
+		m.maxB = bits
+	}
+}
+
+// WithLoadFactor sets the load factors the map resizes at, relative to
+// the defaults of 1 and 0.25. The map grows once a bucket holds grow
+// times its default load, and shrinks once the map holds shrink times the
+// default load of the buckets it shrinks to. A higher grow trades longer
+// buckets for less memory and fewer resizes.
+//
+// shrink is limited to grow/2, so that a map shrinks well under the size
+// it grows back at, and doesn't resize back and forth when its size
+// oscillates. A zero shrink disables shrinking.
+func WithLoadFactor(grow, shrink float64) Option {
+	return func(m *CMap) {
+		if grow <= 0 {
+			return
+		}
+		if shrink < 0 {
+			shrink = 0
+		}
+		if shrink > grow/2 {
+			shrink = grow / 2
+		}
+		m.grow, m.shrink = grow, shrink
+	}
+}
+
+// WithShrinkDisabled keeps the map from shrinking as elements are
+// deleted, so that deletes never start a resize. Use ShrinkTo to shrink
+// the map when convenient.
+func WithShrinkDisabled() Option {
+	return func(m *CMap) {
+		m.noShrink = true
+	}
+}
+
+// WithSynchronousResize makes every resize evacuate all the buckets at
+// once, before the write which started it returns, instead of a group at
+// a time by the following writes. The map is then never seen in the
+// middle of a resize, which makes tests of the behavior around a resize
+// deterministic, at the cost of the latency of that write.
+func WithSynchronousResize() Option {
+	return func(m *CMap) {
+		m.syncResize = true
+	}
+}
+
+// WithApproximateLen makes Len return the last count of the map taken
+// less than d ago, instead of summing the counts of every bucket at each
+// call. Use it when Len is called far more often than it needs to change.
+// LenExact always sums them.
+func WithApproximateLen(d time.Duration) Option {
+	return func(m *CMap) {
+		if d > 0 {
+			m.lenEvery = int64(d)
+		}
+	}
+}
+
+// WithHashSeed sets the seed of the hashes of the keys, for tests which
+// need maps sharing the same buckets. By default each map draws a random
+// seed, so that the keys colliding in a map can't be known in advance.
+// A zero seed keeps the random one.
+//
+// Since Go 1.24, the hashes are also seeded once per process, so maps with
+// the same seed only share their buckets within a process.
+func WithHashSeed(seed uint64) Option {
+	return func(m *CMap) {
+		m.seed = uintptr(seed)
+	}
+}
+
+// WithPanicOnClosed makes every operation on the map panic with ErrClosed
+// once it is closed, to catch a use after Close.
+func WithPanicOnClosed() Option {
+	return func(m *CMap) {
+		m.panicClosed = true
+	}
+}
+
+// start launches the background work configured by options.
+func (m *CMap) start() {
+	if m.janitor != nil {
+		m.janitor.run(m)
+	}
+	if m.persister != nil {
+		m.persister.run()
+	}
+	if m.wal != nil {
+		// the changes are logged from the hub, in the order they are made
+		m.getChangeHub()
+		m.wal.q.run()
+	}
+}
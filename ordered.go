@@ -0,0 +1,264 @@
+package cmap
+
+import "sync"
+
+// oShards is the # of shards of an OrderedMap.
+const oShards = 16
+
+// maxLevel is the max height of a skiplist.
+const maxLevel = 24
+
+// OrderedMap is a concurrent map whose keys are kept sorted by a less
+// function. Each shard keeps its keys in a skiplist, and ordered reads
+// merge the shards.
+//
+// less must define a strict weak ordering, in which keys equal by == are
+// exactly the keys neither less than the other.
+type OrderedMap struct {
+	less   func(a, b interface{}) bool
+	shards [oShards]orderedShard
+}
+
+type orderedShard struct {
+	mu sync.RWMutex
+	l  skiplist
+}
+
+// NewOrderedMap returns an empty OrderedMap sorted by less.
+func NewOrderedMap(less func(a, b interface{}) bool) *OrderedMap {
+	return &OrderedMap{less: less}
+}
+
+func (m *OrderedMap) getShard(key interface{}) *orderedShard {
+	return &m.shards[chash(key, procSeed)%oShards]
+}
+
+// Load returns the value stored in the map for a key, or nil if no
+// value is present.
+// The ok result indicates whether value was found in the map.
+func (m *OrderedMap) Load(key interface{}) (value interface{}, ok bool) {
+	s := m.getShard(key)
+	s.mu.RLock()
+	if x := s.l.seek(m.less, key, nil); x != nil && x.key == key {
+		value, ok = x.value, true
+	}
+	s.mu.RUnlock()
+	return value, ok
+}
+
+// Store sets the value for a key.
+func (m *OrderedMap) Store(key, value interface{}) {
+	s := m.getShard(key)
+	s.mu.Lock()
+	s.l.insert(m.less, key, value)
+	s.mu.Unlock()
+}
+
+// LoadAndDelete deletes the value for a key, returning the previous value if any.
+// The loaded result reports whether the key was present.
+func (m *OrderedMap) LoadAndDelete(key interface{}) (value interface{}, loaded bool) {
+	s := m.getShard(key)
+	s.mu.Lock()
+	value, loaded = s.l.remove(m.less, key)
+	s.mu.Unlock()
+	return value, loaded
+}
+
+// Delete deletes the value for a key.
+func (m *OrderedMap) Delete(key interface{}) {
+	m.LoadAndDelete(key)
+}
+
+// Len returns the number of elements within the map.
+func (m *OrderedMap) Len() int {
+	n := 0
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		n += s.l.len
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+// Min returns the smallest key and its value, ok is false if the map is
+// empty.
+func (m *OrderedMap) Min() (key, value interface{}, ok bool) {
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		if x := s.l.head[0]; x != nil && (!ok || m.less(x.key, key)) {
+			key, value, ok = x.key, x.value, true
+		}
+		s.mu.RUnlock()
+	}
+	return key, value, ok
+}
+
+// Max returns the largest key and its value, ok is false if the map is
+// empty.
+func (m *OrderedMap) Max() (key, value interface{}, ok bool) {
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		if x := s.l.last(); x != nil && (!ok || m.less(key, x.key)) {
+			key, value, ok = x.key, x.value, true
+		}
+		s.mu.RUnlock()
+	}
+	return key, value, ok
+}
+
+// RangeAscending calls f sequentially for each key and value present in
+// the map with from <= key < to, in ascending order of keys. A nil from
+// or to leaves that end unbounded. If f returns false, range stops the
+// iteration.
+//
+// The entries of every shard are copied before f is called, so f may use
+// the map. Like Range, RangeAscending does not correspond to any
+// consistent snapshot of the map's contents.
+func (m *OrderedMap) RangeAscending(from, to interface{}, f func(key, value interface{}) bool) bool {
+	runs := make([][]Entry, oShards)
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		x := s.l.head[0]
+		if from != nil {
+			x = s.l.seek(m.less, from, nil)
+		}
+		for ; x != nil && (to == nil || m.less(x.key, to)); x = x.next[0] {
+			runs[i] = append(runs[i], Entry{x.key, x.value})
+		}
+		s.mu.RUnlock()
+	}
+	return mergeRuns(runs, m.less, f)
+}
+
+// mergeRuns calls f sequentially for each entry of runs, sorted by less,
+// in ascending order of keys. If f returns false, mergeRuns stops.
+func mergeRuns(runs [][]Entry, less func(a, b interface{}) bool, f func(key, value interface{}) bool) bool {
+	for {
+		min := -1
+		for i := range runs {
+			if len(runs[i]) > 0 && (min < 0 || less(runs[i][0].Key, runs[min][0].Key)) {
+				min = i
+			}
+		}
+		if min < 0 {
+			return true
+		}
+		e := runs[min][0]
+		runs[min] = runs[min][1:]
+		if !f(e.Key, e.Value) {
+			return false
+		}
+	}
+}
+
+// skiplist is a sorted list of keys, not safe for concurrent use.
+type skiplist struct {
+	head  [maxLevel]*slnode
+	level int
+	len   int
+	rnd   uint64
+}
+
+type slnode struct {
+	key, value interface{}
+	next       []*slnode
+}
+
+// seek returns the first node whose key is not less than key, or nil.
+// If prev is not nil, it is set to the last node before it at each level,
+// nil standing for the head.
+func (l *skiplist) seek(less func(a, b interface{}) bool, key interface{}, prev *[maxLevel]*slnode) *slnode {
+	var x *slnode
+	for i := l.level - 1; i >= 0; i-- {
+		next := l.head[i]
+		if x != nil {
+			next = x.next[i]
+		}
+		for next != nil && less(next.key, key) {
+			x, next = next, next.next[i]
+		}
+		if prev != nil {
+			prev[i] = x
+		}
+	}
+	if x == nil {
+		return l.head[0]
+	}
+	return x.next[0]
+}
+
+func (l *skiplist) link(prev *slnode, i int) **slnode {
+	if prev == nil {
+		return &l.head[i]
+	}
+	return &prev.next[i]
+}
+
+// randomLevel returns the height of a new node, with p = 1/4 per level.
+func (l *skiplist) randomLevel() int {
+	// xorshift64
+	if l.rnd == 0 {
+		l.rnd = 0x9e3779b97f4a7c15
+	}
+	l.rnd ^= l.rnd << 13
+	l.rnd ^= l.rnd >> 7
+	l.rnd ^= l.rnd << 17
+	level := 1
+	for r := l.rnd; level < maxLevel && r&3 == 0; r >>= 2 {
+		level++
+	}
+	return level
+}
+
+func (l *skiplist) insert(less func(a, b interface{}) bool, key, value interface{}) {
+	var prev [maxLevel]*slnode
+	if x := l.seek(less, key, &prev); x != nil && x.key == key {
+		x.value = value
+		return
+	}
+	level := l.randomLevel()
+	for ; l.level < level; l.level++ {
+		prev[l.level] = nil
+	}
+	x := &slnode{key: key, value: value, next: make([]*slnode, level)}
+	for i := 0; i < level; i++ {
+		p := l.link(prev[i], i)
+		x.next[i], *p = *p, x
+	}
+	l.len++
+}
+
+func (l *skiplist) remove(less func(a, b interface{}) bool, key interface{}) (value interface{}, ok bool) {
+	var prev [maxLevel]*slnode
+	x := l.seek(less, key, &prev)
+	if x == nil || x.key != key {
+		return nil, false
+	}
+	for i := range x.next {
+		*l.link(prev[i], i) = x.next[i]
+	}
+	for l.level > 0 && l.head[l.level-1] == nil {
+		l.level--
+	}
+	l.len--
+	return x.value, true
+}
+
+// last returns the node of the largest key, or nil.
+func (l *skiplist) last() *slnode {
+	var x *slnode
+	for i := l.level - 1; i >= 0; i-- {
+		next := l.head[i]
+		if x != nil {
+			next = x.next[i]
+		}
+		for next != nil {
+			x, next = next, next.next[i]
+		}
+	}
+	return x
+}
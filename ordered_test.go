@@ -0,0 +1,76 @@
+package cmap_test
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func intLess(a, b interface{}) bool { return a.(int) < b.(int) }
+
+func TestOrderedMap(t *testing.T) {
+	m := cmap.NewOrderedMap(intLess)
+	if _, _, ok := m.Min(); ok {
+		t.Fatalf("Min of an empty map")
+	}
+
+	const n = 1000
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for _, i := range rand.Perm(n) {
+				if i%4 == g {
+					m.Store(i, -i)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	m.Store(500, -500)
+
+	if m.Len() != n {
+		t.Fatalf("Len() = %d, want %d", m.Len(), n)
+	}
+	if k, v, ok := m.Min(); !ok || k != 0 || v != 0 {
+		t.Fatalf("Min() = %v, %v, %v", k, v, ok)
+	}
+	if k, v, ok := m.Max(); !ok || k != n-1 || v != -(n-1) {
+		t.Fatalf("Max() = %v, %v, %v", k, v, ok)
+	}
+	for i := 0; i < n; i += 2 {
+		if v, loaded := m.LoadAndDelete(i); !loaded || v != -i {
+			t.Fatalf("LoadAndDelete(%d) = %v, %v", i, v, loaded)
+		}
+	}
+	if v, ok := m.Load(501); !ok || v != -501 {
+		t.Fatalf("Load(501) = %v, %v", v, ok)
+	}
+	if _, ok := m.Load(500); ok {
+		t.Fatalf("Load(500) after delete")
+	}
+
+	want := 101
+	m.RangeAscending(100, 200, func(key, value interface{}) bool {
+		if key != want || value != -want {
+			t.Fatalf("RangeAscending saw %v: %v, want %d", key, value, want)
+		}
+		want += 2
+		return true
+	})
+	if want != 201 {
+		t.Fatalf("RangeAscending stopped before %d", want)
+	}
+
+	count := 0
+	m.RangeAscending(nil, nil, func(key, value interface{}) bool {
+		count++
+		return count < 10
+	})
+	if count != 10 {
+		t.Fatalf("RangeAscending did not stop")
+	}
+}
@@ -0,0 +1,80 @@
+package cmap
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// Cursor is a position in the map for RangePage. The zero Cursor is the
+// start of the map.
+//
+// The map is walked in the order of the bit-reversed hashes of its keys,
+// which keeps the keys of every bucket together whatever the number of
+// buckets, so a Cursor stays valid across resizes.
+type Cursor struct {
+	from uint // least reversed hash of the next page
+	end  bool
+}
+
+// Done reports whether the cursor is at the end of the map.
+func (c Cursor) Done() bool {
+	return c.end
+}
+
+// RangePage returns up to limit entries of the map from cursor, and the
+// cursor of the next page. Entries whose keys have equal hashes are never
+// split across pages, so a page may exceed limit in that rare case.
+//
+// A key present during the whole walk is returned exactly once, even if
+// the map resizes between pages. Keys stored or deleted meanwhile may or
+// may not be returned.
+func (m *CMap) RangePage(cursor Cursor, limit int) (entries []Entry, next Cursor) {
+	if cursor.end {
+		return nil, cursor
+	}
+	if limit < 1 {
+		limit = 1
+	}
+	n := m.getNode()
+	shift := uint(bits.UintSize) - uint(n.B)
+	type revEntry struct {
+		rev uint
+		Entry
+	}
+	var page []revEntry
+	for k := cursor.from >> shift; k <= uint(n.mask); k++ {
+		i := uintptr(bits.Reverse(k << shift))
+		var found []revEntry
+		n.loadBucket(i).rangeLive(func(key, value interface{}) bool {
+			if rev := bits.Reverse(uint(m.keyHash(key))); rev >= cursor.from {
+				found = append(found, revEntry{rev, Entry{key, value}})
+			}
+			return true
+		})
+		sort.Slice(found, func(a, b int) bool { return found[a].rev < found[b].rev })
+		page = append(page, found...)
+		if len(page) < limit {
+			continue
+		}
+
+		last := page[limit-1].rev
+		end := limit
+		for end < len(page) && page[end].rev == last {
+			end++
+		}
+		entries = make([]Entry, end)
+		for j := range entries {
+			entries[j] = page[j].Entry
+		}
+		if last == ^uint(0) {
+			return entries, Cursor{end: true}
+		}
+		return entries, Cursor{from: last + 1}
+	}
+
+	entries = make([]Entry, len(page))
+	for j := range entries {
+		entries[j] = page[j].Entry
+	}
+	return entries, Cursor{end: true}
+}
@@ -0,0 +1,45 @@
+package cmap_test
+
+import (
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestRangePage(t *testing.T) {
+	var m cmap.CMap
+	const n = 5000
+	for i := 0; i < n; i++ {
+		m.Store(i, i)
+	}
+
+	seen := make(map[interface{}]int)
+	var c cmap.Cursor
+	pages := 0
+	for !c.Done() {
+		var entries []cmap.Entry
+		entries, c = m.RangePage(c, 100)
+		pages++
+		for _, e := range entries {
+			seen[e.Key]++
+		}
+		if pages == 10 {
+			// resize between pages
+			m.ForceResize(12)
+		}
+		if pages == 20 {
+			m.ForceResize(5)
+		}
+	}
+	if len(seen) != n {
+		t.Fatalf("RangePage returned %d keys, want %d", len(seen), n)
+	}
+	for k, c := range seen {
+		if c != 1 {
+			t.Fatalf("RangePage returned %v %d times", k, c)
+		}
+	}
+	if pages < n/100 {
+		t.Fatalf("RangePage returned %d pages", pages)
+	}
+}
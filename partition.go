@@ -0,0 +1,31 @@
+package cmap
+
+// WithPartitioner makes the keys with the same partition share a bucket,
+// whatever the number of buckets: the bucket of a key is chosen from
+// partition(key), and its hash only tells apart the keys of a bucket.
+// Operations on keys of one partition then lock a single bucket, as
+// DoAtomic does, but a large partition makes a large bucket, which never
+// splits as the map grows.
+//
+// partition must return the same value for equal keys, and must not keep
+// key: Load hides it from escape analysis, so it may be on the stack of
+// the caller of Load.
+func WithPartitioner(partition func(key interface{}) uintptr) Option {
+	return func(m *CMap) {
+		m.part = partition
+	}
+}
+
+// keyHash returns the hash of key in m. With a partitioner, the bits
+// which may choose a bucket come from the partition of key, and the others
+// from its hash, which the swiss tables and the bloom filter remix to
+// tell apart the keys of a partition.
+func (m *CMap) keyHash(key interface{}) uintptr {
+	hash := chash(key, m.seed)
+	if m.part == nil {
+		return hash
+	}
+	b := m.maxBit()
+	part := uintptr(scramble(uint64(m.part(key)) ^ uint64(m.seed)))
+	return hash<<b | part&(1<<b-1)
+}
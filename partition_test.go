@@ -0,0 +1,55 @@
+package cmap_test
+
+import (
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+type tenantKey struct {
+	tenant, id int
+}
+
+func TestWithPartitioner(t *testing.T) {
+	for _, swiss := range []bool{false, true} {
+		opts := []cmap.Option{cmap.WithPartitioner(func(key interface{}) uintptr {
+			return uintptr(key.(tenantKey).tenant)
+		})}
+		if swiss {
+			opts = append(opts, cmap.WithSwissBuckets())
+		}
+		m := cmap.New(opts...)
+		for i := 0; i < 1000; i++ {
+			m.Store(tenantKey{i % 4, i}, i)
+		}
+		for i := 0; i < 1000; i++ {
+			if v, ok := m.Load(tenantKey{i % 4, i}); !ok || v != i {
+				t.Fatalf("swiss %v: Load(%d) = %v, %v", swiss, i, v, ok)
+			}
+		}
+		// two partitions may share a bucket, one never spreads over two
+		used := 0
+		for _, c := range m.Stats().Buckets {
+			if c > 0 {
+				used++
+			}
+		}
+		if used > 4 {
+			t.Fatalf("swiss %v: keys of 4 partitions spread over %d buckets", swiss, used)
+		}
+		for i := 4; i < 1000; i++ {
+			if s, want := m.ShardFor(tenantKey{i % 4, i}), m.ShardFor(tenantKey{i % 4, 0}); s != want {
+				t.Fatalf("swiss %v: key %d of partition %d in bucket %d, want %d", swiss, i, i%4, s, want)
+			}
+		}
+		for i := 0; i < 1000; i += 2 {
+			m.Delete(tenantKey{i % 4, i})
+		}
+		if n := m.Len(); n != 500 {
+			t.Fatalf("swiss %v: Len() = %d after deletes, want 500", swiss, n)
+		}
+		if n := m.Clone().Len(); n != 500 {
+			t.Fatalf("swiss %v: Clone().Len() = %d, want 500", swiss, n)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package cmap
+
+import (
+	"context"
+	"sync"
+)
+
+// Persister receives the changes of a CMap, see WithPersister.
+type Persister interface {
+	// OnStore is called with a value stored for a key.
+	OnStore(key, value interface{}) error
+	// OnDelete is called with a key deleted or expired.
+	OnDelete(key interface{}) error
+	// Flush is called by CMap.Flush once the changes before it are
+	// written.
+	Flush(ctx context.Context) error
+}
+
+// WithPersister makes the map send its changes to p, from a goroutine
+// reading a queue of size entries. Writers block while the queue is full,
+// so a slow Persister slows writers down instead of losing changes.
+//
+// Changes racing on the same key may reach p in either order. Call Flush
+// to wait for the queued changes, and Close to stop the goroutine.
+func WithPersister(p Persister, size int) Option {
+	return func(m *CMap) {
+		if size < 1 {
+			size = 1
+		}
+		m.persister = &persister{p: p, ops: make(chan persistOp, size)}
+	}
+}
+
+// Flush waits until the changes queued for the Persister are written, and
+// flushes it, and the WAL alike. It returns the first error of the
+// Persister or the WAL since the last Flush, or ctx.Err() if ctx is done
+// first. Without Persister nor WAL, Flush returns nil.
+func (m *CMap) Flush(ctx context.Context) error {
+	if err := m.checkOpen(); err != nil {
+		return err
+	}
+	var err error
+	if m.persister != nil {
+		err = m.persister.flush(ctx)
+	}
+	if m.wal != nil {
+		if werr := m.wal.q.flush(ctx); err == nil {
+			err = werr
+		}
+	}
+	return err
+}
+
+// flush waits until the changes queued are written, and flushes the
+// Persister.
+func (q *persister) flush(ctx context.Context) error {
+	done := make(chan error, 1)
+	select {
+	case q.ops <- persistOp{flush: done}:
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if ferr := q.p.Flush(ctx); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+type persistOp struct {
+	del            bool
+	key, value     interface{}
+	deadline, idle int64        // ttl of value, see entryPersister
+	flush          chan<- error // set for a flush marker
+}
+
+// entryPersister is a Persister also given the ttl of the values stored,
+// like the WAL.
+type entryPersister interface {
+	storeEntry(e SnapshotEntry) error
+}
+
+// persister writes the changes of a CMap to a Persister.
+type persister struct {
+	p    Persister
+	ops  chan persistOp
+	err  error // first error since the last flush
+	once sync.Once
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func (q *persister) run() {
+	q.done = make(chan struct{})
+	q.wg.Add(1)
+	go func() {
+		defer q.wg.Done()
+		for {
+			select {
+			case op := <-q.ops:
+				q.write(op)
+			case <-q.done:
+				return
+			}
+		}
+	}()
+}
+
+func (q *persister) write(op persistOp) {
+	var err error
+	switch {
+	case op.flush != nil:
+		op.flush <- q.err
+		q.err = nil
+		return
+	case op.del:
+		err = q.p.OnDelete(op.key)
+	default:
+		if p, ok := q.p.(entryPersister); ok {
+			err = p.storeEntry(SnapshotEntry{Key: op.key, Value: op.value, Deadline: unixNano(op.deadline), Idle: op.idle})
+		} else {
+			err = q.p.OnStore(op.key, op.value)
+		}
+	}
+	if q.err == nil {
+		q.err = err
+	}
+}
+
+func (q *persister) send(op persistOp) {
+	select {
+	case q.ops <- op:
+	case <-q.done:
+	}
+}
+
+// drain writes the changes left in the queue by stop.
+func (q *persister) drain() {
+	for {
+		select {
+		case op := <-q.ops:
+			q.write(op)
+		default:
+			return
+		}
+	}
+}
+
+func (q *persister) stop() {
+	q.once.Do(func() {
+		close(q.done)
+		q.wg.Wait()
+	})
+}
@@ -0,0 +1,83 @@
+package cmap_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/min1324/cmap"
+)
+
+type memPersister struct {
+	mu      sync.Mutex
+	data    map[interface{}]interface{}
+	flushes int
+	fail    error
+}
+
+func (p *memPersister) OnStore(key, value interface{}) error {
+	time.Sleep(time.Microsecond)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.data[key] = value
+	return p.fail
+}
+
+func (p *memPersister) OnDelete(key interface{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.data, key)
+	return p.fail
+}
+
+func (p *memPersister) Flush(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.flushes++
+	return nil
+}
+
+func TestPersister(t *testing.T) {
+	p := &memPersister{data: make(map[interface{}]interface{})}
+	m := cmap.New(cmap.WithPersister(p, 4))
+	defer m.Close()
+
+	for i := 0; i < 100; i++ {
+		m.Store(i, i)
+	}
+	for i := 0; i < 100; i += 2 {
+		m.Delete(i)
+	}
+	if err := m.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() = %v", err)
+	}
+	p.mu.Lock()
+	if len(p.data) != 50 || p.data[1] != 1 || p.flushes != 1 {
+		t.Fatalf("persisted %d keys, %d flushes", len(p.data), p.flushes)
+	}
+	p.fail = errors.New("disk full")
+	p.mu.Unlock()
+
+	m.Store(1000, 0)
+	if err := m.Flush(context.Background()); err == nil {
+		t.Fatalf("Flush() did not report the Persister error")
+	}
+	p.mu.Lock()
+	p.fail = nil
+	p.mu.Unlock()
+	if err := m.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() reported an old error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p.mu.Lock()
+	m.Store(1001, 0) // queued while the Persister is blocked
+	err := m.Flush(ctx)
+	p.mu.Unlock()
+	if err != context.Canceled {
+		t.Fatalf("Flush(canceled) = %v", err)
+	}
+}
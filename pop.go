@@ -0,0 +1,51 @@
+package cmap
+
+import (
+	"math/rand"
+	"sync/atomic"
+)
+
+// Pop deletes key and returns its value, like LoadAndDelete, for maps used
+// as a pool of pending items: of concurrent Pops of a key, only one gets
+// its value.
+func (m *CMap) Pop(key interface{}) (value interface{}, ok bool) {
+	return m.LoadAndDelete(key)
+}
+
+// PopAny deletes an arbitrary key of the map, and returns it with its
+// value. ok is false if the map is empty.
+//
+// PopAny visits the buckets from a random one until it deletes a key, so
+// that concurrent callers mostly take keys of different buckets, and does
+// not scan the map unless it is almost empty.
+func (m *CMap) PopAny() (key, value interface{}, ok bool) {
+	for m.checkOpen() == nil {
+		n := m.getNode()
+		start := uintptr(rand.Int63())
+		seen := false
+		for i := uintptr(0); i <= n.mask; i++ {
+			b := n.loadBucket((start + i) & n.mask)
+			if atomic.LoadInt64(&b.count) == 0 {
+				continue
+			}
+			var k interface{}
+			found := false
+			b.rangeLive(func(key, _ interface{}) bool {
+				k, found = key, true
+				return false
+			})
+			if !found {
+				continue
+			}
+			seen = true
+			if v, loaded := m.LoadAndDelete(k); loaded {
+				return k, v, true
+			}
+			// taken by another caller
+		}
+		if !seen {
+			break
+		}
+	}
+	return nil, nil, false
+}
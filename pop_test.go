@@ -0,0 +1,58 @@
+package cmap_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestPop(t *testing.T) {
+	var m cmap.CMap
+	m.Store("a", 1)
+	if v, ok := m.Pop("a"); !ok || v != 1 {
+		t.Fatalf("Pop(a) = %v, %v; want 1, true", v, ok)
+	}
+	if _, ok := m.Pop("a"); ok {
+		t.Fatalf("Pop(a) twice found a value")
+	}
+	if _, _, ok := m.PopAny(); ok {
+		t.Fatalf("PopAny found a key in an empty map")
+	}
+}
+
+func TestPopAny(t *testing.T) {
+	var m cmap.CMap
+	const n = 10000
+	for i := 0; i < n; i++ {
+		m.Store(i, i)
+	}
+	var mu sync.Mutex
+	popped := make(map[interface{}]bool, n)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				k, v, ok := m.PopAny()
+				if !ok {
+					return
+				}
+				if k != v {
+					t.Errorf("PopAny() = %v, %v", k, v)
+				}
+				mu.Lock()
+				if popped[k] {
+					t.Errorf("key %v popped twice", k)
+				}
+				popped[k] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(popped) != n || m.Len() != 0 {
+		t.Fatalf("popped %d keys, %d left; want %d, 0", len(popped), m.Len(), n)
+	}
+}
@@ -0,0 +1,176 @@
+package cmap
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+func stringLess(a, b interface{}) bool {
+	return a.(string) < b.(string)
+}
+
+// NewIndexedStringMap returns an empty StringMap which keeps the keys of
+// each shard sorted, for ScanPrefix. The index makes inserts and deletes
+// slower, updates of present keys and reads are unaffected.
+func NewIndexedStringMap() *StringMap {
+	m := new(StringMap)
+	for i := range m.shards {
+		m.shards[i].index = new(skiplist)
+	}
+	return m
+}
+
+// ScanPrefix calls f sequentially for each key starting with prefix and
+// its value, in ascending order of keys. If f returns false, the scan
+// stops.
+//
+// A map created by NewIndexedStringMap only visits the matching keys,
+// others are scanned entirely. The matching entries are copied before f
+// is called, so f may use the map.
+func (m *StringMap) ScanPrefix(prefix string, f func(key string, value interface{}) bool) bool {
+	runs := make([][]Entry, len(m.shards))
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		if s.index != nil {
+			for x := s.index.seek(stringLess, prefix, nil); x != nil && strings.HasPrefix(x.key.(string), prefix); x = x.next[0] {
+				runs[i] = append(runs[i], Entry{x.key, s.m[x.key.(string)].value})
+			}
+		} else {
+			for k, e := range s.m {
+				if strings.HasPrefix(k, prefix) {
+					runs[i] = append(runs[i], Entry{k, e.value})
+				}
+			}
+		}
+		s.mu.RUnlock()
+		if s.index == nil {
+			run := runs[i]
+			sort.Slice(run, func(a, b int) bool {
+				return run[a].Key.(string) < run[b].Key.(string)
+			})
+		}
+	}
+	return mergeRuns(runs, stringLess, func(key, value interface{}) bool {
+		return f(key.(string), value)
+	})
+}
+
+// prefixFilterSize is the number of counters of the prefix filter of a
+// shard, see NewPrefixFilteredStringMap.
+const prefixFilterSize = 1 << 10
+
+// NewPrefixFilteredStringMap returns an empty StringMap which keeps a
+// Bloom filter of the first n bytes of the keys of each shard, so that
+// KeysMatching skips the shards without a key starting with the literal
+// prefix of its pattern, if it is at least n bytes long, without locking
+// them. n is the length of the common prefixes of the keys, like
+// len("user:"); inserts and deletes update the filter.
+func NewPrefixFilteredStringMap(n int) *StringMap {
+	if n < 1 {
+		n = 1
+	}
+	m := new(StringMap)
+	for i := range m.shards {
+		m.shards[i].prefixes, m.shards[i].plen = newBloom(prefixFilterSize), n
+	}
+	return m
+}
+
+// KeysMatching returns the keys matching pattern, in ascending order. In
+// pattern, * matches any sequence of bytes, ? any single character, and
+// \ quotes the next byte: "user:*" matches the keys with the prefix
+// "user:", and "user:??:name" the keys like "user:42:name".
+//
+// The keys are matched against the literal prefix of pattern first: a
+// map created by NewIndexedStringMap only visits the keys with the
+// prefix, and one created by NewPrefixFilteredStringMap skips the shards
+// without any.
+func (m *StringMap) KeysMatching(pattern string) []string {
+	prefix := globPrefix(pattern)
+	var keys []string
+	for i := range m.shards {
+		s := &m.shards[i]
+		if s.prefixes != nil && len(prefix) >= s.plen && !s.prefixes.mayContain(shash(prefix[:s.plen])) {
+			continue
+		}
+		s.mu.RLock()
+		if s.index != nil {
+			for x := s.index.seek(stringLess, prefix, nil); x != nil && strings.HasPrefix(x.key.(string), prefix); x = x.next[0] {
+				if k := x.key.(string); matchGlob(pattern, k) {
+					keys = append(keys, k)
+				}
+			}
+		} else {
+			for k := range s.m {
+				if strings.HasPrefix(k, prefix) && matchGlob(pattern, k) {
+					keys = append(keys, k)
+				}
+			}
+		}
+		s.mu.RUnlock()
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// globPrefix returns the literal prefix of pattern, which every key
+// matching it starts with.
+func globPrefix(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*', '?':
+			return b.String()
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+		}
+		b.WriteByte(pattern[i])
+	}
+	return b.String()
+}
+
+// matchGlob reports whether s matches pattern, see KeysMatching. A *
+// first matches nothing, and one more byte each time the rest of pattern
+// fails to match.
+func matchGlob(pattern, s string) bool {
+	star, next := -1, 0 // the last * seen, and where it ends in s
+	p, i := 0, 0
+	for i < len(s) {
+		if p < len(pattern) {
+			switch c := pattern[p]; c {
+			case '*':
+				star, next = p, i
+				p++
+				continue
+			case '?':
+				_, size := utf8.DecodeRuneInString(s[i:])
+				p, i = p+1, i+size
+				continue
+			case '\\':
+				if p+1 < len(pattern) {
+					c = pattern[p+1]
+					p++
+				}
+				fallthrough
+			default:
+				if c == s[i] {
+					p, i = p+1, i+1
+					continue
+				}
+			}
+		}
+		if star < 0 {
+			return false
+		}
+		next++
+		p, i = star+1, next
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
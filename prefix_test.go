@@ -0,0 +1,90 @@
+package cmap_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestScanPrefix(t *testing.T) {
+	for _, indexed := range []bool{false, true} {
+		m := new(cmap.StringMap)
+		if indexed {
+			m = cmap.NewIndexedStringMap()
+		}
+		for i := 0; i < 100; i++ {
+			m.Store(fmt.Sprintf("user:%02d", i), i)
+			m.Store(fmt.Sprintf("session:%02d", i), i)
+		}
+		for i := 0; i < 100; i += 2 {
+			m.Delete(fmt.Sprintf("user:%02d", i))
+		}
+		m.StoreBytes([]byte("user:01"), 1)
+
+		want := 1
+		m.ScanPrefix("user:", func(key string, value interface{}) bool {
+			if key != fmt.Sprintf("user:%02d", want) || value != want {
+				t.Fatalf("indexed=%v: ScanPrefix saw %q: %v, want user:%02d", indexed, key, value, want)
+			}
+			want += 2
+			return true
+		})
+		if want != 101 {
+			t.Fatalf("indexed=%v: ScanPrefix stopped before user:%02d", indexed, want)
+		}
+
+		n := 0
+		m.ScanPrefix("session:1", func(key string, value interface{}) bool {
+			n++
+			return true
+		})
+		if n != 10 {
+			t.Fatalf(`indexed=%v: ScanPrefix("session:1") saw %d keys, want 10`, indexed, n)
+		}
+	}
+}
+
+func TestKeysMatching(t *testing.T) {
+	for name, m := range map[string]*cmap.StringMap{
+		"plain":    new(cmap.StringMap),
+		"indexed":  cmap.NewIndexedStringMap(),
+		"filtered": cmap.NewPrefixFilteredStringMap(len("user:")),
+	} {
+		for i := 0; i < 20; i++ {
+			m.Store(fmt.Sprintf("user:%02d:name", i), i)
+			m.Store(fmt.Sprintf("session:%02d", i), i)
+		}
+		m.Store("user:*", 0)
+		m.Store("ü:1", 0)
+		m.Store("u", 0)
+		m.Delete("user:19:name")
+
+		for _, tc := range []struct {
+			pattern string
+			want    []string
+		}{
+			{"user:0*", []string{"user:00:name", "user:01:name", "user:02:name", "user:03:name", "user:04:name",
+				"user:05:name", "user:06:name", "user:07:name", "user:08:name", "user:09:name"}},
+			{"user:1?:name", []string{"user:10:name", "user:11:name", "user:12:name", "user:13:name", "user:14:name",
+				"user:15:name", "user:16:name", "user:17:name", "user:18:name"}},
+			{"*:1?", []string{"session:10", "session:11", "session:12", "session:13", "session:14",
+				"session:15", "session:16", "session:17", "session:18", "session:19"}},
+			{`user:\*`, []string{"user:*"}},
+			{"?:1", []string{"ü:1"}},
+			{"u", []string{"u"}},
+			{"user:19*", nil},
+			{"admin:*", nil},
+			{"*r:0*e", []string{"user:00:name", "user:01:name", "user:02:name", "user:03:name", "user:04:name",
+				"user:05:name", "user:06:name", "user:07:name", "user:08:name", "user:09:name"}},
+		} {
+			if got := m.KeysMatching(tc.pattern); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("%s: KeysMatching(%q) = %q, want %q", name, tc.pattern, got, tc.want)
+			}
+		}
+		if n := len(m.KeysMatching("*")); n != m.Len() {
+			t.Errorf("%s: KeysMatching(*) has %d keys, want %d", name, n, m.Len())
+		}
+	}
+}
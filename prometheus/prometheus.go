@@ -0,0 +1,68 @@
+// Package prometheus exports the metrics of a metrics.Instrumented through
+// a prometheus.Collector, to be registered like the collectors of the
+// Prometheus client.
+//
+// It is a module of its own, so that cmap does not depend on the
+// Prometheus client.
+package prometheus
+
+import (
+	"gitee.com/absir_admin/cmap/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector collects the metrics of a metrics.Instrumented, labeled with
+// map="name" after its name.
+type Collector struct {
+	i *metrics.Instrumented
+
+	loads    *prometheus.Desc
+	duration *prometheus.Desc
+	entries  *prometheus.Desc
+	resizes  *prometheus.Desc
+	resizing *prometheus.Desc
+}
+
+// NewCollector returns a Collector of the metrics of i.
+func NewCollector(i *metrics.Instrumented) *Collector {
+	labels := prometheus.Labels{"map": i.Name()}
+	return &Collector{
+		i:        i,
+		loads:    prometheus.NewDesc("cmap_loads_total", "Number of lookups by result.", []string{"result"}, labels),
+		duration: prometheus.NewDesc("cmap_op_duration_seconds", "Duration of the operations.", []string{"op"}, labels),
+		entries:  prometheus.NewDesc("cmap_entries", "Number of elements in the map.", nil, labels),
+		resizes:  prometheus.NewDesc("cmap_resizes_total", "Number of resizes by direction.", []string{"direction"}, labels),
+		resizing: prometheus.NewDesc("cmap_resizing", "Whether the map is being resized.", nil, labels),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.loads
+	ch <- c.duration
+	ch <- c.entries
+	ch <- c.resizes
+	ch <- c.resizing
+}
+
+// Collect implements prometheus.Collector, from a Snapshot of the metrics.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	s := c.i.Snapshot()
+	ch <- prometheus.MustNewConstMetric(c.loads, prometheus.CounterValue, float64(s.Hits), "hit")
+	ch <- prometheus.MustNewConstMetric(c.loads, prometheus.CounterValue, float64(s.Misses), "miss")
+	for op, calls := range s.Calls {
+		buckets := make(map[float64]uint64, len(metrics.LatencyBounds))
+		for b, n := range s.Latency[op] {
+			buckets[metrics.LatencyBounds[b].Seconds()] = n
+		}
+		ch <- prometheus.MustNewConstHistogram(c.duration, calls, s.Duration[op].Seconds(), buckets, op)
+	}
+	ch <- prometheus.MustNewConstMetric(c.entries, prometheus.GaugeValue, float64(s.Len))
+	ch <- prometheus.MustNewConstMetric(c.resizes, prometheus.CounterValue, float64(s.Grows), "grow")
+	ch <- prometheus.MustNewConstMetric(c.resizes, prometheus.CounterValue, float64(s.Shrinks), "shrink")
+	resizing := 0.0
+	if s.Resizing {
+		resizing = 1
+	}
+	ch <- prometheus.MustNewConstMetric(c.resizing, prometheus.GaugeValue, resizing)
+}
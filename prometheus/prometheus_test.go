@@ -0,0 +1,65 @@
+package prometheus_test
+
+import (
+	"testing"
+
+	"gitee.com/absir_admin/cmap"
+	"gitee.com/absir_admin/cmap/metrics"
+	cmapprom "gitee.com/absir_admin/cmap/prometheus"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCollector(t *testing.T) {
+	m := metrics.New("test", new(cmap.CMap))
+	m.Store("a", 1)
+	m.Load("a")
+	m.Load("b")
+
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(cmapprom.NewCollector(m)); err != nil {
+		t.Fatalf("Register() = %v", err)
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() = %v", err)
+	}
+	got := make(map[string]float64)
+	for _, f := range families {
+		for _, metric := range f.Metric {
+			name := f.GetName()
+			for _, l := range metric.Label {
+				if l.GetName() != "map" {
+					name += "/" + l.GetValue()
+				} else if l.GetValue() != "test" {
+					t.Errorf("%s labeled map=%q", f.GetName(), l.GetValue())
+				}
+			}
+			switch {
+			case metric.Counter != nil:
+				got[name] = metric.Counter.GetValue()
+			case metric.Gauge != nil:
+				got[name] = metric.Gauge.GetValue()
+			case metric.Histogram != nil:
+				h := metric.Histogram
+				got[name] = float64(h.GetSampleCount())
+				if len(h.Bucket) != len(metrics.LatencyBounds) {
+					t.Errorf("%s has %d buckets, want %d", name, len(h.Bucket), len(metrics.LatencyBounds))
+				}
+			}
+		}
+	}
+	for name, want := range map[string]float64{
+		"cmap_loads_total/hit":           1,
+		"cmap_loads_total/miss":          1,
+		"cmap_op_duration_seconds/load":  2,
+		"cmap_op_duration_seconds/store": 1,
+		"cmap_op_duration_seconds/range": 0,
+		"cmap_entries":                   1,
+		"cmap_resizes_total/grow":        0,
+		"cmap_resizing":                  0,
+	} {
+		if v, ok := got[name]; !ok || v != want {
+			t.Errorf("%s = %v, %v; want %v", name, v, ok, want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package cmap
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// CountIf returns the number of entries for which pred returns true.
+// The buckets are scanned in parallel, so pred must be safe for
+// concurrent use.
+func (m *CMap) CountIf(pred func(key, value interface{}) bool) int {
+	var count int64
+	n := m.getNode()
+	n.parallel(0, func(i uintptr) {
+		var c int64
+		n.loadBucket(i).rangeLive(func(key, value interface{}) bool {
+			if pred(key, value) {
+				c++
+			}
+			return true
+		})
+		atomic.AddInt64(&count, c)
+	})
+	return int(count)
+}
+
+// Any reports whether pred returns true for some entry.
+// The buckets are scanned in parallel, and every goroutine stops at the
+// first match, so pred must be safe for concurrent use.
+func (m *CMap) Any(pred func(key, value interface{}) bool) bool {
+	_, _, ok := m.Find(pred)
+	return ok
+}
+
+// Find returns an entry for which pred returns true, ok is false if there
+// is none. The buckets are scanned in parallel, and every goroutine stops
+// at the first match, so pred must be safe for concurrent use, and the
+// entry found is any of the matching ones.
+func (m *CMap) Find(pred func(key, value interface{}) bool) (key, value interface{}, ok bool) {
+	var found uint32
+	var once sync.Once
+	n := m.getNode()
+	n.parallel(0, func(i uintptr) {
+		if atomic.LoadUint32(&found) != 0 {
+			return
+		}
+		n.loadBucket(i).rangeLive(func(k, v interface{}) bool {
+			if atomic.LoadUint32(&found) != 0 {
+				return false
+			}
+			if !pred(k, v) {
+				return true
+			}
+			once.Do(func() {
+				key, value, ok = k, v, true
+				atomic.StoreUint32(&found, 1)
+			})
+			return false
+		})
+	})
+	return key, value, ok
+}
@@ -0,0 +1,33 @@
+package cmap_test
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestCountIfAnyFind(t *testing.T) {
+	var m cmap.CMap
+	for i := 0; i < 1000; i++ {
+		m.Store(i, i)
+	}
+	if n := m.CountIf(func(key, value interface{}) bool { return value.(int) < 100 }); n != 100 {
+		t.Fatalf("CountIf() = %d, want 100", n)
+	}
+	if m.Any(func(key, value interface{}) bool { return value.(int) < 0 }) {
+		t.Fatalf("Any() found a negative value")
+	}
+
+	var calls int64
+	k, v, ok := m.Find(func(key, value interface{}) bool {
+		atomic.AddInt64(&calls, 1)
+		return value.(int)%7 == 3
+	})
+	if !ok || v.(int)%7 != 3 || k != v {
+		t.Fatalf("Find() = %v, %v, %v", k, v, ok)
+	}
+	if calls >= 1000 {
+		t.Fatalf("Find() did not stop early: %d calls", calls)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package cmap_test
+
+// raceEnabled is true in the tests run with the race detector, which
+// makes sync.Pool drop some of the values put in it.
+const raceEnabled = true
@@ -0,0 +1,271 @@
+package cmap
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Entry is a key and its value copied out of a map.
+type Entry struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// RangeSnapshot calls f sequentially for each key and value present in the
+// map at one instant. If f returns false, range stops the iteration.
+//
+// Unlike Range, f observes a consistent state of the whole map: the map
+// is ranged over a Fork of it. Writers are only blocked while the buckets
+// are shared with the fork, one pointer per bucket, and the first write
+// to a bucket afterwards copies it. f may use the map.
+func (m *CMap) RangeSnapshot(f func(key, value interface{}) bool) bool {
+	return m.Fork().Range(f)
+}
+
+// RangeChunked calls f sequentially for each key and value present in
+// the map, copying the entries out of a bucket up to chunk at a time. If f
+// returns false, RangeChunked stops the iteration. If chunk <= 0, a
+// default of 4096 is used.
+//
+// The buckets are read without any lock, so neither Range nor
+// RangeChunked block writers. RangeChunked bounds the memory of the
+// copies of RangeCtx and the iterators, which otherwise hold a whole
+// bucket: f is called once chunk entries are copied, before the rest of
+// the bucket is read. Like Range, RangeChunked does not correspond to any
+// consistent snapshot of the map's contents.
+func (m *CMap) RangeChunked(chunk int, f func(key, value interface{}) bool) bool {
+	if chunk <= 0 {
+		chunk = maxPooledEntries
+	}
+	ok, _ := m.rangeChunks(nil, chunk, false, f)
+	return ok
+}
+
+// rangeBuckets calls f sequentially for each key and value present in the
+// map, copying the entries out of one bucket at a time, so f never runs
+// while a bucket is read, unless it holds more than 4096 entries, copied
+// 4096 at a time.
+func (m *CMap) rangeBuckets(f func(key, value interface{}) bool) bool {
+	ok, _ := m.rangeBucketsCtx(nil, f)
+	return ok
+}
+
+// rangeBucketsCtx is rangeBuckets stopping with ctx.Err() once ctx is
+// done, checked between buckets. ctx may be nil.
+func (m *CMap) rangeBucketsCtx(ctx context.Context, f func(key, value interface{}) bool) (bool, error) {
+	return m.rangeChunks(ctx, maxPooledEntries, false, f)
+}
+
+// rangeChunks is rangeBucketsCtx copying up to chunk entries at a time.
+// If raw, f is called with the values as stored, to read their ttl.
+func (m *CMap) rangeChunks(ctx context.Context, chunk int, raw bool, f func(key, value interface{}) bool) (bool, error) {
+	n := m.getNode()
+	buf := entryPool.Get().(*[]Entry)
+	entries := (*buf)[:0]
+	defer func() {
+		putEntries(buf, entries)
+	}()
+	stopped := false
+	flush := func() bool {
+		for _, e := range entries {
+			if !f(e.Key, e.Value) {
+				stopped = true
+				return false
+			}
+		}
+		entries = entries[:0]
+		return true
+	}
+	for i := uintptr(0); i <= n.mask; i++ {
+		if ctx != nil && ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		add := func(key, value interface{}) bool {
+			entries = append(entries, Entry{key, value})
+			return len(entries) < chunk || flush()
+		}
+		if raw {
+			now := nanotime()
+			n.loadBucket(i).rangeHash(func(key, value interface{}, _ uintptr) bool {
+				return isExpired(value, now) || add(key, value)
+			})
+		} else {
+			n.loadBucket(i).rangeLive(add)
+		}
+		if stopped || !flush() {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// RangeKeys calls f sequentially for each key present in the map. If f
+// returns false, RangeKeys stops the iteration.
+//
+// Like the Keys iterator, RangeKeys copies the keys out of one bucket at
+// a time, so f never runs while a bucket is read, but it leaves the
+// values in the bucket: a scan of the keys of a large map copies half
+// the data Range over the entries would. Like Range, RangeKeys does not
+// correspond to any consistent snapshot of the map's contents.
+func (m *CMap) RangeKeys(f func(key interface{}) bool) bool {
+	n := m.getNode()
+	var keys []interface{}
+	for i := uintptr(0); i <= n.mask; i++ {
+		keys = n.loadBucket(i).appendKeys(keys[:0])
+		for _, k := range keys {
+			if !f(k) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// entryPool holds the buffers rangeBucketsCtx copies the entries of a
+// bucket to, so that periodic scans of a map don't allocate them anew.
+var entryPool = sync.Pool{
+	New: func() interface{} { return new([]Entry) },
+}
+
+// maxPooledEntries is the capacity over which a buffer is left to the
+// GC, so that a scan of a few huge buckets doesn't keep their size.
+const maxPooledEntries = 1 << 12
+
+// putEntries returns entries, the last contents of buf, to entryPool.
+func putEntries(buf *[]Entry, entries []Entry) {
+	if cap(entries) > maxPooledEntries {
+		return
+	}
+	// drop the references to the keys and values of every bucket copied
+	entries = entries[:cap(entries)]
+	for i := range entries {
+		entries[i] = Entry{}
+	}
+	*buf = entries[:0]
+	entryPool.Put(buf)
+}
+
+// lockAll locks every bucket of the current node, after finishing any
+// evacuation in progress.
+func (m *CMap) lockAll() *node {
+	for {
+		n := m.getNode()
+		n.evacuateAll()
+		for i := uintptr(0); i <= n.mask; i++ {
+			n.lockBucket(i)
+		}
+		if m.getNode() == n {
+			return n
+		}
+		// resized before we got the locks
+		n.unlockAll()
+	}
+}
+
+func (n *node) unlockAll() {
+	for i := uintptr(0); i <= n.mask; i++ {
+		n.getBucket(i).mu.Unlock()
+	}
+}
+
+// lockBucket locks the bucket i of n and returns it. It is still current
+// once locked, since the buckets of a node are only replaced with their
+// lock held, see Compact and unshare.
+func (n *node) lockBucket(i uintptr) *bucket {
+	for {
+		b := n.getBucket(i)
+		b.mu.Lock()
+		if n.getBucket(i) == b {
+			return b
+		}
+		b.mu.Unlock()
+	}
+}
+
+// RangeParallel calls f for each key and value present in the map, from
+// workers goroutines taking one bucket at a time, and returns when all
+// of them are done. If workers <= 0, GOMAXPROCS is used.
+//
+// f must be safe for concurrent use. If f panics, the workers stop and
+// the panic is raised again in the caller. Like Range, RangeParallel does
+// not correspond to any consistent snapshot of the map's contents.
+func (m *CMap) RangeParallel(workers int, f func(key, value interface{})) {
+	n := m.getNode()
+	n.parallel(workers, func(i uintptr) {
+		n.loadBucket(i).rangeLive(func(key, value interface{}) bool {
+			f(key, value)
+			return true
+		})
+	})
+}
+
+// parallel calls f with the index of every bucket of n, from workers
+// goroutines taking one bucket at a time, and returns when all of them
+// are done. If workers <= 0, GOMAXPROCS is used.
+//
+// If f panics, the workers stop taking buckets, and the first panic is
+// raised again by parallel once they are done.
+func (n *node) parallel(workers int, f func(i uintptr)) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > int(n.mask+1) {
+		workers = int(n.mask + 1)
+	}
+
+	var next uintptr
+	var wg sync.WaitGroup
+	var once sync.Once
+	var panicked interface{} // the first panic of f
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					once.Do(func() { panicked = r })
+					// stop the other workers
+					atomic.StoreUintptr(&next, n.mask+1)
+				}
+			}()
+			for i := atomic.AddUintptr(&next, 1) - 1; i <= n.mask; i = atomic.AddUintptr(&next, 1) - 1 {
+				f(i)
+			}
+		}()
+	}
+	wg.Wait()
+	if panicked != nil {
+		panic(panicked)
+	}
+}
+
+// rangeLive calls f sequentially for each key and value present in b,
+// skipping the expired ones.
+func (b *bucket) rangeLive(f func(key, value interface{}) bool) bool {
+	return b.rangeHash(func(key, value interface{}, _ uintptr) bool {
+		if value, ok := unwrap(value); ok {
+			return f(key, value)
+		}
+		return true
+	})
+}
+
+// appendKeys appends the live keys of b to keys.
+func (b *bucket) appendKeys(keys []interface{}) []interface{} {
+	b.rangeLive(func(key, _ interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+// appendTo appends the live entries of b to entries.
+func (b *bucket) appendTo(entries []Entry) []Entry {
+	b.rangeLive(func(key, value interface{}) bool {
+		entries = append(entries, Entry{key, value})
+		return true
+	})
+	return entries
+}
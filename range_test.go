@@ -0,0 +1,207 @@
+package cmap_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/min1324/cmap"
+)
+
+func TestRangeSnapshot(t *testing.T) {
+	const pairs = 1 << 10
+	var m cmap.CMap
+	for i := 0; i < pairs; i++ {
+		m.Store(i, 0)
+		m.Store(-i-1, 0)
+	}
+
+	// the writer keeps the values of i and -i-1 equal between two stores,
+	// so a snapshot must never see them differ by more than one.
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for v := 1; ; v++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			for i := 0; i < pairs; i++ {
+				m.Store(i, v)
+				m.Store(-i-1, v)
+			}
+		}
+	}()
+	defer func() {
+		close(done)
+		wg.Wait()
+	}()
+
+	for n := 0; n < 10; n++ {
+		seen := make(map[int]int, 2*pairs)
+		m.RangeSnapshot(func(key, value interface{}) bool {
+			seen[key.(int)] = value.(int)
+			return true
+		})
+		if len(seen) != 2*pairs {
+			t.Fatalf("RangeSnapshot saw %d entries, want %d", len(seen), 2*pairs)
+		}
+		min, max := seen[0], seen[0]
+		for _, v := range seen {
+			if v < min {
+				min = v
+			}
+			if v > max {
+				max = v
+			}
+		}
+		if max-min > 1 {
+			t.Fatalf("RangeSnapshot saw values from %d to %d", min, max)
+		}
+	}
+}
+
+func TestRangeSnapshotWrites(t *testing.T) {
+	var m cmap.CMap
+	for i := 0; i < 100; i++ {
+		m.Store(i, i)
+	}
+	// f writes to the map, the snapshot is unchanged
+	n := 0
+	m.RangeSnapshot(func(key, value interface{}) bool {
+		if value != key {
+			t.Fatalf("RangeSnapshot saw %v = %v", key, value)
+		}
+		m.Store(key, -1)
+		m.Store(key.(int)+100, 0)
+		n++
+		return true
+	})
+	if n != 100 {
+		t.Fatalf("RangeSnapshot saw %d entries, want 100", n)
+	}
+	if v, _ := m.Load(0); v != -1 || m.Len() != 200 {
+		t.Fatalf("Load(0) = %v, Len() = %d after RangeSnapshot; want -1, 200", v, m.Len())
+	}
+}
+
+func TestRangeParallel(t *testing.T) {
+	var m cmap.CMap
+	for i := 0; i < 200; i++ {
+		m.Store(i, i)
+	}
+	for _, workers := range []int{0, 1, 3, 1 << 10} {
+		var mu sync.Mutex
+		seen := make(map[interface{}]bool)
+		m.RangeParallel(workers, func(key, value interface{}) {
+			mu.Lock()
+			if seen[key] {
+				t.Errorf("RangeParallel(%d) visited %v twice", workers, key)
+			}
+			seen[key] = true
+			mu.Unlock()
+		})
+		if len(seen) != 200 {
+			t.Fatalf("RangeParallel(%d) visited %d keys, want 200", workers, len(seen))
+		}
+	}
+}
+
+func TestRangeKeys(t *testing.T) {
+	var m cmap.CMap
+	for i := 0; i < 200; i++ {
+		m.Store(i, i)
+	}
+	m.StoreWithTTL("expired", 0, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	seen := make(map[interface{}]bool)
+	if !m.RangeKeys(func(key interface{}) bool {
+		if seen[key] {
+			t.Fatalf("RangeKeys visited %v twice", key)
+		}
+		seen[key] = true
+		m.Delete(key) // f may use the map
+		return true
+	}) {
+		t.Fatalf("RangeKeys() = false, want true")
+	}
+	if len(seen) != 200 || seen["expired"] {
+		t.Fatalf("RangeKeys visited %d keys, want 200 without the expired one", len(seen))
+	}
+	m.DeleteExpired()
+	if m.Len() != 0 {
+		t.Fatalf("Len() = %d after deleting every key", m.Len())
+	}
+
+	m.Store(1, 1)
+	m.Store(2, 2)
+	calls := 0
+	if m.RangeKeys(func(interface{}) bool { calls++; return false }) || calls != 1 {
+		t.Fatalf("RangeKeys did not stop, %d calls", calls)
+	}
+}
+
+func TestRangeCtxAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector makes sync.Pool drop buffers")
+	}
+	var m cmap.CMap
+	for i := 0; i < 1000; i++ {
+		m.Store(i, i)
+	}
+	ctx := context.Background()
+	f := func(key, value interface{}) bool { return true }
+	m.RangeCtx(ctx, f)
+	allocs := testing.AllocsPerRun(100, func() {
+		m.RangeCtx(ctx, f)
+	})
+	if allocs != 0 {
+		t.Fatalf("RangeCtx allocates %v times", allocs)
+	}
+}
+
+func TestRangeChunked(t *testing.T) {
+	m := cmap.New(cmap.WithMaxShardBits(4))
+	const n = 1 << 12
+	for i := 0; i < n; i++ {
+		m.Store(i, i)
+	}
+	for _, chunk := range []int{0, 1, 7, 100, n} {
+		seen := make(map[interface{}]bool)
+		if !m.RangeChunked(chunk, func(key, value interface{}) bool {
+			if seen[key] || key != value {
+				t.Fatalf("RangeChunked(%d) visited %v = %v twice", chunk, key, value)
+			}
+			seen[key] = true
+			return true
+		}) {
+			t.Fatalf("RangeChunked(%d) = false, want true", chunk)
+		}
+		if len(seen) != n {
+			t.Fatalf("RangeChunked(%d) visited %d keys, want %d", chunk, len(seen), n)
+		}
+
+		calls := 0
+		if m.RangeChunked(chunk, func(key, value interface{}) bool { calls++; return calls < 3 }) || calls != 3 {
+			t.Fatalf("RangeChunked(%d) did not stop, %d calls", chunk, calls)
+		}
+	}
+
+	// f may write the bucket being copied
+	m.RangeChunked(10, func(key, value interface{}) bool {
+		m.Store(key, -1)
+		m.Delete(key.(int) + 1)
+		return true
+	})
+	m.Range(func(key, value interface{}) bool {
+		if value != -1 {
+			t.Fatalf("%v = %v after RangeChunked, want -1", key, value)
+		}
+		return true
+	})
+}
@@ -0,0 +1,43 @@
+package cmap
+
+// ReadOnly is the read side of a map, to hand a map to code which must
+// not change it. Both the view returned by CMap.ReadOnly and ReadOnlyMap
+// implement it.
+type ReadOnly interface {
+	// Load returns the value stored in the map for a key, or nil if no
+	// value is present.
+	// The ok result indicates whether value was found in the map.
+	Load(key interface{}) (value interface{}, ok bool)
+	// Len returns the number of elements within the map.
+	Len() int
+	// Range calls f sequentially for each key and value present in the
+	// map. If f returns false, range stops the iteration.
+	Range(f func(key, value interface{}) bool) bool
+}
+
+var (
+	_ ReadOnly = readOnlyView{}
+	_ ReadOnly = ReadOnlyMap{}
+)
+
+// ReadOnly returns a read-only view of the map, which sees its later
+// writes, unlike Freeze. The view can't be converted back to the CMap.
+func (m *CMap) ReadOnly() ReadOnly {
+	return readOnlyView{m}
+}
+
+type readOnlyView struct {
+	m *CMap
+}
+
+func (r readOnlyView) Load(key interface{}) (value interface{}, ok bool) {
+	return r.m.Load(key)
+}
+
+func (r readOnlyView) Len() int {
+	return r.m.Len()
+}
+
+func (r readOnlyView) Range(f func(key, value interface{}) bool) bool {
+	return r.m.Range(f)
+}
@@ -0,0 +1,34 @@
+package cmap_test
+
+import (
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestReadOnly(t *testing.T) {
+	var m cmap.CMap
+	m.Store(1, "a")
+	r := m.ReadOnly()
+	if _, ok := r.(interface{ Store(key, value interface{}) }); ok {
+		t.Fatalf("ReadOnly view has a Store method")
+	}
+	m.Store(2, "b")
+	if v, ok := r.Load(2); !ok || v != "b" || r.Len() != 2 {
+		t.Fatalf("view Load(2) = %v, %v, Len() = %d, want the writes of the map", v, ok, r.Len())
+	}
+	n := 0
+	r.Range(func(_, _ interface{}) bool {
+		n++
+		return true
+	})
+	if n != 2 {
+		t.Fatalf("view ranged over %d entries, want 2", n)
+	}
+
+	var frozen cmap.ReadOnly = m.Freeze()
+	m.Store(3, "c")
+	if frozen.Len() != 2 {
+		t.Fatalf("frozen Len() = %d, want 2", frozen.Len())
+	}
+}
@@ -0,0 +1,34 @@
+package cmap
+
+// Rename moves the value of oldKey to newKey atomically, and reports
+// whether it did: it fails if oldKey is absent or newKey is present.
+// The ttl of the value is not kept.
+func (m *CMap) Rename(oldKey, newKey interface{}) (ok bool) {
+	return m.rename(oldKey, newKey, false)
+}
+
+// RenameForce is like Rename, but replaces the value of newKey if present.
+func (m *CMap) RenameForce(oldKey, newKey interface{}) (ok bool) {
+	return m.rename(oldKey, newKey, true)
+}
+
+func (m *CMap) rename(oldKey, newKey interface{}, force bool) (ok bool) {
+	if oldKey == newKey {
+		_, ok = m.Load(oldKey)
+		return ok
+	}
+	m.DoAtomic([]interface{}{oldKey, newKey}, func(tx TxView) error {
+		v, found := tx.Get(oldKey)
+		if !found {
+			return nil
+		}
+		if _, exists := tx.Get(newKey); exists && !force {
+			return nil
+		}
+		tx.Delete(oldKey)
+		tx.Set(newKey, v)
+		ok = true
+		return nil
+	})
+	return ok
+}
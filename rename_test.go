@@ -0,0 +1,35 @@
+package cmap_test
+
+import (
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestRename(t *testing.T) {
+	var m cmap.CMap
+	m.Store("a", 1)
+	m.Store("b", 2)
+
+	if m.Rename("a", "b") {
+		t.Fatalf("Rename replaced a present key")
+	}
+	if m.Rename("x", "y") {
+		t.Fatalf("Rename of a missing key")
+	}
+	if !m.Rename("a", "c") {
+		t.Fatalf("Rename(a, c) failed")
+	}
+	if _, ok := m.Load("a"); ok {
+		t.Fatalf("Rename kept the old key")
+	}
+	if v, _ := m.Load("c"); v != 1 {
+		t.Fatalf("Load(c) = %v, want 1", v)
+	}
+	if !m.RenameForce("c", "b") {
+		t.Fatalf("RenameForce(c, b) failed")
+	}
+	if v, _ := m.Load("b"); v != 1 || m.Len() != 1 {
+		t.Fatalf("Load(b) = %v, Len() = %d", v, m.Len())
+	}
+}
@@ -0,0 +1,158 @@
+package cmap
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrChangesDropped is returned by Replicate once the source dropped
+// changes its replica had not received, see ChangesDropOldest.
+var ErrChangesDropped = errors.New("cmap: changes dropped")
+
+// replicaBuffer is the default buffer of the Changes of Replicate.
+const replicaBuffer = 1024
+
+// ReplicateOption configures Replicate.
+type ReplicateOption func(r *replicator)
+
+// WithReplicaWorkers sets the number of goroutines of the initial copy of
+// Replicate, GOMAXPROCS by default.
+func WithReplicaWorkers(n int) ReplicateOption {
+	return func(r *replicator) {
+		r.workers = n
+	}
+}
+
+// WithReplicaBuffer sets the buffer of the Changes tailed by Replicate,
+// 1024 by default.
+func WithReplicaBuffer(n int) ReplicateOption {
+	return func(r *replicator) {
+		r.buffer = n
+	}
+}
+
+// WithReplicaTransform makes Replicate store into its replica the values
+// returned by f for the values of the source, to try out a new schema of
+// the values on a shadow map. f must be safe for concurrent use.
+func WithReplicaTransform(f func(key, value interface{}) interface{}) ReplicateOption {
+	return func(r *replicator) {
+		r.transform = f
+	}
+}
+
+// WithReplicaSynced sets a function called by Replicate once its replica
+// holds the initial copy and the changes made meanwhile, from then on
+// trailing the source by the changes in flight, so that it can take over.
+func WithReplicaSynced(f func()) ReplicateOption {
+	return func(r *replicator) {
+		r.synced = f
+	}
+}
+
+// Replicate copies the entries of src into dst, from goroutines taking one
+// bucket at a time, then keeps dst converged with src by applying the
+// Changes of src, until ctx is done or src is closed. It returns
+// ctx.Err(), ErrClosed once src is closed, or ErrChangesDropped if src
+// dropped changes, see WithChangePolicy.
+//
+// The changes made during the copy are kept aside until it is done, so
+// that the writers of src do not wait for it. Since Changes delivers the
+// changes of a key in the order they were made, dst converges to src once
+// its writers stop, even when they raced on the same keys. The entries of
+// dst have no ttl: they are deleted as the keys of src expire. dst should
+// not be written by others while Replicate runs.
+func Replicate(ctx context.Context, src, dst *CMap, opts ...ReplicateOption) error {
+	r := replicator{buffer: replicaBuffer}
+	for _, opt := range opts {
+		opt(&r)
+	}
+	// the changes are tailed before the copy, so that the changes of the
+	// keys copied come after their value
+	ch, cancel := src.Changes(r.buffer)
+	defer cancel()
+
+	ctx, stop := context.WithCancel(ctx)
+	copied := make(chan struct{})
+	defer func() {
+		stop()
+		<-copied
+	}()
+	go func() {
+		defer close(copied)
+		r.copy(ctx, src, dst)
+	}()
+
+	var pending []Change
+	copying := copied
+	for {
+		select {
+		case <-copying:
+			copying = nil
+			for _, c := range pending {
+				if err := r.apply(dst, c); err != nil {
+					return err
+				}
+			}
+			pending = nil
+			if r.synced != nil {
+				r.synced()
+			}
+		case c, ok := <-ch:
+			if !ok {
+				return ErrClosed
+			}
+			if copying != nil {
+				pending = append(pending, c)
+			} else if err := r.apply(dst, c); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// replicator is the state of Replicate.
+type replicator struct {
+	workers   int
+	buffer    int
+	transform func(key, value interface{}) interface{}
+	synced    func()
+	seq       uint64 // Seq of the last change applied
+}
+
+// copy stores the entries of src into dst, until ctx is done.
+func (r *replicator) copy(ctx context.Context, src, dst *CMap) {
+	n := src.getNode()
+	n.parallel(r.workers, func(i uintptr) {
+		if ctx.Err() != nil {
+			return
+		}
+		n.loadBucket(i).rangeLive(func(key, value interface{}) bool {
+			dst.Store(key, r.value(key, value))
+			return true
+		})
+	})
+}
+
+// apply applies c to dst.
+func (r *replicator) apply(dst *CMap, c Change) error {
+	if c.Seq != r.seq+1 {
+		return ErrChangesDropped
+	}
+	r.seq = c.Seq
+	switch c.Op {
+	case ChangeInsert, ChangeUpdate:
+		dst.Store(c.Key, r.value(c.Key, c.New))
+	default:
+		dst.Delete(c.Key)
+	}
+	return nil
+}
+
+func (r *replicator) value(key, value interface{}) interface{} {
+	if r.transform != nil {
+		return r.transform(key, value)
+	}
+	return value
+}
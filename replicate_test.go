@@ -0,0 +1,175 @@
+package cmap_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/min1324/cmap"
+)
+
+func TestReplicate(t *testing.T) {
+	src := cmap.New()
+	defer src.Close()
+	for i := 0; i < 1000; i++ {
+		src.Store(i, i)
+	}
+	dst := cmap.New()
+	defer dst.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	synced := make(chan struct{})
+	errc := make(chan error, 1)
+	go func() {
+		errc <- cmap.Replicate(ctx, src, dst,
+			cmap.WithReplicaWorkers(4),
+			cmap.WithReplicaTransform(func(key, value interface{}) interface{} {
+				return value.(int) * 2
+			}),
+			cmap.WithReplicaSynced(func() { close(synced) }))
+	}()
+	for i := 0; i < 2000; i++ {
+		if i%3 == 0 {
+			src.Delete(i / 2)
+		} else {
+			src.Store(i, i)
+		}
+	}
+	<-synced
+
+	want := cmap.New()
+	src.Range(func(key, value interface{}) bool {
+		want.Store(key, value.(int)*2)
+		return true
+	})
+	for deadline := time.Now().Add(5 * time.Second); !dst.Equal(want, nil); {
+		if time.Now().After(deadline) {
+			t.Fatalf("replica has %d keys, want %d", dst.Len(), want.Len())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Fatalf("Replicate() = %v, want context.Canceled", err)
+	}
+}
+
+func TestReplicateRacing(t *testing.T) {
+	src := cmap.New()
+	defer src.Close()
+	for i := 0; i < 100; i++ {
+		src.Store(i, i)
+	}
+	dst := cmap.New()
+	defer dst.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- cmap.Replicate(ctx, src, dst, cmap.WithReplicaWorkers(4))
+	}()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				key := i % 3
+				switch i % 4 {
+				case 0:
+					src.Delete(key)
+				case 1:
+					src.LoadOrStore(key, g)
+				case 2:
+					src.Update(key, func(value interface{}, ok bool) (interface{}, bool) {
+						return g*10000 + i, false
+					})
+				default:
+					src.Store(key, g)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	for deadline := time.Now().Add(5 * time.Second); !src.Equal(dst, nil); {
+		if time.Now().After(deadline) {
+			t.Fatalf("replica has %d keys, want %d", dst.Len(), src.Len())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Fatalf("Replicate() = %v, want context.Canceled", err)
+	}
+}
+
+func TestReplicateOtherCancelled(t *testing.T) {
+	src := cmap.New()
+	defer src.Close()
+	dst := cmap.New()
+	defer dst.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	synced := make(chan struct{})
+	errc := make(chan error, 1)
+	go func() {
+		errc <- cmap.Replicate(ctx, src, dst, cmap.WithReplicaSynced(func() { close(synced) }))
+	}()
+	<-synced
+	// another consumer of the changes of src comes and goes
+	ch, stop := src.Changes(1)
+	go func() {
+		for range ch {
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		src.Store(i, i)
+	}
+	stop()
+	for i := 100; i < 200; i++ {
+		src.Store(i, i)
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); !src.Equal(dst, nil); {
+		if time.Now().After(deadline) {
+			t.Fatalf("replica has %d keys, want %d", dst.Len(), src.Len())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Fatalf("Replicate() = %v, want context.Canceled", err)
+	}
+}
+
+func TestReplicateDropped(t *testing.T) {
+	src := cmap.New(cmap.WithChangePolicy(cmap.ChangesDropOldest))
+	defer src.Close()
+	dst := cmap.New()
+	defer dst.Close()
+
+	err := cmap.Replicate(context.Background(), src, dst,
+		cmap.WithReplicaBuffer(1),
+		cmap.WithReplicaSynced(func() {
+			for i := 0; i < 10; i++ {
+				src.Store(i, i) // dropped but the last, since Replicate waits
+			}
+		}))
+	if !errors.Is(err, cmap.ErrChangesDropped) {
+		t.Fatalf("Replicate() = %v, want ErrChangesDropped", err)
+	}
+}
+
+func TestReplicateClosed(t *testing.T) {
+	src := cmap.New()
+	dst := cmap.New()
+	defer dst.Close()
+	err := cmap.Replicate(context.Background(), src, dst,
+		cmap.WithReplicaSynced(src.Close))
+	if err != cmap.ErrClosed {
+		t.Fatalf("Replicate() = %v, want ErrClosed", err)
+	}
+}
@@ -0,0 +1,348 @@
+package cmap
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"unsafe"
+)
+
+// A resize replaces the node of a CMap by a new one with 1<<B buckets,
+// whose buckets are filled from the old node incrementally: every write
+// evacuates one more group of buckets, and an operation which needs a
+// bucket not evacuated yet evacuates it first. No goroutine is started.
+//
+// The buckets of both nodes are split into groups such that the keys of
+// the old buckets of a group all go to the new buckets of the same group:
+// with min(old, new) groups, group g holds the buckets i with
+// i&(groups-1) == g in either node. A group is evacuated at once, with
+// its old buckets locked, then its new buckets are published.
+
+// state of an evacuation group
+const (
+	evacPending uint32 = iota
+	evacRunning
+	evacDone
+)
+
+// ResizeInProgress reports whether the buckets are being evacuated by a
+// resize.
+func (m *CMap) ResizeInProgress() bool {
+	return m.getNode().oldNode() != nil
+}
+
+// ForceResize resizes the map to 1<<B buckets, after finishing any resize
+// in progress. B is limited to the range [4, 31], or to the max set by
+// WithMaxShardBits.
+//
+// ForceResize returns once the resize is started: the buckets are then
+// evacuated by the following writes, call WaitResize to do it at once,
+// unless the map was created WithSynchronousResize.
+func (m *CMap) ForceResize(B uint8) {
+	if B < mInitBit {
+		B = mInitBit
+	}
+	if max := m.maxBit(); B > max {
+		B = max
+	}
+	for m.checkOpen() == nil {
+		n := m.getNode()
+		n.evacuateAll()
+		if n.B == B || growWork(m, n, B, 0) {
+			return
+		}
+	}
+}
+
+// WaitResize evacuates the buckets of the resize in progress, if any, and
+// returns once it is done. If ctx is done first, WaitResize returns
+// ctx.Err() and the remaining buckets are left to the following writes.
+func (m *CMap) WaitResize(ctx context.Context) error {
+	n := m.getNode()
+	for g := range n.groups {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n.evacuate(uintptr(g))
+	}
+	return nil
+}
+
+// Reserve grows the map to hold n elements without any further resize,
+// with its buckets sized for them, and returns once the buckets are
+// evacuated. Reserve never shrinks the map.
+func (m *CMap) Reserve(n int) {
+	B := m.sizeBit(n)
+	for m.checkOpen() == nil {
+		nd := m.getNode()
+		nd.evacuateAll()
+		if nd.B >= B {
+			return
+		}
+		if growWork(m, nd, B, n>>B) {
+			m.getNode().evacuateAll()
+			return
+		}
+	}
+}
+
+// ShrinkTo shrinks the map to 1<<B buckets, after finishing any resize in
+// progress, and returns once the buckets are evacuated. B is limited to
+// the range [4, 31]. ShrinkTo never grows the map.
+//
+// With WithShrinkDisabled, ShrinkTo lets the map shrink at a chosen time,
+// like a maintenance window, instead of during the deletes.
+func (m *CMap) ShrinkTo(B uint8) {
+	if B < mInitBit {
+		B = mInitBit
+	}
+	for m.checkOpen() == nil {
+		n := m.getNode()
+		n.evacuateAll()
+		if n.B <= B {
+			return
+		}
+		if growWork(m, n, B, 0) {
+			m.getNode().evacuateAll()
+			return
+		}
+	}
+}
+
+// sizeBit returns the B of a map holding n elements without any resize.
+func (m *CMap) sizeBit(n int) uint8 {
+	B := uint8(mInitBit)
+	// keep the buckets at a quarter of their load factor on average
+	for B < m.maxBit() && n>>B > 1<<minBit(B, 15)>>1 {
+		B++
+	}
+	return B
+}
+
+func minBit(a, b uint8) uint8 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// The map grows once a bucket is over the grow load factor of its node,
+// and shrinks once the whole node is under the shrink load factor of the
+// node with half its buckets, see WithLoadFactor. At a load factor of 1, a
+// bucket holds 2<<min(B, 15) elements: with B <= 15, halving the buckets
+// divides their load by 4.
+const (
+	defaultGrow   = 1
+	defaultShrink = 0.25
+
+	// a delete leaving its bucket under the shrink load factor checks
+	// the load of the node every shrinkEvery writes of the bucket, first
+	// on shrinkSample buckets only.
+	shrinkEvery  = 32
+	shrinkSample = 16
+)
+
+// loadFactor returns the load factors of the map, see WithLoadFactor.
+// The shrink load factor is zero if the map never shrinks by itself.
+func (m *CMap) loadFactor() (grow, shrink float64) {
+	grow, shrink = m.grow, m.shrink
+	if grow == 0 {
+		grow, shrink = defaultGrow, defaultShrink
+	}
+	if m.noShrink {
+		shrink = 0
+	}
+	return grow, shrink
+}
+
+// bucketCap returns the number of elements a bucket of a node of 1<<B
+// buckets holds at a load factor of 1.
+func bucketCap(B uint8) float64 {
+	return float64(uint32(2) << minBit(B, 15))
+}
+
+// shrinkLimit returns the number of elements under which a node of 1<<B
+// buckets shrinks.
+func shrinkLimit(shrink float64, B uint8) float64 {
+	return shrink * float64(bucketShift(B-1)) * bucketCap(B-1)
+}
+
+// removed is called after a key was deleted from bucket b of node n.
+func (m *CMap) removed(n *node, b *bucket) {
+	n.debugCheck(b)
+	_, shrink := m.loadFactor()
+	if n.B <= mInitBit || shrink == 0 ||
+		float64(atomic.LoadInt64(&b.count))*float64(bucketShift(n.B)) >= shrinkLimit(shrink, n.B) ||
+		atomic.LoadUint64(&b.writes)%shrinkEvery != 0 {
+		return
+	}
+	m.checkShrink(n)
+}
+
+// checkShrink starts shrinking the map from node n if n is under the
+// shrink load factor.
+func (m *CMap) checkShrink(n *node) {
+	_, shrink := m.loadFactor()
+	if n.B <= mInitBit || shrink == 0 || n.oldNode() != nil || atomic.LoadUint32(&n.resize) != 0 {
+		return
+	}
+	limit := shrinkLimit(shrink, n.B)
+	if n.mask >= shrinkSample {
+		// counting n takes a pass over its buckets, rule out the nodes
+		// far from the limit on a sample first
+		var sum int64
+		step := (n.mask + 1) / shrinkSample
+		for i := uintptr(0); i <= n.mask; i += step {
+			sum += atomic.LoadInt64(&n.getBucket(i).count)
+		}
+		if float64(sum)*float64(step) >= 2*limit {
+			return
+		}
+	}
+	if float64(n.count()) < limit {
+		growWork(m, n, n.B-1, 0)
+	}
+}
+
+// growWork starts resizing the map from node n to 1<<B buckets, unless
+// another resize is in progress or started from n already. The new
+// buckets are sized for hint items.
+// It reports whether the resize was started.
+func growWork(m *CMap, n *node, B uint8, hint int) bool {
+	if n.oldNode() != nil || atomic.LoadUint32(&m.closed) != 0 ||
+		!atomic.CompareAndSwapUint32(&n.resize, 0, 1) {
+		return false
+	}
+	groups := bucketShift(B)
+	if n.B < B {
+		groups = bucketShift(n.B)
+	}
+	nn := &node{
+		mask:    bucketMask(B),
+		B:       B,
+		data:    make([]unsafe.Pointer, bucketShift(B)),
+		old:     unsafe.Pointer(n),
+		groups:  make([]uint32, groups),
+		hint:    hint,
+		swiss:   n.swiss,
+		started: nanotime(),
+		done:    m.resized(),
+	}
+	// cas node
+	ok := atomic.CompareAndSwapPointer(&m.node, unsafe.Pointer(n), unsafe.Pointer(nn))
+	if !ok {
+		panic("BUG: failed swapping head")
+	}
+	if B > n.B {
+		atomic.AddUint64(&m.grows, 1)
+	} else {
+		atomic.AddUint64(&m.shrinks, 1)
+	}
+	m.resizing(n, nn)
+	if m.syncResize {
+		nn.evacuateAll()
+	}
+	return true
+}
+
+// oldNode returns the node being evacuated into n, or nil.
+func (n *node) oldNode() *node {
+	return (*node)(atomic.LoadPointer(&n.old))
+}
+
+// loadBucket returns the bucket i of n, evacuating it first if needed.
+func (n *node) loadBucket(i uintptr) *bucket {
+	b := n.getBucket(i)
+	if b == nil {
+		n.evacuate(i & uintptr(len(n.groups)-1))
+		b = n.getBucket(i)
+	}
+	return b
+}
+
+// readBucket returns the bucket holding the keys of hash, which is the
+// old one if it is not evacuated yet.
+func (n *node) readBucket(hash uintptr) *bucket {
+	if b := n.getBucket(hash); b != nil {
+		return b
+	}
+	if o := n.oldNode(); o != nil {
+		return o.getBucket(hash)
+	}
+	// evacuated in the meantime
+	return n.getBucket(hash)
+}
+
+// assist evacuates the next group of buckets, if n is being resized.
+func (n *node) assist() {
+	if n.oldNode() == nil {
+		return
+	}
+	g := atomic.AddUint32(&n.next, 1) - 1
+	if g < uint32(len(n.groups)) {
+		n.evacuate(uintptr(g))
+	}
+}
+
+// evacuateAll evacuates every bucket into n.
+func (n *node) evacuateAll() {
+	for g := range n.groups {
+		n.evacuate(uintptr(g))
+	}
+}
+
+// evacuate copies the old buckets of group g into new buckets, and
+// publishes them in n. It returns once the group is evacuated, by this
+// goroutine or another one.
+func (n *node) evacuate(g uintptr) {
+	state := &n.groups[g]
+	if !atomic.CompareAndSwapUint32(state, evacPending, evacRunning) {
+		for atomic.LoadUint32(state) != evacDone {
+			runtime.Gosched()
+		}
+		return
+	}
+	o := n.oldNode()
+	step := uintptr(len(n.groups))
+
+	// Writers check that their bucket is still current after locking
+	// it, so once the old buckets are locked they can't change anymore.
+	var olds []*bucket
+	for i := g; i <= o.mask; i += step {
+		b := o.getBucket(i)
+		b.mu.Lock()
+		olds = append(olds, b)
+	}
+	news := make([]*bucket, 0, (n.mask+1)/step)
+	for j := g; j <= n.mask; j += step {
+		nb := n.newBucket()
+		if n.hint > 0 {
+			nb.reserve(n.hint)
+		}
+		news = append(news, nb)
+	}
+	for _, ob := range olds {
+		ob.rangeHash(func(key, value interface{}, hash uintptr) bool {
+			nb := news[(hash&n.mask)/step]
+			nb.store(key, value, hash)
+			nb.count++
+			return true
+		})
+	}
+	for k, nb := range news {
+		atomic.StorePointer(&n.data[g+uintptr(k)*step], unsafe.Pointer(nb))
+	}
+	for _, ob := range olds {
+		debugFreeze(ob)
+		ob.mu.Unlock()
+	}
+
+	atomic.StoreUint32(state, evacDone)
+	if atomic.AddUint32(&n.evacuated, 1) == uint32(len(n.groups)) {
+		// drop the old node
+		atomic.StorePointer(&n.old, nil)
+		if n.done != nil {
+			n.done(n)
+		}
+	}
+}
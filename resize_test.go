@@ -0,0 +1,262 @@
+package cmap_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestIncrementalResize(t *testing.T) {
+	var m cmap.CMap
+	n := 0
+	for ; !m.ResizeInProgress(); n++ {
+		if n > 1<<20 {
+			t.Fatalf("no resize after %d stores", n)
+		}
+		m.Store(n, n)
+	}
+
+	// the buckets are not evacuated yet, loads must find every key
+	for i := 0; i < n; i++ {
+		if v, ok := m.Load(i); !ok || v != i {
+			t.Fatalf("Load(%d) = %v, %v during resize", i, v, ok)
+		}
+	}
+	if m.Len() != n {
+		t.Fatalf("Len() = %d during resize, want %d", m.Len(), n)
+	}
+
+	// every write evacuates some buckets
+	for m.ResizeInProgress() {
+		m.Delete(-1)
+	}
+	for i := 0; i < n; i++ {
+		if v, ok := m.Load(i); !ok || v != i {
+			t.Fatalf("Load(%d) = %v, %v after resize", i, v, ok)
+		}
+	}
+	seen := 0
+	m.Range(func(key, value interface{}) bool {
+		seen++
+		return true
+	})
+	if seen != n || m.Len() != n {
+		t.Fatalf("Range saw %d keys, Len() = %d, want %d", seen, m.Len(), n)
+	}
+}
+
+func TestForceResize(t *testing.T) {
+	var m cmap.CMap
+	for i := 0; i < 100; i++ {
+		m.Store(i, i)
+	}
+	for _, B := range []uint8{10, 12, 4, 0} {
+		m.ForceResize(B)
+		if B > 4 && !m.ResizeInProgress() {
+			t.Fatalf("ForceResize(%d) did not start a resize", B)
+		}
+		if err := m.WaitResize(context.Background()); err != nil {
+			t.Fatalf("WaitResize: %v", err)
+		}
+		if m.ResizeInProgress() {
+			t.Fatalf("resize in progress after WaitResize")
+		}
+		for i := 0; i < 100; i++ {
+			if v, ok := m.Load(i); !ok || v != i {
+				t.Fatalf("Load(%d) = %v, %v after ForceResize(%d)", i, v, ok, B)
+			}
+		}
+		if m.Len() != 100 {
+			t.Fatalf("Len() = %d after ForceResize(%d)", m.Len(), B)
+		}
+	}
+
+	m.ForceResize(8)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := m.WaitResize(ctx); err != context.Canceled {
+		t.Fatalf("WaitResize(canceled) = %v", err)
+	}
+}
+
+func TestSynchronousResize(t *testing.T) {
+	m := cmap.New(cmap.WithSynchronousResize())
+	var resizes []uint8
+	m.OnResize(func(oldB, newB uint8, count int64) {
+		resizes = append(resizes, newB)
+	})
+	for i := 0; i < 1<<12; i++ {
+		n := len(resizes)
+		m.Store(i, i)
+		if m.ResizeInProgress() {
+			t.Fatalf("resize in progress after Store(%d)", i)
+		}
+		if len(resizes) != n && m.Stats().B != resizes[len(resizes)-1] {
+			t.Fatalf("Store(%d) started a resize to B=%d, map has B=%d", i, resizes[len(resizes)-1], m.Stats().B)
+		}
+	}
+	if len(resizes) == 0 {
+		t.Fatalf("no resize")
+	}
+	grown := m.Stats().B
+	for i := 0; i < 1<<12; i++ {
+		m.Delete(i)
+		if m.ResizeInProgress() {
+			t.Fatalf("resize in progress after Delete(%d)", i)
+		}
+	}
+	if m.Stats().B >= grown {
+		t.Fatalf("map not shrunk, B=%d", m.Stats().B)
+	}
+	m.ForceResize(10)
+	if m.ResizeInProgress() || m.Stats().B != 10 {
+		t.Fatalf("ForceResize(10) left B=%d, in progress %v", m.Stats().B, m.ResizeInProgress())
+	}
+}
+
+func TestMaxShardBits(t *testing.T) {
+	m := cmap.New(cmap.WithMaxShardBits(4))
+	for i := 0; i < 1<<14; i++ {
+		m.Store(i, i)
+		if m.ResizeInProgress() {
+			t.Fatalf("resized past WithMaxShardBits at %d keys", i)
+		}
+	}
+	m.ForceResize(10)
+	if m.ResizeInProgress() {
+		t.Fatalf("ForceResize resized past WithMaxShardBits")
+	}
+	if m.Len() != 1<<14 {
+		t.Fatalf("Len() = %d, want %d", m.Len(), 1<<14)
+	}
+}
+
+func TestResizeLarge(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping large map in short mode")
+	}
+	const size = 1 << 21
+	var m cmap.CMap
+	for i := 0; i < size; i++ {
+		m.Store(i, i)
+	}
+	if m.Len() != size {
+		t.Fatalf("Len() = %d, want %d", m.Len(), size)
+	}
+	for i := 0; i < size; i += 997 {
+		if v, ok := m.Load(i); !ok || v != i {
+			t.Fatalf("Load(%d) = %v, %v", i, v, ok)
+		}
+	}
+}
+
+func TestReserve(t *testing.T) {
+	const size = 1 << 16
+	var m cmap.CMap
+	m.Reserve(size)
+	for i := 0; i < size; i++ {
+		m.Store(i, i)
+		if m.ResizeInProgress() {
+			t.Fatalf("resized at %d keys after Reserve(%d)", i, size)
+		}
+	}
+	if m.Len() != size {
+		t.Fatalf("Len() = %d, want %d", m.Len(), size)
+	}
+}
+
+func TestShrink(t *testing.T) {
+	var m cmap.CMap
+	for i := 0; i < 100000; i++ {
+		m.Store(i, i)
+	}
+	m.WaitResize(context.Background())
+	if B := m.Stats().B; B < 8 {
+		t.Fatalf("B = %d after 100000 stores", B)
+	}
+	for i := 0; i < 100000; i++ {
+		m.Delete(i)
+	}
+	m.WaitResize(context.Background())
+	if s := m.Stats(); s.B > 6 || s.Shrinks == 0 {
+		t.Fatalf("B = %d after %d shrinks, want the map shrunk once empty", s.B, s.Shrinks)
+	}
+}
+
+func TestLoadFactor(t *testing.T) {
+	fill := func(m *cmap.CMap) cmap.Stats {
+		for i := 0; i < 100000; i++ {
+			m.Store(i, i)
+		}
+		m.WaitResize(context.Background())
+		return m.Stats()
+	}
+	def := fill(new(cmap.CMap))
+	dense := fill(cmap.New(cmap.WithLoadFactor(8, 0)))
+	if dense.B >= def.B {
+		t.Fatalf("B = %d with a load factor of 8, %d by default", dense.B, def.B)
+	}
+
+	// with shrinking disabled, an emptied map keeps its buckets
+	m := cmap.New(cmap.WithLoadFactor(1, 0))
+	s := fill(m)
+	for i := 0; i < 100000; i++ {
+		m.Delete(i)
+	}
+	if after := m.Stats(); after.B != s.B || after.Shrinks != 0 {
+		t.Fatalf("B = %d after %d shrinks with a zero shrink load factor, want %d", after.B, after.Shrinks, s.B)
+	}
+}
+
+func TestResizeHysteresis(t *testing.T) {
+	var m cmap.CMap
+	n := 0
+	for ; m.Stats().Grows < 3; n++ {
+		m.Store(n, n)
+	}
+	m.WaitResize(context.Background())
+	before := m.Stats()
+	// churn around the size the map last grew at
+	for r := 0; r < 20; r++ {
+		for i := n; i < n+n/4; i++ {
+			m.Store(i, i)
+		}
+		for i := n; i < n+n/2; i++ {
+			m.Delete(i)
+		}
+	}
+	after := m.Stats()
+	if resizes := after.Grows + after.Shrinks - before.Grows - before.Shrinks; resizes > 1 {
+		t.Fatalf("%d resizes while churning around %d elements", resizes, n)
+	}
+}
+
+func TestShrinkTo(t *testing.T) {
+	m := cmap.New(cmap.WithShrinkDisabled())
+	for i := 0; i < 100000; i++ {
+		m.Store(i, i)
+	}
+	m.WaitResize(context.Background())
+	B := m.Stats().B
+	for i := 0; i < 99000; i++ {
+		m.Delete(i)
+	}
+	if s := m.Stats(); s.B != B || s.Shrinks != 0 {
+		t.Fatalf("B = %d after %d shrinks with shrinking disabled, want %d", s.B, s.Shrinks, B)
+	}
+
+	m.ShrinkTo(B + 1)
+	if s := m.Stats(); s.B != B {
+		t.Fatalf("ShrinkTo(%d) grew the map to B = %d", B+1, s.B)
+	}
+	m.ShrinkTo(6)
+	if s := m.Stats(); s.B != 6 || s.Resizing || s.Len != 1000 {
+		t.Fatalf("after ShrinkTo(6): B = %d, Resizing %v, Len %d", s.B, s.Resizing, s.Len)
+	}
+	for i := 99000; i < 100000; i++ {
+		if v, ok := m.Load(i); !ok || v != i {
+			t.Fatalf("Load(%d) = %v, %v after ShrinkTo", i, v, ok)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package cmap
+
+import (
+	"math/rand"
+	"sort"
+	"sync/atomic"
+)
+
+// Sample returns up to n random entries of the map, without duplicates.
+//
+// Each entry is drawn from a bucket picked with a probability proportional
+// to its number of elements, so the entries are about uniformly sampled,
+// while only the buckets picked are visited. If n is at least the number
+// of elements, Sample returns every entry.
+func (m *CMap) Sample(n int) []Entry {
+	if n <= 0 {
+		return nil
+	}
+	nd := m.getNode()
+	sums := make([]int64, nd.mask+1) // running sums of the bucket counts
+	var total int64
+	for i := range sums {
+		if c := atomic.LoadInt64(&nd.loadBucket(uintptr(i)).count); c > 0 {
+			total += c
+		}
+		sums[i] = total
+	}
+	if total == 0 {
+		return nil
+	}
+	entries := make([]Entry, 0, n)
+	if int64(n) >= total {
+		for i := uintptr(0); i <= nd.mask; i++ {
+			entries = nd.loadBucket(i).appendTo(entries)
+		}
+		return entries
+	}
+
+	picks := make(map[uintptr]int)
+	for j := 0; j < n; j++ {
+		r := rand.Int63n(total)
+		i := sort.Search(len(sums), func(i int) bool { return sums[i] > r })
+		picks[uintptr(i)]++
+	}
+	for i, k := range picks {
+		entries = nd.loadBucket(i).sample(k, entries)
+	}
+	return entries
+}
+
+// sample appends up to k random live entries of b to entries, picked by
+// reservoir sampling.
+func (b *bucket) sample(k int, entries []Entry) []Entry {
+	base, seen := len(entries), 0
+	b.rangeLive(func(key, value interface{}) bool {
+		if seen < k {
+			entries = append(entries, Entry{key, value})
+		} else if j := rand.Intn(seen + 1); j < k {
+			entries[base+j] = Entry{key, value}
+		}
+		seen++
+		return true
+	})
+	return entries
+}
@@ -0,0 +1,46 @@
+package cmap_test
+
+import (
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestSample(t *testing.T) {
+	var m cmap.CMap
+	if s := m.Sample(3); len(s) != 0 {
+		t.Fatalf("Sample of an empty map = %v", s)
+	}
+	const n = 1000
+	for i := 0; i < n; i++ {
+		m.Store(i, i*2)
+	}
+	if s := m.Sample(2 * n); len(s) != n {
+		t.Fatalf("Sample(%d) returned %d entries, want all %d", 2*n, len(s), n)
+	}
+
+	hits := make([]int, 10)
+	for round := 0; round < 500; round++ {
+		s := m.Sample(20)
+		if len(s) == 0 || len(s) > 20 {
+			t.Fatalf("Sample(20) returned %d entries", len(s))
+		}
+		seen := make(map[interface{}]bool)
+		for _, e := range s {
+			if seen[e.Key] {
+				t.Fatalf("Sample returned key %v twice", e.Key)
+			}
+			seen[e.Key] = true
+			if e.Value != e.Key.(int)*2 {
+				t.Fatalf("Sample returned %v: %v", e.Key, e.Value)
+			}
+			hits[e.Key.(int)*10/n]++
+		}
+	}
+	// each tenth of the keys gets about a tenth of the samples
+	for i, h := range hits {
+		if h < 500 || h > 1500 {
+			t.Fatalf("keys %d..%d sampled %d times of about 1000: %v", i*n/10, (i+1)*n/10, h, hits)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package cmap
+
+// Set is a concurrent set of comparable elements, kept in the buckets of
+// a CMap, so it shares its sharding and resizing.
+//
+// The zero Set is empty and ready for use. A Set must not be copied after
+// first use.
+type Set struct {
+	m CMap
+}
+
+// Add adds v to the set, and reports whether it was missing.
+func (s *Set) Add(v interface{}) bool {
+	_, loaded := s.m.LoadOrStore(v, struct{}{})
+	return !loaded
+}
+
+// Remove removes v from the set, and reports whether it was present.
+func (s *Set) Remove(v interface{}) bool {
+	_, loaded := s.m.LoadAndDelete(v)
+	return loaded
+}
+
+// Contains reports whether v is in the set.
+func (s *Set) Contains(v interface{}) bool {
+	_, ok := s.m.Load(v)
+	return ok
+}
+
+// Len returns the number of elements in the set.
+func (s *Set) Len() int {
+	return s.m.Len()
+}
+
+// Range calls f sequentially for each element present in the set.
+// If f returns false, range stops the iteration.
+func (s *Set) Range(f func(v interface{}) bool) bool {
+	return s.m.Range(func(key, _ interface{}) bool {
+		return f(key)
+	})
+}
+
+// Union returns a new set of the elements of s or o.
+//
+// Like Range, the set operations don't correspond to any consistent
+// snapshot of s and o.
+func (s *Set) Union(o *Set) *Set {
+	r := new(Set)
+	s.Range(func(v interface{}) bool {
+		r.Add(v)
+		return true
+	})
+	o.Range(func(v interface{}) bool {
+		r.Add(v)
+		return true
+	})
+	return r
+}
+
+// Intersect returns a new set of the elements of both s and o.
+func (s *Set) Intersect(o *Set) *Set {
+	r := new(Set)
+	a, b := s, o
+	if b.Len() < a.Len() {
+		a, b = b, a
+	}
+	a.Range(func(v interface{}) bool {
+		if b.Contains(v) {
+			r.Add(v)
+		}
+		return true
+	})
+	return r
+}
+
+// Difference returns a new set of the elements of s not in o.
+func (s *Set) Difference(o *Set) *Set {
+	r := new(Set)
+	s.Range(func(v interface{}) bool {
+		if !o.Contains(v) {
+			r.Add(v)
+		}
+		return true
+	})
+	return r
+}
@@ -0,0 +1,60 @@
+package cmap_test
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func newSet(vs ...int) *cmap.Set {
+	s := new(cmap.Set)
+	for _, v := range vs {
+		s.Add(v)
+	}
+	return s
+}
+
+func setElems(s *cmap.Set) []int {
+	var vs []int
+	s.Range(func(v interface{}) bool {
+		vs = append(vs, v.(int))
+		return true
+	})
+	sort.Ints(vs)
+	return vs
+}
+
+func TestSet(t *testing.T) {
+	s := newSet(1, 2, 3)
+	if s.Add(1) {
+		t.Fatalf("Add(1) reported a missing element")
+	}
+	if !s.Add(4) || !s.Contains(4) || s.Len() != 4 {
+		t.Fatalf("Add(4) failed")
+	}
+	if !s.Remove(4) || s.Remove(4) || s.Contains(4) {
+		t.Fatalf("Remove(4) failed")
+	}
+
+	o := newSet(2, 3, 5)
+	for _, tc := range []struct {
+		name string
+		s    *cmap.Set
+		want []int
+	}{
+		{"Union", s.Union(o), []int{1, 2, 3, 5}},
+		{"Intersect", s.Intersect(o), []int{2, 3}},
+		{"Difference", s.Difference(o), []int{1}},
+	} {
+		got := setElems(tc.s)
+		if len(got) != len(tc.want) || tc.s.Len() != len(tc.want) {
+			t.Fatalf("%s = %v, want %v", tc.name, got, tc.want)
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Fatalf("%s = %v, want %v", tc.name, got, tc.want)
+			}
+		}
+	}
+}
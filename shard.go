@@ -0,0 +1,151 @@
+package cmap
+
+import (
+	"errors"
+	"runtime"
+	"sync/atomic"
+)
+
+// ErrKeyNotInShard is the panic value of a ShardView used with a key of
+// another bucket.
+var ErrKeyNotInShard = errors.New("cmap: key not in the locked shard")
+
+// ShardView reads and writes the keys of the bucket locked by
+// WithShardLocked.
+type ShardView interface {
+	// Get returns the value of key, including the writes of the view.
+	Get(key interface{}) (value interface{}, ok bool)
+	// Set sets the value of key.
+	Set(key, value interface{})
+	// Delete deletes key.
+	Delete(key interface{})
+	// Contains reports whether key belongs to the bucket, and so can be
+	// used with the view.
+	Contains(key interface{}) bool
+	// Range calls f sequentially for each key and value of the bucket,
+	// including the writes of the view. If f returns false, range stops
+	// the iteration.
+	Range(f func(key, value interface{}) bool) bool
+}
+
+// WithShardLocked calls fn with a view of the bucket of key, locked while
+// fn is running: fn can read and write every key of the bucket, like the
+// keys of a DoAtomic transaction, without knowing them in advance. The
+// writes of fn are applied once it returns. Use WithPartitioner to choose
+// the keys sharing a bucket.
+//
+// fn must only use the keys of the bucket, and must not use the map.
+// Callbacks, watchers and persister are called after the bucket is
+// unlocked.
+func (m *CMap) WithShardLocked(key interface{}, fn func(shard ShardView)) {
+	if m.checkOpen() != nil {
+		return
+	}
+	hash := m.hash(key)
+	for {
+		n, locked, ok := m.lockKeys(map[interface{}]uintptr{key: hash})
+		if !ok {
+			runtime.Gosched()
+			continue
+		}
+		s := &shardView{txView: txView{
+			n:       n,
+			hashes:  map[interface{}]uintptr{key: hash},
+			buckets: locked,
+			writes:  make(map[interface{}]txWrite),
+		}, m: m, index: hash & n.mask}
+		done, _ := s.run(m, func(TxView) error {
+			fn(s)
+			return nil
+		})
+		for _, w := range done {
+			m.txDone(n, w)
+		}
+		return
+	}
+}
+
+type shardView struct {
+	txView
+	m     *CMap
+	index uintptr // of the locked bucket
+}
+
+func (s *shardView) Contains(key interface{}) bool {
+	if _, ok := s.hashes[key]; ok {
+		return true
+	}
+	hash := s.m.keyHash(key)
+	if hash&s.n.mask != s.index {
+		return false
+	}
+	s.hashes[key] = hash
+	return true
+}
+
+func (s *shardView) check(key interface{}) {
+	if !s.Contains(key) {
+		panic(ErrKeyNotInShard)
+	}
+}
+
+func (s *shardView) Get(key interface{}) (value interface{}, ok bool) {
+	s.check(key)
+	return s.txView.Get(key)
+}
+
+func (s *shardView) Set(key, value interface{}) {
+	s.check(key)
+	s.txView.Set(key, value)
+}
+
+func (s *shardView) Delete(key interface{}) {
+	s.check(key)
+	s.txView.Delete(key)
+}
+
+func (s *shardView) Range(f func(key, value interface{}) bool) bool {
+	b := s.buckets[s.index]
+	if !b.rangeLive(func(key, value interface{}) bool {
+		if w, ok := s.writes[key]; ok {
+			if w.del {
+				return true
+			}
+			value = w.value
+		}
+		return f(key, value)
+	}) {
+		return false
+	}
+	// the keys added by the view
+	for key, w := range s.writes {
+		if w.del {
+			continue
+		}
+		raw, present := b.load(key, s.hashes[key])
+		if present {
+			_, present = unwrap(raw)
+		}
+		if !present && !f(key, w.value) {
+			return false
+		}
+	}
+	return true
+}
+
+// ShardFor returns the index of the bucket of key, among the 1<<Stats().B
+// buckets of the map. The index changes when the map is resized.
+func (m *CMap) ShardFor(key interface{}) int {
+	n := m.getNode()
+	return int(m.keyHash(key) & n.mask)
+}
+
+// ShardLen returns the number of elements of the bucket i, as counted by
+// the bucket like in Stats, or 0 if i is not the index of a bucket.
+func (m *CMap) ShardLen(i int) int {
+	n := m.getNode()
+	if i < 0 || uintptr(i) > n.mask {
+		return 0
+	}
+	return int(atomic.LoadInt64(&n.loadBucket(uintptr(i)).count))
+}
@@ -0,0 +1,105 @@
+package cmap_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestWithShardLocked(t *testing.T) {
+	m := cmap.New(cmap.WithPartitioner(func(key interface{}) uintptr {
+		return uintptr(key.(tenantKey).tenant)
+	}))
+	for i := 0; i < 100; i++ {
+		m.Store(tenantKey{i % 2, i}, 1)
+	}
+
+	// a per-tenant total kept next to the entries of the tenant
+	total := tenantKey{0, -1}
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				m.WithShardLocked(total, func(s cmap.ShardView) {
+					k := tenantKey{0, 1000 + g*100 + i}
+					s.Set(k, 1)
+					n, _ := s.Get(total)
+					c, _ := n.(int)
+					s.Set(total, c+1)
+				})
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	m.WithShardLocked(total, func(s cmap.ShardView) {
+		sum, count := 0, 0
+		s.Range(func(key, value interface{}) bool {
+			if key.(tenantKey).tenant != 0 {
+				t.Errorf("Range of tenant 0 saw %v", key)
+			}
+			if key != total {
+				sum += value.(int)
+				count++
+			}
+			return true
+		})
+		if n, _ := s.Get(total); n != 800 || sum != 850 || count != 850 {
+			t.Errorf("total = %v, sum %d of %d keys; want 800, 850, 850", n, sum, count)
+		}
+
+		s.Set(tenantKey{0, -2}, 2)
+		s.Delete(tenantKey{0, 0})
+		seen := map[interface{}]bool{}
+		s.Range(func(key, _ interface{}) bool {
+			seen[key] = true
+			return true
+		})
+		if !seen[tenantKey{0, -2}] || seen[tenantKey{0, 0}] {
+			t.Errorf("Range ignored the writes of the view")
+		}
+		if s.Contains(tenantKey{1, 1}) {
+			t.Errorf("Contains reported a key of another tenant")
+		}
+		defer func() {
+			if recover() != cmap.ErrKeyNotInShard {
+				t.Errorf("Set of another tenant did not panic with ErrKeyNotInShard")
+			}
+		}()
+		s.Set(tenantKey{1, 1}, 0)
+	})
+	if _, ok := m.Load(tenantKey{0, -2}); !ok {
+		t.Fatalf("Set of a view not applied")
+	}
+	if _, ok := m.Load(tenantKey{0, 0}); ok {
+		t.Fatalf("Delete of a view not applied")
+	}
+}
+
+func TestShardFor(t *testing.T) {
+	var m cmap.CMap
+	for i := 0; i < 1000; i++ {
+		m.Store(i, i)
+	}
+	counts := make(map[int]int)
+	for i := 0; i < 1000; i++ {
+		counts[m.ShardFor(i)]++
+	}
+	buckets := len(m.Stats().Buckets)
+	total := 0
+	for i := 0; i < buckets; i++ {
+		if n := m.ShardLen(i); n != counts[i] {
+			t.Fatalf("ShardLen(%d) = %d, ShardFor counted %d", i, n, counts[i])
+		}
+		total += m.ShardLen(i)
+	}
+	if total != 1000 {
+		t.Fatalf("ShardLen sums to %d, want 1000", total)
+	}
+	if m.ShardLen(-1) != 0 || m.ShardLen(buckets) != 0 {
+		t.Fatalf("ShardLen of an index out of range is not 0")
+	}
+}
@@ -0,0 +1,60 @@
+package cmap
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// The memory held by each element of a bucket, besides its key and value:
+// the slots of the Go maps of a Map and its entry, or the slot and the
+// entry of a swissTable. Measured on 64-bit platforms.
+const (
+	mapEntryBytes   = 64
+	swissEntryBytes = 56
+)
+
+// WithWeigher makes SizeBytes add weigher(key, value) for each entry, like
+// the size of the memory referenced by its key and value. The total is
+// updated at each write, calling weigher without holding any lock.
+//
+// weigher must return the same weight for the same entry. Writes racing
+// on the same key may leave the total slightly off.
+func WithWeigher(weigher func(key, value interface{}) int64) Option {
+	return func(m *CMap) {
+		m.weigher = weigher
+	}
+}
+
+// SizeBytes returns an estimate of the memory held by the map: its
+// buckets and the slots of their elements, and the weight of the entries
+// given by WithWeigher. The memory referenced by the keys and values is
+// only counted by the weigher.
+func (m *CMap) SizeBytes() int64 {
+	n := m.getNode()
+	size := int64(unsafe.Sizeof(*m)) + n.sizeBytes()
+	if o := n.oldNode(); o != nil {
+		size += o.sizeBytes()
+	}
+	perEntry := int64(mapEntryBytes)
+	if n.swiss {
+		perEntry = swissEntryBytes
+	}
+	return size + n.count()*perEntry + atomic.LoadInt64(&m.weight)
+}
+
+// sizeBytes returns the memory held by n and its empty buckets.
+func (n *node) sizeBytes() int64 {
+	perBucket := int64(unsafe.Sizeof(unsafe.Pointer(nil)) + unsafe.Sizeof(bucket{}))
+	if n.swiss {
+		perBucket += int64(unsafe.Sizeof(swissTable{}))
+	}
+	return int64(unsafe.Sizeof(*n)) + int64(len(n.data))*perBucket + int64(len(n.groups))*4
+}
+
+// weigh adds the weight of an entry to the total of the map, or removes
+// it if sign is -1.
+func (m *CMap) weigh(key, value interface{}, sign int64) {
+	if m.weigher != nil {
+		atomic.AddInt64(&m.weight, sign*m.weigher(key, value))
+	}
+}
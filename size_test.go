@@ -0,0 +1,58 @@
+package cmap_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/min1324/cmap"
+)
+
+func TestSizeBytes(t *testing.T) {
+	var m cmap.CMap
+	empty := m.SizeBytes()
+	if empty <= 0 {
+		t.Fatalf("SizeBytes() = %d for an empty map", empty)
+	}
+	for i := 0; i < 10000; i++ {
+		m.Store(i, i)
+	}
+	full := m.SizeBytes()
+	if full < empty+10000*32 {
+		t.Fatalf("SizeBytes() = %d with 10000 entries, %d empty", full, empty)
+	}
+	for i := 0; i < 10000; i++ {
+		m.Delete(i)
+	}
+	if s := m.SizeBytes(); s >= full {
+		t.Fatalf("SizeBytes() = %d once emptied, %d full", s, full)
+	}
+}
+
+func TestWithWeigher(t *testing.T) {
+	m := cmap.New(cmap.WithWeigher(func(_, value interface{}) int64 {
+		return int64(len(value.(string)))
+	}))
+	m.Store("a", "xxxx")
+	m.Store("a", "xx")
+	m.LoadOrStore("b", "yyy")
+	m.LoadOrStore("b", "zzzzzzzz")
+	m.Update("b", func(interface{}, bool) (interface{}, bool) { return "y", false })
+	m.StoreWithTTL("c", "ccccc", time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	m.Store("c", "cc") // replaces an expired value
+	m.DoAtomic([]interface{}{"a", "d"}, func(tx cmap.TxView) error {
+		tx.Set("a", "aaa")
+		tx.Set("d", "dddd")
+		return nil
+	})
+	m.Delete("d")
+
+	// a: 3, b: 1, c: 2
+	var st cmap.CMap
+	st.Store("a", 0)
+	st.Store("b", 0)
+	st.Store("c", 0)
+	if got, want := m.SizeBytes(), st.SizeBytes()+6; got != want {
+		t.Fatalf("SizeBytes() = %d, want %d with the weights of the values", got, want)
+	}
+}
@@ -0,0 +1,131 @@
+package cmap
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// snapshotVersion is the version of the format written by Snapshot.
+const snapshotVersion = 1
+
+// snapshotHeader starts a snapshot.
+type snapshotHeader struct {
+	Version int
+}
+
+// snapshotRecord is an entry of a snapshot, or the end of the snapshot,
+// which tells a snapshot from one truncated between two entries.
+type snapshotRecord struct {
+	End bool
+	SnapshotEntry
+}
+
+// SnapshotEntry is an entry of a snapshot, see RangeSnapshotEntries.
+type SnapshotEntry struct {
+	Key, Value interface{}
+	Deadline   int64 // unix nano, 0 if the key never expires
+	Idle       int64 // ns, see StoreWithIdleTTL
+}
+
+// Snapshot writes the entries of m to w with encoding/gob, copying them
+// out of one bucket at a time, so that a large map is never copied whole.
+// Keys stored with a ttl are written with their deadline, so that Restore
+// re-arms it, and an idle ttl with its duration as well.
+//
+// The concrete types of the keys and values must be registered with
+// gob.Register, except for the basic types. Like Range, Snapshot does not
+// correspond to any consistent snapshot of the map's contents: use it on
+// a Fork for that.
+func (m *CMap) Snapshot(w io.Writer) error {
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(snapshotHeader{Version: snapshotVersion}); err != nil {
+		return err
+	}
+	err := m.RangeSnapshotEntries(func(e SnapshotEntry) error {
+		return enc.Encode(&snapshotRecord{SnapshotEntry: e})
+	})
+	if err != nil {
+		return err
+	}
+	return enc.Encode(&snapshotRecord{End: true})
+}
+
+// RangeSnapshotEntries calls f with the entries of m, with their ttl, for
+// the encoders of snapshots, like Snapshot. The entries are copied out of
+// one bucket at a time, and f may use the map. If f returns an error,
+// RangeSnapshotEntries stops and returns it.
+func (m *CMap) RangeSnapshotEntries(f func(e SnapshotEntry) error) error {
+	var err error
+	m.rangeChunks(nil, maxPooledEntries, true, func(key, value interface{}) bool {
+		e := SnapshotEntry{Key: key, Value: value}
+		if x, ok := value.(*expiring); ok {
+			e.Value, e.Deadline, e.Idle = x.value, unixNano(atomic.LoadInt64(&x.deadline)), x.idle
+		}
+		err = f(e)
+		return err == nil
+	})
+	return err
+}
+
+// Restore stores the entries of a snapshot written by Snapshot into m,
+// replacing the values of the keys present. A key with a ttl gets back
+// its deadline, and is skipped if it expired meanwhile.
+//
+// Restore stops at the first error, like a write StoreErr would return,
+// and keeps the entries stored before it. A snapshot cut short returns
+// io.ErrUnexpectedEOF, even between two entries. Once the header is read,
+// Restore increments the generation of m, even on an error, see
+// Generation.
+func (m *CMap) Restore(r io.Reader) error {
+	dec := gob.NewDecoder(r)
+	var h snapshotHeader
+	if err := dec.Decode(&h); err != nil {
+		return err
+	}
+	if h.Version != snapshotVersion {
+		return fmt.Errorf("cmap: snapshot version %d, want %d", h.Version, snapshotVersion)
+	}
+	defer m.Invalidate()
+	for {
+		var r snapshotRecord
+		err := dec.Decode(&r)
+		if errors.Is(err, io.EOF) {
+			return io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		if r.End {
+			return nil
+		}
+		if err := m.RestoreEntry(r.SnapshotEntry); err != nil {
+			return err
+		}
+	}
+}
+
+// RestoreEntry stores an entry of a snapshot into m, for the decoders of
+// snapshots, like Restore: the value of a key present is replaced, a key
+// with a ttl gets back its deadline, and a key which expired meanwhile is
+// skipped. It returns the errors of StoreErr.
+func (m *CMap) RestoreEntry(e SnapshotEntry) error {
+	deadline := fromUnixNano(e.Deadline)
+	if deadline != 0 && nanotime() >= deadline {
+		return nil
+	}
+	if err := m.checkOpen(); err != nil {
+		return err
+	}
+	if err := m.checkKey(e.Key); err != nil {
+		return err
+	}
+	hash, raw := m.hash(e.Key), m.wrap(e.Value, deadline, e.Idle)
+	if err := m.store(hash, e.Key, raw); err != nil {
+		return err
+	}
+	m.schedule(e.Key, hash, raw)
+	return nil
+}
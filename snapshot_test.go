@@ -0,0 +1,80 @@
+package cmap_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/min1324/cmap"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	var m cmap.CMap
+	for i := 0; i < 1000; i++ {
+		m.Store(i, i)
+	}
+	m.StoreWithTTL("ttl", "v", time.Hour)
+	m.StoreWithIdleTTL("idle", "v", time.Hour)
+	m.StoreWithTTL("short", "v", 200*time.Millisecond)
+
+	var buf bytes.Buffer
+	if err := m.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	r := new(cmap.CMap)
+	if err := r.Restore(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Equal(&m, nil) {
+		t.Fatal("restored map differs")
+	}
+	if ttl, ok := r.GetTTL("ttl"); !ok || ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Fatalf("GetTTL(ttl) = %v, %v after Restore; want about 1h", ttl, ok)
+	}
+	if ttl, ok := r.GetTTL(1); !ok || ttl != cmap.NoExpiration {
+		t.Fatalf("GetTTL(1) = %v, %v after Restore; want NoExpiration", ttl, ok)
+	}
+
+	// the snapshot keeps deadlines, not durations: a key expiring while it
+	// is on disk is not restored
+	time.Sleep(250 * time.Millisecond)
+	r = cmap.New(cmap.WithJanitor(time.Millisecond))
+	defer r.Close()
+	if err := r.Restore(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.Load("short"); ok {
+		t.Fatal("Restore resurrected an expired key")
+	}
+	if n := r.Len(); n != 1002 {
+		t.Fatalf("Len() = %d after Restore, want 1002", n)
+	}
+
+	// the idle ttl is re-armed as an idle ttl, which loads push back
+	time.Sleep(5 * time.Millisecond)
+	before, _ := r.GetTTL("idle")
+	r.Load("idle")
+	if after, _ := r.GetTTL("idle"); after <= before {
+		t.Fatalf("GetTTL(idle) = %v after a load, want more than %v", after, before)
+	}
+
+	if err := r.Restore(bytes.NewReader(data[:len(data)/2])); err == nil {
+		t.Fatal("Restore of a truncated snapshot succeeded")
+	}
+
+	// including between two entries
+	var two cmap.CMap
+	two.Store(1, 1)
+	two.Store(2, 2)
+	buf.Reset()
+	if err := two.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < buf.Len(); n++ {
+		if err := new(cmap.CMap).Restore(bytes.NewReader(buf.Bytes()[:n])); err == nil {
+			t.Fatalf("Restore of %d bytes of %d succeeded", n, buf.Len())
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package cmap
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// Stats describes the distribution of the elements of a CMap over its
+// buckets, and its resize history.
+type Stats struct {
+	B       uint8 // log_2 of # of buckets
+	Len     int   // number of elements
+	Buckets []int // number of elements of each bucket
+
+	Min    int     // min number of elements in a bucket
+	Max    int     // max number of elements in a bucket
+	Mean   float64 // mean number of elements in a bucket
+	StdDev float64 // standard deviation of the number of elements in a bucket
+	P50    int     // median number of elements in a bucket
+	P99    int     // 99th percentile of the number of elements in a bucket
+	Empty  int     // number of buckets without element
+
+	// Writes counts the writes of each bucket since it was created by the
+	// last resize or Compact. HotBucket is the bucket with most writes,
+	// and HotShare its share of all the writes: a share far above 1/2^B
+	// means the keys written are skewed to one bucket, which serializes
+	// their writers.
+	Writes    []uint64
+	HotBucket int
+	HotShare  float64
+
+	Grows    uint64 // number of resizes to more buckets
+	Shrinks  uint64 // number of resizes to fewer buckets
+	Resizing bool   // whether the buckets are being evacuated
+}
+
+// Stats returns statistics about the map. It does not block writers, so
+// the counts may not correspond to any single instant when the map is
+// written concurrently.
+//
+// While resizing, the elements of the buckets not evacuated yet are
+// counted where they will be evacuated to.
+func (m *CMap) Stats() Stats {
+	n := m.getNode()
+	s := Stats{
+		B:       n.B,
+		Buckets: make([]int, n.mask+1),
+		Writes:  make([]uint64, n.mask+1),
+		Grows:   atomic.LoadUint64(&m.grows),
+		Shrinks: atomic.LoadUint64(&m.shrinks),
+	}
+	o := n.oldNode()
+	s.Resizing = o != nil
+	for i := range s.Buckets {
+		if b := n.getBucket(uintptr(i)); b != nil {
+			s.Buckets[i] = int(atomic.LoadInt64(&b.count))
+			s.Writes[i] = atomic.LoadUint64(&b.writes)
+		}
+	}
+	if o != nil {
+		step := uintptr(len(n.groups))
+		for g := uintptr(0); g < step; g++ {
+			if atomic.LoadUint32(&n.groups[g]) == evacDone {
+				continue
+			}
+			for i := g; i <= o.mask; i += step {
+				o.getBucket(i).rangeHash(func(_, _ interface{}, hash uintptr) bool {
+					s.Buckets[hash&n.mask]++
+					return true
+				})
+			}
+		}
+	}
+
+	s.Min = s.Buckets[0]
+	for _, c := range s.Buckets {
+		s.Len += c
+		if c < s.Min {
+			s.Min = c
+		}
+		if c > s.Max {
+			s.Max = c
+		}
+	}
+	s.Mean = float64(s.Len) / float64(len(s.Buckets))
+	s.summarize()
+
+	var writes uint64
+	for i, w := range s.Writes {
+		writes += w
+		if w > s.Writes[s.HotBucket] {
+			s.HotBucket = i
+		}
+	}
+	if writes > 0 {
+		s.HotShare = float64(s.Writes[s.HotBucket]) / float64(writes)
+	}
+	return s
+}
+
+// summarize sets the spread of the bucket sizes of s, from its histogram.
+func (s *Stats) summarize() {
+	var sq float64
+	for _, c := range s.Buckets {
+		d := float64(c) - s.Mean
+		sq += d * d
+	}
+	s.StdDev = math.Sqrt(sq / float64(len(s.Buckets)))
+
+	h := s.Histogram()
+	s.Empty = h[0]
+	seen, p50, p99 := 0, (len(s.Buckets)+1)/2, (len(s.Buckets)*99+99)/100
+	for size, c := range h {
+		if seen < p50 && seen+c >= p50 {
+			s.P50 = size
+		}
+		if seen < p99 && seen+c >= p99 {
+			s.P99 = size
+		}
+		seen += c
+	}
+}
+
+// Histogram returns the number of buckets of each size: the element i is
+// the number of buckets holding i elements, up to Max.
+func (s Stats) Histogram() []int {
+	h := make([]int, s.Max+1)
+	for _, c := range s.Buckets {
+		h[c]++
+	}
+	return h
+}
+
+// Histogram returns the number of buckets of each size, see
+// Stats.Histogram. Like Stats, it does not block writers.
+func (m *CMap) Histogram() []int {
+	return m.Stats().Histogram()
+}
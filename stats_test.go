@@ -0,0 +1,93 @@
+package cmap_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestStats(t *testing.T) {
+	var m cmap.CMap
+	for i := 0; i < 1000; i++ {
+		m.Store(i, i)
+	}
+	m.ForceResize(8)
+	check := func(s cmap.Stats, B uint8) {
+		t.Helper()
+		if s.B != B || len(s.Buckets) != 1<<B {
+			t.Fatalf("Stats has B = %d and %d buckets, want %d", s.B, len(s.Buckets), B)
+		}
+		sum := 0
+		for _, c := range s.Buckets {
+			sum += c
+			if c < s.Min || c > s.Max {
+				t.Fatalf("bucket of %d outside [%d, %d]", c, s.Min, s.Max)
+			}
+		}
+		if sum != 1000 || s.Len != 1000 || s.Mean != 1000/float64(len(s.Buckets)) {
+			t.Fatalf("Stats has Len %d, Mean %v, buckets sum %d", s.Len, s.Mean, sum)
+		}
+	}
+
+	s := m.Stats()
+	if !s.Resizing || s.Grows == 0 {
+		t.Fatalf("Stats has Resizing %v, Grows %d after ForceResize", s.Resizing, s.Grows)
+	}
+	check(s, 8)
+
+	m.WaitResize(context.Background())
+	m.ForceResize(5)
+	m.WaitResize(context.Background())
+	s = m.Stats()
+	if s.Resizing || s.Shrinks != 1 {
+		t.Fatalf("Stats has Resizing %v, Shrinks %d after shrinking", s.Resizing, s.Shrinks)
+	}
+	check(s, 5)
+}
+
+func TestStatsHotBucket(t *testing.T) {
+	var m cmap.CMap
+	for i := 0; i < 100; i++ {
+		m.Store(i, i)
+	}
+	for i := 0; i < 10000; i++ {
+		m.Store("hot", i)
+	}
+	s := m.Stats()
+	if s.HotShare < 0.9 {
+		t.Fatalf("HotShare = %v, want the share of the hot key", s.HotShare)
+	}
+	if s.Writes[s.HotBucket] < 10000 {
+		t.Fatalf("hot bucket %d has %d writes", s.HotBucket, s.Writes[s.HotBucket])
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	var m cmap.CMap
+	for i := 0; i < 1000; i++ {
+		m.Store(i, i)
+	}
+	s := m.Stats()
+	h := s.Histogram()
+	if len(h) != s.Max+1 {
+		t.Fatalf("len(Histogram()) = %d, want Max+1 = %d", len(h), s.Max+1)
+	}
+	buckets, elems := 0, 0
+	for size, c := range h {
+		buckets += c
+		elems += size * c
+	}
+	if buckets != len(s.Buckets) || elems != 1000 {
+		t.Fatalf("Histogram counts %d buckets of %d elements, want %d of 1000", buckets, elems, len(s.Buckets))
+	}
+	if s.Empty != h[0] || s.P50 < s.Min || s.P50 > s.P99 || s.P99 > s.Max || s.StdDev < 0 {
+		t.Fatalf("Stats summary inconsistent: %+v", s)
+	}
+
+	m.ForceResize(12)
+	m.WaitResize(context.Background())
+	if s := m.Stats(); s.Empty < len(s.Buckets)-1000 || m.Histogram()[0] != s.Empty {
+		t.Fatalf("%d empty buckets of %d for 1000 elements", s.Empty, len(s.Buckets))
+	}
+}
@@ -0,0 +1,161 @@
+package cmap
+
+import "sync"
+
+// sBit is log_2 of the # of shards of the specialized maps.
+const sBit = 5
+
+// StringMap is like a CMap keyed by strings, but its shards are plain
+// map[string]*stringEntry selected by the runtime string hash of the key,
+// so keys are never boxed into interfaces.
+//
+// The zero StringMap is empty and ready for use. A StringMap must not be
+// copied after first use.
+type StringMap struct {
+	shards [1 << sBit]stringShard
+}
+
+type stringShard struct {
+	mu       sync.RWMutex
+	m        map[string]*stringEntry
+	index    *skiplist // sorted keys, see NewIndexedStringMap
+	prefixes *bloom    // prefixes of the keys, see NewPrefixFilteredStringMap
+	plen     int       // length of the prefixes
+}
+
+// stringEntry holds the value of a key of a StringMap, written with the
+// shard locked. The value of a key present is set through its entry, so
+// that the key kept by the map is not replaced, see StoreBytes.
+type stringEntry struct {
+	value interface{}
+}
+
+func (m *StringMap) getShard(key string) *stringShard {
+	return &m.shards[shash(key)&(1<<sBit-1)]
+}
+
+// Load returns the value stored in the map for a key, or nil if no
+// value is present.
+// The ok result indicates whether value was found in the map.
+func (m *StringMap) Load(key string) (value interface{}, ok bool) {
+	s := m.getShard(key)
+	s.mu.RLock()
+	e, ok := s.m[key]
+	if ok {
+		value = e.value
+	}
+	s.mu.RUnlock()
+	return value, ok
+}
+
+// Store sets the value for a key.
+func (m *StringMap) Store(key string, value interface{}) {
+	s := m.getShard(key)
+	s.mu.Lock()
+	s.set(key, value)
+	s.mu.Unlock()
+}
+
+// LoadOrStore returns the existing value for the key if present.
+// Otherwise, it stores and returns the given value.
+// The loaded result is true if the value was loaded, false if stored.
+func (m *StringMap) LoadOrStore(key string, value interface{}) (actual interface{}, loaded bool) {
+	s := m.getShard(key)
+	s.mu.Lock()
+	if e, ok := s.m[key]; ok {
+		actual, loaded = e.value, true
+	} else {
+		s.set(key, value)
+		actual = value
+	}
+	s.mu.Unlock()
+	return actual, loaded
+}
+
+// LoadAndDelete deletes the value for a key, returning the previous value if any.
+// The loaded result reports whether the key was present.
+func (m *StringMap) LoadAndDelete(key string) (value interface{}, loaded bool) {
+	s := m.getShard(key)
+	s.mu.Lock()
+	value, loaded = s.del(key)
+	s.mu.Unlock()
+	return value, loaded
+}
+
+// Delete deletes the value for a key.
+func (m *StringMap) Delete(key string) {
+	m.LoadAndDelete(key)
+}
+
+// Len returns the number of elements within the map.
+func (m *StringMap) Len() int {
+	n := 0
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		n += len(s.m)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+// Range calls f sequentially for each key and value present in the map.
+// If f returns false, range stops the iteration.
+//
+// The entries of a shard are copied before f is called on them, so f may
+// use the map.
+func (m *StringMap) Range(f func(key string, value interface{}) bool) bool {
+	var keys []string
+	var values []interface{}
+	for i := range m.shards {
+		s := &m.shards[i]
+		keys, values = keys[:0], values[:0]
+		s.mu.RLock()
+		for k, e := range s.m {
+			keys = append(keys, k)
+			values = append(values, e.value)
+		}
+		s.mu.RUnlock()
+		for j, k := range keys {
+			if !f(k, values[j]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// set sets the value of key, s must be locked. The entry of a key present
+// is updated in place, keeping its key.
+func (s *stringShard) set(key string, value interface{}) {
+	if e, ok := s.m[key]; ok {
+		e.value = value
+		return
+	}
+	if s.m == nil {
+		s.m = make(map[string]*stringEntry)
+	}
+	if s.index != nil {
+		s.index.insert(stringLess, key, nil)
+	}
+	if s.prefixes != nil && len(key) >= s.plen {
+		s.prefixes.add(shash(key[:s.plen]))
+	}
+	s.m[key] = &stringEntry{value}
+}
+
+// del deletes key, s must be locked.
+func (s *stringShard) del(key string) (value interface{}, loaded bool) {
+	e, loaded := s.m[key]
+	if loaded {
+		value = e.value
+		delete(s.m, key)
+		if s.index != nil {
+			s.index.remove(stringLess, key)
+		}
+		if s.prefixes != nil && len(key) >= s.plen {
+			s.prefixes.remove(shash(key[:s.plen]))
+		}
+	}
+	return value, loaded
+}
@@ -0,0 +1,76 @@
+package cmap_test
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestStringMap(t *testing.T) {
+	var m cmap.StringMap
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := g; i < 1000; i += 4 {
+				m.Store(strconv.Itoa(i), i)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if m.Len() != 1000 {
+		t.Fatalf("Len() = %d, want 1000", m.Len())
+	}
+	if v, ok := m.Load("42"); !ok || v != 42 {
+		t.Fatalf("Load(42) = %v, %v", v, ok)
+	}
+	if v, loaded := m.LoadOrStore("42", 0); !loaded || v != 42 {
+		t.Fatalf("LoadOrStore(42) = %v, %v", v, loaded)
+	}
+	if v, loaded := m.LoadAndDelete("42"); !loaded || v != 42 {
+		t.Fatalf("LoadAndDelete(42) = %v, %v", v, loaded)
+	}
+	if _, ok := m.Load("42"); ok {
+		t.Fatalf("Load(42) after delete")
+	}
+	n := 0
+	m.Range(func(key string, value interface{}) bool {
+		if key != strconv.Itoa(value.(int)) {
+			t.Fatalf("Range saw %q: %v", key, value)
+		}
+		n++
+		return true
+	})
+	if n != 999 {
+		t.Fatalf("Range saw %d keys, want 999", n)
+	}
+}
+
+func BenchmarkStringMapLoad(b *testing.B) {
+	var m cmap.StringMap
+	var c cmap.CMap
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+		m.Store(keys[i], i)
+		c.Store(keys[i], i)
+	}
+	b.Run("StringMap", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				m.Load(keys[i&1023])
+			}
+		})
+	})
+	b.Run("CMap", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				c.Load(keys[i&1023])
+			}
+		})
+	})
+}
@@ -0,0 +1,308 @@
+package cmap
+
+import (
+	"math/bits"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// WithSwissBuckets makes the buckets of the map open-addressing tables in
+// the layout of swiss tables, instead of Maps: keys are found by probing
+// groups of 8 slots whose 7 bits tags are compared at once, using the
+// hashes already computed by the map.
+//
+// Like Maps, the tables are read without any lock, and written under a
+// lock of their own.
+func WithSwissBuckets() Option {
+	return func(m *CMap) {
+		m.swiss = true
+	}
+}
+
+const (
+	swissGroup = 8                  // # of slots of a group
+	swissEmpty = 0x80               // tag of an empty slot
+	swissLSB   = 0x0101010101010101 // lowest bit of every tag
+	swissMSB   = 0x8080808080808080 // highest bit of every tag
+)
+
+// swissTable is an open-addressing hash table of keys and values, safe
+// for concurrent use. Loads are lock-free, writes are serialized by mu.
+type swissTable struct {
+	mu   sync.Mutex
+	tab  unsafe.Pointer // *swissTab, replaced on rehash
+	live int            // # of keys with a value, changed with mu held
+}
+
+// swissTab is the content of a swissTable. Its slots are filled once, and
+// only emptied by a rehash into a new swissTab, so a reader of an old one
+// finds every key which was present when it was replaced.
+type swissTab struct {
+	mask  uintptr          // # of groups - 1
+	ctrl  []uint64         // tags of the slots of each group
+	slots []unsafe.Pointer // *swissEntry
+	used  int              // # of slots filled
+}
+
+// swissEntry is the slot of a key. Deleting the key only clears p, like
+// an entry of Map, so that a new value is stored in place.
+type swissEntry struct {
+	key  interface{}
+	hash uintptr
+	p    unsafe.Pointer // *interface{}, nil if deleted
+}
+
+func newSwissTab(groups uintptr) *swissTab {
+	t := &swissTab{
+		mask:  groups - 1,
+		ctrl:  make([]uint64, groups),
+		slots: make([]unsafe.Pointer, groups*swissGroup),
+	}
+	for i := range t.ctrl {
+		t.ctrl[i] = swissEmpty * swissLSB
+	}
+	return t
+}
+
+func (e *swissEntry) load() (value interface{}, ok bool) {
+	p := atomic.LoadPointer(&e.p)
+	if p == nil {
+		return nil, false
+	}
+	return *(*interface{})(p), true
+}
+
+// h1 selects the first group to probe, h2 is the tag. The low bits of
+// hash choose the bucket, and are the same for its keys, or are those of a
+// partition, see WithPartitioner: both are taken from hash remixed, h2
+// from its top bits.
+func swissH(hash uintptr) (h1 uintptr, h2 uint64) {
+	x := scramble(uint64(hash))
+	return uintptr(x), x >> 57
+}
+
+// matchTag returns a mask of the highest bits of the tags of w which may
+// equal tag, false positives are possible.
+func matchTag(w, tag uint64) uint64 {
+	x := w ^ (swissLSB * tag)
+	return (x - swissLSB) &^ x & swissMSB
+}
+
+// find returns the entry of key, or nil.
+func (t *swissTab) find(key interface{}, hash uintptr) *swissEntry {
+	g, tag := swissH(hash)
+	g &= t.mask
+	for step := uintptr(1); ; step++ {
+		w := atomic.LoadUint64(&t.ctrl[g])
+		for m := matchTag(w, tag); m != 0; m &= m - 1 {
+			i := g*swissGroup + uintptr(bits.TrailingZeros64(m)/8)
+			e := (*swissEntry)(atomic.LoadPointer(&t.slots[i]))
+			if e != nil && e.hash == hash && e.key == key {
+				return e
+			}
+		}
+		if w&swissMSB != 0 {
+			// an empty slot ends the probe
+			return nil
+		}
+		if step > t.mask {
+			return nil
+		}
+		// triangular probing visits every group
+		g = (g + step) & t.mask
+	}
+}
+
+// insert puts e in the first empty slot of its probe sequence, t must
+// have one and be owned by the writer.
+func (t *swissTab) insert(e *swissEntry) {
+	g, tag := swissH(e.hash)
+	g &= t.mask
+	for step := uintptr(1); ; step++ {
+		w := atomic.LoadUint64(&t.ctrl[g])
+		if empty := w & swissMSB; empty != 0 {
+			j := uintptr(bits.TrailingZeros64(empty) / 8)
+			// publish the entry before its tag, readers load them in the
+			// opposite order
+			atomic.StorePointer(&t.slots[g*swissGroup+j], unsafe.Pointer(e))
+			w = w&^(0xff<<(j*8)) | tag<<(j*8)
+			atomic.StoreUint64(&t.ctrl[g], w)
+			t.used++
+			return
+		}
+		g = (g + step) & t.mask
+	}
+}
+
+func (s *swissTable) getTab() *swissTab {
+	return (*swissTab)(atomic.LoadPointer(&s.tab))
+}
+
+func (s *swissTable) load(key interface{}, hash uintptr) (value interface{}, ok bool) {
+	t := s.getTab()
+	if t == nil {
+		return nil, false
+	}
+	if e := t.find(key, hash); e != nil {
+		return e.load()
+	}
+	return nil, false
+}
+
+// entryLocked returns the entry of key, adding one without value if it
+// is missing. s.mu must be held.
+func (s *swissTable) entryLocked(key interface{}, hash uintptr) *swissEntry {
+	t := s.getTab()
+	if t != nil {
+		if e := t.find(key, hash); e != nil {
+			return e
+		}
+	}
+	// keep at least one empty slot per group on average
+	if t == nil || (t.used+1)*8 > len(t.slots)*7 {
+		t = s.rehashLocked(s.live + 1)
+	}
+	e := &swissEntry{key: key, hash: hash}
+	t.insert(e)
+	return e
+}
+
+// rehashLocked replaces the table by one sized for n keys, holding only
+// the entries with a value.
+func (s *swissTable) rehashLocked(n int) *swissTab {
+	groups := uintptr(1)
+	for groups*swissGroup < uintptr(n)*2 {
+		groups <<= 1
+	}
+	nt := newSwissTab(groups)
+	if t := s.getTab(); t != nil {
+		for i := range t.slots {
+			e := (*swissEntry)(t.slots[i])
+			if e != nil && atomic.LoadPointer(&e.p) != nil {
+				nt.insert(e)
+			}
+		}
+	}
+	atomic.StorePointer(&s.tab, unsafe.Pointer(nt))
+	return nt
+}
+
+func (s *swissTable) reserve(n int) {
+	s.mu.Lock()
+	if t := s.getTab(); t == nil || len(t.slots) < n*2 {
+		s.rehashLocked(n)
+	}
+	s.mu.Unlock()
+}
+
+func (s *swissTable) store(key, value interface{}, hash uintptr) {
+	s.mu.Lock()
+	e := s.entryLocked(key, hash)
+	if atomic.SwapPointer(&e.p, unsafe.Pointer(&value)) == nil {
+		s.live++
+	}
+	s.mu.Unlock()
+}
+
+func (s *swissTable) swap(key, value interface{}, hash uintptr) (previous interface{}, loaded bool) {
+	s.mu.Lock()
+	e := s.entryLocked(key, hash)
+	p := atomic.SwapPointer(&e.p, unsafe.Pointer(&value))
+	if p == nil {
+		s.live++
+	}
+	s.mu.Unlock()
+	if p == nil {
+		return nil, false
+	}
+	return *(*interface{})(p), true
+}
+
+func (s *swissTable) loadOrStore(key, value interface{}, hash uintptr) (actual interface{}, loaded bool) {
+	if actual, loaded = s.load(key, hash); loaded {
+		return actual, true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.entryLocked(key, hash)
+	if actual, loaded = e.load(); loaded {
+		return actual, true
+	}
+	atomic.StorePointer(&e.p, unsafe.Pointer(&value))
+	s.live++
+	return value, false
+}
+
+func (s *swissTable) loadAndDelete(key interface{}, hash uintptr) (value interface{}, loaded bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.getTab()
+	if t == nil {
+		return nil, false
+	}
+	e := t.find(key, hash)
+	if e == nil {
+		return nil, false
+	}
+	p := atomic.SwapPointer(&e.p, nil)
+	if p == nil {
+		return nil, false
+	}
+	s.live--
+	return *(*interface{})(p), true
+}
+
+func (s *swissTable) compareAndSwap(key interface{}, hash uintptr, old, new interface{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.getTab()
+	if t == nil {
+		return false
+	}
+	e := t.find(key, hash)
+	if e == nil {
+		return false
+	}
+	if v, ok := e.load(); !ok || v != old {
+		return false
+	}
+	atomic.StorePointer(&e.p, unsafe.Pointer(&new))
+	return true
+}
+
+func (s *swissTable) compareAndDelete(key interface{}, hash uintptr, old interface{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.getTab()
+	if t == nil {
+		return false
+	}
+	e := t.find(key, hash)
+	if e == nil {
+		return false
+	}
+	if v, ok := e.load(); !ok || v != old {
+		return false
+	}
+	atomic.StorePointer(&e.p, nil)
+	s.live--
+	return true
+}
+
+func (s *swissTable) rangeHash(f func(key, value interface{}, hash uintptr) bool) bool {
+	t := s.getTab()
+	if t == nil {
+		return true
+	}
+	for i := range t.slots {
+		e := (*swissEntry)(atomic.LoadPointer(&t.slots[i]))
+		if e == nil {
+			continue
+		}
+		if v, ok := e.load(); ok && !f(e.key, v, e.hash) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,50 @@
+package cmap
+
+import "testing"
+
+// TestSwissTagSpread checks that the keys of one bucket of a large node,
+// whose low hash bits are all the same, get spread tags and first groups.
+func TestSwissTagSpread(t *testing.T) {
+	const mask = 1<<10 - 1
+	tags := make(map[uint64]bool)
+	groups := make(map[uintptr]bool)
+	n := 0
+	for i := 0; n < 1000; i++ {
+		hash := chash(i, 0)
+		if hash&mask != 0 {
+			continue
+		}
+		n++
+		h1, h2 := swissH(hash)
+		tags[h2] = true
+		groups[h1&255] = true
+	}
+	if len(tags) < 120 {
+		t.Errorf("%d keys of a bucket have %d distinct tags, want about 128", n, len(tags))
+	}
+	if len(groups) < 220 {
+		t.Errorf("%d keys of a bucket start in %d of 256 groups", n, len(groups))
+	}
+}
+
+// TestPartitionHashSpread checks that the keys of one partition, whose
+// hashes share the bits choosing a bucket, still get spread swiss tags and
+// bloom counters.
+func TestPartitionHashSpread(t *testing.T) {
+	m := New(WithPartitioner(func(key interface{}) uintptr { return 0 }))
+	f := newBloom(1 << 16)
+	tags := make(map[uint64]bool)
+	counters := make(map[uintptr]bool)
+	for i := 0; i < 1000; i++ {
+		hash := m.hashOf(i)
+		_, h2 := swissH(hash)
+		tags[h2] = true
+		counters[f.index(bloomMix(hash), 0)] = true
+	}
+	if len(tags) < 120 {
+		t.Errorf("1000 keys of a partition have %d distinct tags, want about 128", len(tags))
+	}
+	if len(counters) < 950 {
+		t.Errorf("1000 keys of a partition use %d distinct bloom counters", len(counters))
+	}
+}
@@ -0,0 +1,90 @@
+package cmap_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/min1324/cmap"
+)
+
+func TestSwissBuckets(t *testing.T) {
+	m := cmap.New(cmap.WithSwissBuckets())
+	const n = 20000
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := g; i < n; i += 4 {
+				m.Store(i, i)
+				if v, ok := m.Load(i); !ok || v != i {
+					t.Errorf("Load(%d) = %v, %v", i, v, ok)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if m.Len() != n {
+		t.Fatalf("Len() = %d, want %d", m.Len(), n)
+	}
+
+	for i := 0; i < n; i += 2 {
+		if v, loaded := m.LoadAndDelete(i); !loaded || v != i {
+			t.Fatalf("LoadAndDelete(%d) = %v, %v", i, v, loaded)
+		}
+	}
+	for i := 0; i < n; i += 4 {
+		if _, loaded := m.LoadOrStore(i, -i); loaded {
+			t.Fatalf("LoadOrStore(%d) loaded a deleted key", i)
+		}
+	}
+	if m.Len() != n/2+n/4 {
+		t.Fatalf("Len() = %d, want %d", m.Len(), n/2+n/4)
+	}
+	seen := 0
+	m.Range(func(key, value interface{}) bool {
+		k := key.(int)
+		if k%2 == 0 && value != -k || k%2 == 1 && value != k {
+			t.Fatalf("Range saw %v: %v", key, value)
+		}
+		seen++
+		return true
+	})
+	if seen != m.Len() {
+		t.Fatalf("Range saw %d keys, want %d", seen, m.Len())
+	}
+
+	m.StoreWithTTL("ttl", 1, time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	m.DeleteExpired()
+	if _, ok := m.Load("ttl"); ok {
+		t.Fatalf("an expired key is still present")
+	}
+	m.Compact()
+	if c := m.Clone(); c.Len() != m.Len() {
+		t.Fatalf("Clone has %d keys, want %d", c.Len(), m.Len())
+	}
+}
+
+func BenchmarkSwissLoad(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		m    *cmap.CMap
+	}{
+		{"Map", cmap.New()},
+		{"Swiss", cmap.New(cmap.WithSwissBuckets())},
+	} {
+		for i := 0; i < 1<<16; i++ {
+			bc.m.Store(i, i)
+		}
+		b.Run(bc.name, func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					bc.m.Load(i & (1<<16 - 1))
+				}
+			})
+		})
+	}
+}
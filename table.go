@@ -0,0 +1,78 @@
+package cmap
+
+// The content of a bucket is kept by its Map, or by its swissTable with
+// WithSwissBuckets. The methods below select the one in use.
+
+func (n *node) newBucket() *bucket {
+	b := new(bucket)
+	if n.swiss {
+		b.s = new(swissTable)
+	}
+	return b
+}
+
+func (b *bucket) load(key interface{}, hash uintptr) (value interface{}, ok bool) {
+	if b.s != nil {
+		return b.s.load(key, hash)
+	}
+	return b.m.Load(key)
+}
+
+func (b *bucket) store(key, value interface{}, hash uintptr) {
+	if b.s != nil {
+		b.s.store(key, value, hash)
+		return
+	}
+	b.m.storeHash(key, value, hash)
+}
+
+func (b *bucket) swap(key, value interface{}, hash uintptr) (previous interface{}, loaded bool) {
+	if b.s != nil {
+		return b.s.swap(key, value, hash)
+	}
+	return b.m.swapHash(key, value, hash)
+}
+
+func (b *bucket) loadOrStore(key, value interface{}, hash uintptr) (actual interface{}, loaded bool) {
+	if b.s != nil {
+		return b.s.loadOrStore(key, value, hash)
+	}
+	return b.m.loadOrStoreHash(key, value, hash)
+}
+
+func (b *bucket) loadAndDelete(key interface{}, hash uintptr) (value interface{}, loaded bool) {
+	if b.s != nil {
+		return b.s.loadAndDelete(key, hash)
+	}
+	return b.m.LoadAndDelete(key)
+}
+
+func (b *bucket) compareAndSwap(key interface{}, hash uintptr, old, new interface{}) bool {
+	if b.s != nil {
+		return b.s.compareAndSwap(key, hash, old, new)
+	}
+	return b.m.CompareAndSwap(key, old, new)
+}
+
+func (b *bucket) compareAndDelete(key interface{}, hash uintptr, old interface{}) bool {
+	if b.s != nil {
+		return b.s.compareAndDelete(key, hash, old)
+	}
+	return b.m.CompareAndDelete(key, old)
+}
+
+func (b *bucket) reserve(n int) {
+	if b.s != nil {
+		b.s.reserve(n)
+		return
+	}
+	b.m.reserve(n)
+}
+
+// rangeHash calls f with every entry of b, and the hash of its key.
+func (b *bucket) rangeHash(f func(key, value interface{}, hash uintptr) bool) bool {
+	if b.s != nil {
+		return b.s.rangeHash(f)
+	}
+	return b.m.rangeHash(f)
+}
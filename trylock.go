@@ -0,0 +1,133 @@
+//go:build go1.18
+
+package cmap
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+// TryStore is Store, but fails and returns false instead of waiting when
+// the bucket of key is busy: another writer holds it, or DoAtomic, Update
+// or a resize hold it exclusively. It is meant for the writes which can
+// be skipped under contention, like filling a cache.
+//
+// TryStore also returns false if m is closed, rejects key, see
+// WithNilKeys, or is full, see WithMaxEntries.
+func (m *CMap) TryStore(key, value interface{}) bool {
+	if m.checkOpen() != nil || m.checkKey(key) != nil {
+		return false
+	}
+	hash, raw := m.hash(key), m.wrap(value, 0, 0)
+	for {
+		_, b := m.getNodeAndBucket(hash)
+		done, stored := b.tryStoreNoWait(m, hash, key, raw)
+		if done {
+			if stored {
+				m.stored(key, raw)
+			}
+			return stored
+		}
+		runtime.Gosched()
+	}
+}
+
+// TryLoad is Load, but acquired is false, and ok too, if it would have to
+// wait for a lock. Load only waits on a miss in a bucket whose latest keys
+// are not published to its read-only part yet, see Map; swiss buckets
+// never make it wait.
+func (m *CMap) TryLoad(key interface{}) (value interface{}, ok, acquired bool) {
+	if m.panicClosed {
+		m.checkOpen()
+	}
+	hash := m.hash(key)
+	b := m.getNode().readBucket(hash)
+	if m.keyFilter != nil && !m.keyFilter.mayContain(hash) {
+		return nil, false, true
+	}
+	if b.s != nil {
+		value, ok = b.s.load(key, hash)
+	} else if value, ok, acquired = b.m.tryLoad(key); !acquired {
+		return nil, false, false
+	}
+	if ok {
+		value, ok = access(value)
+	}
+	return value, ok, true
+}
+
+// lockNoWait is lock, but acquired is false instead of waiting if b is
+// locked.
+func (b *bucket) lockNoWait(m *CMap, hash uintptr) (n *node, ok, acquired bool) {
+	if !b.mu.TryLock() {
+		return nil, false, false
+	}
+	n = m.getNode()
+	if n.getBucket(hash) != b {
+		debugFrozen(b)
+		b.mu.Unlock()
+		return nil, false, true
+	}
+	if atomic.LoadUint32(&b.shared) != 0 {
+		b.mu.Unlock()
+		n.unshare(hash, b)
+		return nil, false, true
+	}
+	atomic.AddUint64(&b.writes, 1)
+	return n, true, true
+}
+
+// tryStoreNoWait is tryStore with b locked by lockNoWait, stored is false
+// if b is locked or m is full. b is locked exclusively, so that the lock
+// of its table is free as well. It leaves assisting a resize to the other
+// writers, since evacuating waits for the locks of the old buckets.
+func (b *bucket) tryStoreNoWait(m *CMap, hash uintptr, key, value interface{}) (done, stored bool) {
+	n, ok, acquired := b.lockNoWait(m, hash)
+	if !ok {
+		return !acquired, false
+	}
+	prev, loaded, old, err := b.loadOrStoreLocked(m, hash, key, value)
+	if err != nil {
+		b.mu.Unlock()
+		return true, false
+	}
+	if loaded {
+		b.store(key, value, hash)
+	}
+	if m.capturing() {
+		if loaded {
+			m.recordStore(key, prev, true, value)
+		} else {
+			m.recordStore(key, old, old != nil, value)
+		}
+	}
+	b.mu.Unlock()
+	if loaded {
+		m.weigh(key, prev, -1)
+	}
+	m.inserted(n, b, key, loaded, old)
+	return true, true
+}
+
+// tryLoad is Load, but acquired is false instead of waiting for m.mu.
+func (m *Map) tryLoad(key interface{}) (value interface{}, ok, acquired bool) {
+	read, _ := m.read.Load().(readOnly)
+	e, ok := read.m[key]
+	if !ok && read.amended {
+		if !m.mu.TryLock() {
+			return nil, false, false
+		}
+		read, _ = m.read.Load().(readOnly)
+		e, ok = read.m[key]
+		if !ok && read.amended {
+			e, ok = m.dirty[key]
+			m.missLocked()
+		}
+		m.mu.Unlock()
+	}
+	if !ok {
+		return nil, false, true
+	}
+	value, ok = e.load()
+	return value, ok, true
+}
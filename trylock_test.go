@@ -0,0 +1,72 @@
+//go:build go1.18
+
+package cmap_test
+
+import (
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestTryStore(t *testing.T) {
+	for _, swiss := range []bool{false, true} {
+		var opts []cmap.Option
+		if swiss {
+			opts = append(opts, cmap.WithSwissBuckets())
+		}
+		m := cmap.New(opts...)
+		if !m.TryStore("a", 1) {
+			t.Fatal("TryStore failed on an idle map")
+		}
+		if v, ok, acquired := m.TryLoad("a"); !acquired || !ok || v != 1 {
+			t.Fatalf("TryLoad(a) = %v, %v, %v; want 1, true, true", v, ok, acquired)
+		}
+		if _, ok, acquired := m.TryLoad("b"); !acquired || ok {
+			t.Fatalf("TryLoad(b) = %v, %v; want false, true", ok, acquired)
+		}
+
+		// Update holds the bucket of a while f runs
+		m.Update("a", func(v interface{}, _ bool) (interface{}, bool) {
+			if m.TryStore("a", 2) {
+				t.Error("TryStore succeeded on a locked bucket")
+			}
+			if v, ok, acquired := m.TryLoad("a"); !acquired || !ok || v != 1 {
+				t.Errorf("TryLoad(a) = %v, %v, %v in Update; want 1, true, true", v, ok, acquired)
+			}
+			return 3, false
+		})
+		if v, _ := m.Load("a"); v != 3 {
+			t.Fatalf("Load(a) = %v, want 3", v)
+		}
+
+		for i := 0; i < 1000; i++ {
+			if !m.TryStore(i, i) {
+				t.Fatalf("TryStore(%d) failed without contention", i)
+			}
+		}
+		if n := m.Len(); n != 1001 {
+			t.Fatalf("Len() = %d, want 1001", n)
+		}
+	}
+}
+
+func TestTryStoreNilKeys(t *testing.T) {
+	r := cmap.New(cmap.WithNilKeys(cmap.NilKeysRejected))
+	if r.TryStore(nil, 1) {
+		t.Fatal("TryStore(nil) = true with NilKeysRejected")
+	}
+	if _, ok := r.Load(nil); ok {
+		t.Fatal("TryStore stored a nil key")
+	}
+	if !r.TryStore(1, 1) {
+		t.Fatal("TryStore(1) = false")
+	}
+
+	p := cmap.New(cmap.WithNilKeys(cmap.NilKeysPanic))
+	defer func() {
+		if r := recover(); r != cmap.ErrNilKey {
+			t.Errorf("TryStore(nil) recovered %v, want ErrNilKey", r)
+		}
+	}()
+	p.TryStore(nil, 1)
+}
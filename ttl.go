@@ -0,0 +1,273 @@
+package cmap
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// NoExpiration is the ttl reported by GetTTL for keys stored without one.
+const NoExpiration time.Duration = -1
+
+// expiring is the value kept in a bucket for a key stored with a ttl, or
+// with metadata, see WithEntryMeta and WithVersions.
+type expiring struct {
+	value    interface{}
+	deadline int64  // nanotime, 0 if the key never expires, changed atomically
+	idle     int64  // ns the deadline is pushed back to by loads, 0 for a fixed deadline
+	created  int64  // nanotime, 0 if not tracked
+	accessed int64  // nanotime, changed atomically by loads
+	version  uint64 // 0 if not tracked
+}
+
+func (e *expiring) expired(now int64) bool {
+	deadline := atomic.LoadInt64(&e.deadline)
+	return deadline != 0 && now >= deadline
+}
+
+// touch records an access of e at now.
+func (e *expiring) touch(now int64) {
+	if e.idle != 0 {
+		atomic.StoreInt64(&e.deadline, now+e.idle)
+	}
+	if e.created != 0 {
+		atomic.StoreInt64(&e.accessed, now)
+	}
+}
+
+// with returns a copy of e holding value.
+func (e *expiring) with(value interface{}) *expiring {
+	return &expiring{
+		value:    value,
+		deadline: atomic.LoadInt64(&e.deadline),
+		idle:     e.idle,
+		created:  e.created,
+		accessed: atomic.LoadInt64(&e.accessed),
+		version:  e.version,
+	}
+}
+
+// wrap returns the value kept in a bucket for value, expiring at deadline
+// unless it is zero, and pushed back by idle on every load unless it is
+// zero.
+func (m *CMap) wrap(value interface{}, deadline, idle int64) interface{} {
+	if !m.meta && !m.versioned {
+		if deadline == 0 {
+			return value
+		}
+		return &expiring{value: value, deadline: deadline, idle: idle}
+	}
+	e := &expiring{value: value, deadline: deadline, idle: idle}
+	if m.meta {
+		now := nanotime()
+		e.created, e.accessed = now, now
+	}
+	if m.versioned {
+		e.version = atomic.AddUint64(&m.version, 1)
+	}
+	return e
+}
+
+// unwrap returns the user value of v, ok is false if v has expired.
+func unwrap(v interface{}) (value interface{}, ok bool) {
+	if e, isExp := v.(*expiring); isExp {
+		if e.expired(nanotime()) {
+			return nil, false
+		}
+		return e.value, true
+	}
+	return v, true
+}
+
+// isExpired reports whether v is an expired value.
+func isExpired(v interface{}, now int64) bool {
+	e, ok := v.(*expiring)
+	return ok && e.expired(now)
+}
+
+// start is the origin of nanotime, a second before the package was
+// initialized so that nanotime is never 0, the time of no deadline.
+var start = time.Now().Add(-time.Second)
+
+// nanotime returns the time in ns since start, read on the monotonic
+// clock, so that the deadlines don't move with the wall clock. The times
+// leave the map in unix ns, see unixNano.
+func nanotime() int64 {
+	return int64(time.Since(start))
+}
+
+// unixNano returns the time t of nanotime in unix ns, 0 for 0.
+func unixNano(t int64) int64 {
+	if t == 0 {
+		return 0
+	}
+	return start.UnixNano() + t
+}
+
+// fromUnixNano returns the time of nanotime of u in unix ns, 0 for 0.
+func fromUnixNano(u int64) int64 {
+	if u == 0 {
+		return 0
+	}
+	return u - start.UnixNano()
+}
+
+// StoreWithTTL sets the value for a key, which expires after d.
+// An expired key is never returned, and is removed by DeleteExpired or
+// the janitor started with WithJanitor.
+//
+// If d <= 0, StoreWithTTL is the same as Store.
+func (m *CMap) StoreWithTTL(key, value interface{}, d time.Duration) {
+	if d <= 0 {
+		m.Store(key, value)
+		return
+	}
+	if m.checkOpen() != nil || m.checkKey(key) != nil {
+		return
+	}
+	hash, raw := m.hash(key), m.wrap(value, nanotime()+int64(d), 0)
+	m.store(hash, key, raw)
+	m.schedule(key, hash, raw)
+}
+
+// StoreWithIdleTTL sets the value for a key, which expires once it was
+// not loaded for d: Load, LoadMeta and Touch push its deadline back to d
+// from now, while Range and the other reads leave it unchanged. Update
+// keeps the idle ttl of the key, and counts as a load.
+//
+// If d <= 0, StoreWithIdleTTL is the same as Store.
+func (m *CMap) StoreWithIdleTTL(key, value interface{}, d time.Duration) {
+	if d <= 0 {
+		m.Store(key, value)
+		return
+	}
+	if m.checkOpen() != nil || m.checkKey(key) != nil {
+		return
+	}
+	hash, raw := m.hash(key), m.wrap(value, nanotime()+int64(d), int64(d))
+	m.store(hash, key, raw)
+	m.schedule(key, hash, raw)
+}
+
+// Touch counts as a load of key without returning its value, pushing back
+// the deadline of a key stored with StoreWithIdleTTL. It reports whether
+// the key is present.
+func (m *CMap) Touch(key interface{}) bool {
+	hash := m.hash(key)
+	raw, ok := m.getNode().readBucket(hash).load(key, hash)
+	if ok {
+		_, ok = access(raw)
+	}
+	return ok
+}
+
+// GetTTL returns the remaining time to live of a key,
+// or NoExpiration if the key was stored without ttl.
+// The ok result indicates whether key was found in the map.
+func (m *CMap) GetTTL(key interface{}) (ttl time.Duration, ok bool) {
+	hash := m.hash(key)
+	_, b := m.getNodeAndBucket(hash)
+	v, ok := b.load(key, hash)
+	if !ok {
+		return 0, false
+	}
+	e, isExp := v.(*expiring)
+	if !isExp || atomic.LoadInt64(&e.deadline) == 0 {
+		return NoExpiration, true
+	}
+	ttl = time.Duration(atomic.LoadInt64(&e.deadline) - nanotime())
+	if ttl <= 0 {
+		return 0, false
+	}
+	return ttl, true
+}
+
+// DeleteExpired deletes all expired entries, one bucket at a time.
+func (m *CMap) DeleteExpired() {
+	now := nanotime()
+	n := m.getNode()
+	for i := uintptr(0); i <= n.mask; {
+		b := n.loadBucket(i)
+		if atomic.LoadUint32(&b.shared) != 0 && !b.hasExpired(now) {
+			// not copied from a Fork for nothing
+			i++
+			continue
+		}
+		if b.deleteExpired(m, i, now) {
+			i++
+		} else if cur := m.getNode(); cur != n {
+			// resized meanwhile, start over
+			n, i = cur, 0
+		}
+	}
+	m.checkShrink(n)
+}
+
+// hasExpired reports whether b holds an expired entry.
+func (b *bucket) hasExpired(now int64) bool {
+	return !b.rangeHash(func(_, value interface{}, _ uintptr) bool {
+		return !isExpired(value, now)
+	})
+}
+
+// deleteExpired deletes the expired entries of b, the bucket i of the
+// current node. It returns false if b is no longer current, or was
+// shared with a Fork: the caller retries with the bucket in its place.
+func (b *bucket) deleteExpired(m *CMap, i uintptr, now int64) bool {
+	if _, ok := b.lock(m, i); !ok {
+		return false
+	}
+	var evicted []Entry
+	capture := m.capturing()
+	b.rangeHash(func(key, value interface{}, hash uintptr) bool {
+		if isExpired(value, now) && b.compareAndDelete(key, hash, value) {
+			atomic.AddInt64(&b.count, -1)
+			m.dropKey(hash)
+			evicted = append(evicted, Entry{key, value.(*expiring).value})
+			if capture {
+				m.record(ChangeExpire, key, value.(*expiring).value, nil)
+			}
+		}
+		return true
+	})
+	b.mu.Unlock()
+	for _, e := range evicted {
+		m.evicted(e.Key, e.Value)
+	}
+	return true
+}
+
+// janitor periodically deletes expired entries of a CMap.
+type janitor struct {
+	interval time.Duration
+	wheels   *wheels // the keys to expire
+	once     sync.Once
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func (j *janitor) run(m *CMap) {
+	j.done = make(chan struct{})
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.expireDue(j.wheels)
+			case <-j.done:
+				return
+			}
+		}
+	}()
+}
+
+func (j *janitor) stop() {
+	j.once.Do(func() {
+		close(j.done)
+		j.wg.Wait()
+		j.wheels.clear()
+	})
+}
@@ -0,0 +1,186 @@
+package cmap_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/min1324/cmap"
+)
+
+func TestStoreWithTTL(t *testing.T) {
+	var m cmap.CMap
+	m.StoreWithTTL("a", 1, time.Millisecond)
+	m.StoreWithTTL("b", 2, time.Hour)
+	m.Store("c", 3)
+
+	if v, ok := m.Load("a"); !ok || v != 1 {
+		t.Fatalf("Load(a) = %v, %v; want 1, true", v, ok)
+	}
+	if ttl, ok := m.GetTTL("b"); !ok || ttl <= 0 || ttl > time.Hour {
+		t.Fatalf("GetTTL(b) = %v, %v", ttl, ok)
+	}
+	if ttl, ok := m.GetTTL("c"); !ok || ttl != cmap.NoExpiration {
+		t.Fatalf("GetTTL(c) = %v, %v; want NoExpiration, true", ttl, ok)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := m.Load("a"); ok {
+		t.Fatalf("Load(a) found an expired key")
+	}
+	if _, ok := m.GetTTL("a"); ok {
+		t.Fatalf("GetTTL(a) found an expired key")
+	}
+	if v, loaded := m.LoadOrStore("a", 4); loaded || v != 4 {
+		t.Fatalf("LoadOrStore(a) = %v, %v; want 4, false", v, loaded)
+	}
+	m.Range(func(key, value interface{}) bool {
+		if key == "a" && value != 4 {
+			t.Fatalf("Range saw a = %v", value)
+		}
+		return true
+	})
+}
+
+func TestDeleteExpired(t *testing.T) {
+	m := cmap.New(cmap.WithJanitor(time.Millisecond))
+	defer m.Close()
+	for i := 0; i < 100; i++ {
+		m.StoreWithTTL(i, i, time.Millisecond)
+	}
+	m.Store(-1, -1)
+
+	deadline := time.Now().Add(time.Second)
+	for m.Count() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("janitor left %d entries, want 1", m.Count())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, ok := m.Load(-1); !ok {
+		t.Fatalf("janitor deleted a key without ttl")
+	}
+	m.Close()
+}
+
+func TestDeleteExpiredResizing(t *testing.T) {
+	m := cmap.New(cmap.WithMaxShardBits(6))
+	for i := 0; i < 1000; i++ {
+		m.StoreWithTTL(i, i, time.Millisecond)
+	}
+	f := m.Fork()
+	m.Store(-1, -1)
+	time.Sleep(2 * time.Millisecond)
+	// the buckets change under DeleteExpired, which retries them
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			m.ForceResize(uint8(2 + i%4))
+			m.Compact()
+		}
+	}()
+	m.DeleteExpired()
+	<-done
+	m.DeleteExpired()
+	if n := m.Count(); n != 1 {
+		t.Fatalf("Count() = %d after DeleteExpired, want 1", n)
+	}
+	if n := f.Count(); n != 1000 {
+		t.Fatalf("the fork counts %d entries after DeleteExpired of the map, want 1000", n)
+	}
+}
+
+func TestStoreWithIdleTTL(t *testing.T) {
+	var m cmap.CMap
+	m.StoreWithIdleTTL("loaded", 1, 50*time.Millisecond)
+	m.StoreWithIdleTTL("touched", 2, 50*time.Millisecond)
+	m.StoreWithIdleTTL("idle", 3, 50*time.Millisecond)
+	m.StoreWithTTL("fixed", 4, 50*time.Millisecond)
+
+	for i := 0; i < 6; i++ {
+		time.Sleep(20 * time.Millisecond)
+		if _, ok := m.Load("loaded"); !ok {
+			t.Fatalf("key loaded every 20ms expired after %d loads", i)
+		}
+		if !m.Touch("touched") {
+			t.Fatalf("key touched every 20ms expired after %d touches", i)
+		}
+		m.Load("fixed")
+	}
+	if _, ok := m.Load("idle"); ok {
+		t.Fatalf("idle key not expired")
+	}
+	if _, ok := m.Load("fixed"); ok {
+		t.Fatalf("key with a fixed ttl pushed back by loads")
+	}
+	if m.Touch("absent") {
+		t.Fatalf("Touch reported an absent key")
+	}
+
+	// Update keeps the idle ttl
+	m.Update("loaded", func(v interface{}, _ bool) (interface{}, bool) { return 10, false })
+	if ttl, ok := m.GetTTL("loaded"); !ok || ttl <= 0 || ttl > 50*time.Millisecond {
+		t.Fatalf("GetTTL after Update = %v, %v, want the idle ttl", ttl, ok)
+	}
+}
+
+func TestJanitorWheels(t *testing.T) {
+	m := cmap.New(cmap.WithJanitor(time.Millisecond))
+	defer m.Close()
+	var evicted int32
+	m.OnEvict(func(key, _ interface{}) {
+		if _, ok := key.(int); ok {
+			atomic.AddInt32(&evicted, 1)
+		}
+	})
+	// ttls spanning several levels of the wheels
+	start := time.Now()
+	for i := 0; i < 200; i++ {
+		m.StoreWithTTL(i, i, time.Duration(i+1)*time.Millisecond)
+	}
+	const idle = 20 * time.Millisecond
+	seen := time.Now() // before the last Load which saw "idle"
+	m.StoreWithIdleTTL("idle", 0, idle)
+	m.StoreWithTTL("replaced", 0, 10*time.Millisecond)
+	m.Store("replaced", 1)
+
+	idleGone := false
+	for atomic.LoadInt32(&evicted) < 200 {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("janitor evicted %d keys, want 200", atomic.LoadInt32(&evicted))
+		}
+		// no key goes before its ttl, counted from start, before it was
+		// stored, to right after the Load which missed it
+		for i := 0; i < 200; i++ {
+			if _, ok := m.Load(i); !ok {
+				if elapsed := time.Since(start); elapsed < time.Duration(i+1)*time.Millisecond {
+					t.Fatalf("key with a ttl of %dms gone after %v", i+1, elapsed)
+				}
+			}
+		}
+		if !idleGone {
+			before := time.Now()
+			if _, ok := m.Load("idle"); ok {
+				seen = before
+			} else if since := time.Since(seen); since < idle {
+				t.Fatalf("idle key gone %v after it was loaded, want %v", since, idle)
+			} else {
+				// the test was not scheduled for an idle ttl
+				idleGone = true
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, ok := m.Load("replaced"); !ok {
+		t.Fatalf("janitor deleted a key stored again without ttl")
+	}
+	want := 2
+	if idleGone {
+		m.Delete("idle") // maybe not removed by the janitor yet
+		want = 1
+	}
+	if n := m.Len(); n != want {
+		t.Fatalf("Len() = %d after the janitor, want %d", n, want)
+	}
+}
@@ -0,0 +1,255 @@
+package cmap
+
+import (
+	"errors"
+	"runtime"
+	"sort"
+	"sync/atomic"
+)
+
+// ErrKeyNotLocked is the panic value of a TxView used with a key not
+// passed to DoAtomic.
+var ErrKeyNotLocked = errors.New("cmap: key not locked by DoAtomic")
+
+// TxView reads and writes the keys of a DoAtomic transaction.
+type TxView interface {
+	// Get returns the value of key, including the writes of the
+	// transaction.
+	Get(key interface{}) (value interface{}, ok bool)
+	// Set sets the value of key.
+	Set(key, value interface{})
+	// Delete deletes key.
+	Delete(key interface{})
+}
+
+// DoAtomic calls fn with a view of keys, whose buckets are locked while fn
+// is running, in the order of their index so that concurrent calls never
+// deadlock. The writes of fn are applied at once if it returns nil, and
+// discarded otherwise, and DoAtomic returns the error of fn.
+//
+// fn must only use the keys given to DoAtomic, and must not use the map.
+// Callbacks, watchers and persister are called after the buckets are
+// unlocked. DoAtomic returns ErrNilKey without calling fn if keys has a
+// nil key the map rejects, see WithNilKeys, and ErrFull without applying
+// the writes of fn if they would go past WithMaxEntries.
+func (m *CMap) DoAtomic(keys []interface{}, fn func(view TxView) error) error {
+	if err := m.checkOpen(); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := m.checkKey(k); err != nil {
+			return err
+		}
+	}
+	tx := &txView{
+		hashes: make(map[interface{}]uintptr, len(keys)),
+		writes: make(map[interface{}]txWrite, len(keys)),
+	}
+	for _, k := range keys {
+		tx.hashes[k] = m.hash(k)
+	}
+	for {
+		n, locked, ok := m.lockKeys(tx.hashes)
+		if !ok {
+			runtime.Gosched()
+			continue
+		}
+		tx.n, tx.buckets = n, locked
+		done, err := tx.run(m, fn)
+		for _, w := range done {
+			m.txDone(n, w)
+		}
+		return err
+	}
+}
+
+// run calls fn and applies its writes, unlocking the buckets of tx even
+// if fn panics.
+func (tx *txView) run(m *CMap, fn func(view TxView) error) (done []txWrite, err error) {
+	defer func() {
+		for _, b := range tx.buckets {
+			b.mu.Unlock()
+		}
+	}()
+	if err = fn(tx); err != nil {
+		return nil, err
+	}
+	return tx.apply(m)
+}
+
+// lockKeys locks the buckets of hashes, in the order of their index. ok
+// is false if one of them is no longer current.
+func (m *CMap) lockKeys(hashes map[interface{}]uintptr) (n *node, locked map[uintptr]*bucket, ok bool) {
+	n = m.getNode()
+	idx := make([]uintptr, 0, len(hashes))
+	locked = make(map[uintptr]*bucket, len(hashes))
+	for _, h := range hashes {
+		i := h & n.mask
+		if _, dup := locked[i]; !dup {
+			locked[i] = n.loadBucket(i)
+			idx = append(idx, i)
+		}
+	}
+	sort.Slice(idx, func(a, b int) bool { return idx[a] < idx[b] })
+	for _, i := range idx {
+		locked[i].mu.Lock()
+	}
+	cur := m.getNode()
+	for _, i := range idx {
+		if cur.getBucket(i) != locked[i] || cur != n {
+			for _, i := range idx {
+				locked[i].mu.Unlock()
+			}
+			return nil, nil, false
+		}
+	}
+	for _, i := range idx {
+		if atomic.LoadUint32(&locked[i].shared) != 0 {
+			for _, i := range idx {
+				locked[i].mu.Unlock()
+			}
+			for _, i := range idx {
+				if b := locked[i]; atomic.LoadUint32(&b.shared) != 0 {
+					n.unshare(i, b)
+				}
+			}
+			return nil, nil, false
+		}
+	}
+	return n, locked, true
+}
+
+type txWrite struct {
+	key, value interface{}
+	del        bool
+
+	// set by apply
+	b       *bucket
+	present bool
+	old     interface{} // the value replaced, for callbacks
+	expired bool
+}
+
+type txView struct {
+	n       *node
+	hashes  map[interface{}]uintptr
+	buckets map[uintptr]*bucket // by index in the node
+	writes  map[interface{}]txWrite
+}
+
+// bucket returns the locked bucket of key.
+func (tx *txView) bucket(key interface{}) *bucket {
+	h, ok := tx.hashes[key]
+	if !ok {
+		panic(ErrKeyNotLocked)
+	}
+	return tx.buckets[h&tx.n.mask]
+}
+
+func (tx *txView) Get(key interface{}) (value interface{}, ok bool) {
+	b := tx.bucket(key)
+	if w, ok := tx.writes[key]; ok {
+		return w.value, !w.del
+	}
+	return b.tryLoad(key, tx.hashes[key])
+}
+
+func (tx *txView) Set(key, value interface{}) {
+	tx.bucket(key)
+	tx.writes[key] = txWrite{key: key, value: value}
+}
+
+func (tx *txView) Delete(key interface{}) {
+	tx.bucket(key)
+	tx.writes[key] = txWrite{key: key, del: true}
+}
+
+// apply applies the writes of tx to its locked buckets, or none of them
+// if they would store more entries than m allows.
+func (tx *txView) apply(m *CMap) ([]txWrite, error) {
+	done := make([]txWrite, 0, len(tx.writes))
+	now := nanotime()
+	var added int64
+	for _, w := range tx.writes {
+		b := tx.bucket(w.key)
+		raw, present := b.load(w.key, tx.hashes[w.key])
+		w.b, w.present, w.old = b, present, raw
+		if e, ok := raw.(*expiring); ok {
+			w.old, w.expired = e.value, e.expired(now)
+		}
+		switch {
+		case w.del && present:
+			added--
+		case !w.del && !present:
+			added++
+		}
+		done = append(done, w)
+	}
+	if !m.admit(added) {
+		return nil, ErrFull
+	}
+	m.release(-added)
+	capture := m.capturing()
+	for _, w := range done {
+		if capture {
+			m.recordTx(w)
+		}
+		b := w.b
+		if w.del {
+			if w.present {
+				b.loadAndDelete(w.key, tx.hashes[w.key])
+				atomic.AddInt64(&b.count, -1)
+				if m.keyFilter != nil {
+					m.keyFilter.remove(tx.hashes[w.key])
+				}
+			}
+		} else {
+			if !w.present && m.keyFilter != nil {
+				m.keyFilter.add(tx.hashes[w.key])
+			}
+			b.store(w.key, m.wrap(w.value, 0, 0), tx.hashes[w.key])
+			if !w.present {
+				atomic.AddInt64(&b.count, 1)
+			}
+		}
+	}
+	return done, nil
+}
+
+// recordTx records a write of DoAtomic, see Changes.
+func (m *CMap) recordTx(w txWrite) {
+	if w.present && w.expired {
+		m.record(ChangeExpire, w.key, w.old, nil)
+	}
+	switch {
+	case w.del && w.present && !w.expired:
+		m.record(ChangeDelete, w.key, w.old, nil)
+	case w.del:
+	case w.present && !w.expired:
+		m.record(ChangeUpdate, w.key, w.old, w.value)
+	default:
+		m.record(ChangeInsert, w.key, nil, w.value)
+	}
+}
+
+// txDone runs the callbacks of a write applied by DoAtomic.
+func (m *CMap) txDone(n *node, w txWrite) {
+	if w.present && w.expired {
+		m.evicted(w.key, w.old)
+	} else if w.present && w.del {
+		m.deleted(w.key, w.old)
+	} else if w.present {
+		m.weigh(w.key, w.old, -1)
+	}
+	if w.del {
+		if w.present {
+			m.removed(n, w.b)
+		}
+	} else {
+		if !w.present {
+			m.inserted(n, w.b, w.key, false, nil)
+		}
+		m.stored(w.key, w.value)
+	}
+	n.assist()
+}
@@ -0,0 +1,97 @@
+package cmap_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestDoAtomic(t *testing.T) {
+	var m cmap.CMap
+	const accounts = 20
+	for i := 0; i < accounts; i++ {
+		m.Store(i, 100)
+	}
+
+	// concurrent transfers keep the total unchanged
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				from, to := (g+i)%accounts, (g*7+i*3+1)%accounts
+				if from == to {
+					continue
+				}
+				m.DoAtomic([]interface{}{from, to}, func(tx cmap.TxView) error {
+					a, _ := tx.Get(from)
+					b, _ := tx.Get(to)
+					tx.Set(from, a.(int)-1)
+					tx.Set(to, b.(int)+1)
+					return nil
+				})
+			}
+		}(g)
+	}
+	wg.Wait()
+	total := 0
+	m.Range(func(key, value interface{}) bool {
+		total += value.(int)
+		return true
+	})
+	if total != accounts*100 {
+		t.Fatalf("total = %d, want %d", total, accounts*100)
+	}
+
+	// move a value, and discard the writes of a failed transaction
+	errAbort := errors.New("abort")
+	err := m.DoAtomic([]interface{}{0, "new"}, func(tx cmap.TxView) error {
+		v, _ := tx.Get(0)
+		tx.Delete(0)
+		tx.Set("new", v)
+		if _, ok := tx.Get(0); ok {
+			t.Errorf("Get sees a deleted key")
+		}
+		return errAbort
+	})
+	if _, ok := m.Load(0); err != errAbort || !ok || m.Len() != accounts {
+		t.Fatalf("DoAtomic applied a failed transaction")
+	}
+	m.DoAtomic([]interface{}{0, "new"}, func(tx cmap.TxView) error {
+		v, _ := tx.Get(0)
+		tx.Delete(0)
+		tx.Set("new", v)
+		return nil
+	})
+	if _, ok := m.Load(0); ok || m.Len() != accounts {
+		t.Fatalf("DoAtomic did not move the value")
+	}
+
+	defer func() {
+		if r := recover(); r != cmap.ErrKeyNotLocked {
+			t.Fatalf("recover() = %v, want ErrKeyNotLocked", r)
+		}
+	}()
+	m.DoAtomic([]interface{}{1}, func(tx cmap.TxView) error {
+		tx.Get(2)
+		return nil
+	})
+}
+
+func TestDoAtomicPanic(t *testing.T) {
+	var m cmap.CMap
+	func() {
+		defer func() { recover() }()
+		m.DoAtomic([]interface{}{1}, func(tx cmap.TxView) error {
+			panic("fn")
+		})
+	}()
+	// the bucket of 1 must be unlocked
+	m.Store(1, 1)
+	if v, _ := m.Load(1); v != 1 {
+		t.Fatalf("Load(1) = %v", v)
+	}
+}
@@ -0,0 +1,149 @@
+package cmap
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// TypeError is the error of a typed load of a value which does not hold
+// the type asked for.
+type TypeError struct {
+	Key   interface{}
+	Value interface{}
+	Want  reflect.Type
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("cmap: value of key %v is %T, not %v", e.Key, e.Value, e.Want)
+}
+
+// LoadString returns the value of key as a string. The error is
+// ErrNotFound if key is absent, and a *TypeError if its value is not a
+// string.
+func (m *CMap) LoadString(key interface{}) (string, error) {
+	var s string
+	err := m.LoadAs(key, &s)
+	return s, err
+}
+
+// LoadInt returns the value of key as an int, converting the other
+// integer types if the value fits. The errors are those of LoadString.
+func (m *CMap) LoadInt(key interface{}) (int, error) {
+	var i int
+	err := m.LoadAs(key, &i)
+	return i, err
+}
+
+// LoadInt64 returns the value of key as an int64, converting the other
+// integer types if the value fits. The errors are those of LoadString.
+func (m *CMap) LoadInt64(key interface{}) (int64, error) {
+	var i int64
+	err := m.LoadAs(key, &i)
+	return i, err
+}
+
+// LoadFloat64 returns the value of key as a float64, converting a
+// float32. The errors are those of LoadString.
+func (m *CMap) LoadFloat64(key interface{}) (float64, error) {
+	var f float64
+	err := m.LoadAs(key, &f)
+	return f, err
+}
+
+// LoadBool returns the value of key as a bool. The errors are those of
+// LoadString.
+func (m *CMap) LoadBool(key interface{}) (bool, error) {
+	var b bool
+	err := m.LoadAs(key, &b)
+	return b, err
+}
+
+// LoadAs stores the value of key in the variable ptr points to. The value
+// must be assignable to the variable, or be an integer, or a float, which
+// converts to the type of the variable without loss.
+//
+// The error is ErrNotFound if key is absent, and a *TypeError if its
+// value does not fit in the variable. LoadAs panics if ptr is not a
+// non-nil pointer.
+func (m *CMap) LoadAs(key, ptr interface{}) error {
+	p := reflect.ValueOf(ptr)
+	if p.Kind() != reflect.Ptr || p.IsNil() {
+		panic(fmt.Sprintf("cmap: LoadAs of a %T, not a non-nil pointer", ptr))
+	}
+	value, ok := m.Load(key)
+	if !ok {
+		return ErrNotFound
+	}
+	dst := p.Elem()
+	if value != nil {
+		v := reflect.ValueOf(value)
+		if v.Type().AssignableTo(dst.Type()) {
+			dst.Set(v)
+			return nil
+		}
+		if c, ok := convertExact(v, dst.Type()); ok {
+			dst.Set(c)
+			return nil
+		}
+	} else if canBeNil(dst.Kind()) {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	return &TypeError{Key: key, Value: value, Want: dst.Type()}
+}
+
+// convertExact converts v to t if both are integers, or both are floats,
+// and the value of v is unchanged in t.
+func convertExact(v reflect.Value, t reflect.Type) (c reflect.Value, ok bool) {
+	c = reflect.New(t).Elem()
+	switch {
+	case isInt(v.Kind()) && isInt(t.Kind()):
+		i := v.Int()
+		if c.OverflowInt(i) {
+			return c, false
+		}
+		c.SetInt(i)
+	case isInt(v.Kind()) && isUint(t.Kind()):
+		i := v.Int()
+		if i < 0 || c.OverflowUint(uint64(i)) {
+			return c, false
+		}
+		c.SetUint(uint64(i))
+	case isUint(v.Kind()) && isInt(t.Kind()):
+		u := v.Uint()
+		if u > math.MaxInt64 || c.OverflowInt(int64(u)) {
+			return c, false
+		}
+		c.SetInt(int64(u))
+	case isUint(v.Kind()) && isUint(t.Kind()):
+		u := v.Uint()
+		if c.OverflowUint(u) {
+			return c, false
+		}
+		c.SetUint(u)
+	case isFloat(v.Kind()) && isFloat(t.Kind()):
+		f := v.Float()
+		c.SetFloat(f)
+		if c.Float() != f {
+			return c, false
+		}
+	default:
+		return c, false
+	}
+	return c, true
+}
+
+func isInt(k reflect.Kind) bool   { return k >= reflect.Int && k <= reflect.Int64 }
+func isUint(k reflect.Kind) bool  { return k >= reflect.Uint && k <= reflect.Uintptr }
+func isFloat(k reflect.Kind) bool { return k == reflect.Float32 || k == reflect.Float64 }
+
+// canBeNil reports whether a nil value can be assigned to a variable of
+// kind k.
+func canBeNil(k reflect.Kind) bool {
+	switch k {
+	case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice:
+		return true
+	}
+	return false
+}
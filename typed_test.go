@@ -0,0 +1,63 @@
+package cmap_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestLoadAs(t *testing.T) {
+	var m cmap.CMap
+	m.Store("s", "str")
+	m.Store("i8", int8(-3))
+	m.Store("big", uint64(1)<<40)
+	m.Store("f32", float32(1.5))
+	m.Store("nil", nil)
+
+	if s, err := m.LoadString("s"); err != nil || s != "str" {
+		t.Fatalf("LoadString(s) = %q, %v", s, err)
+	}
+	if i, err := m.LoadInt64("i8"); err != nil || i != -3 {
+		t.Fatalf("LoadInt64(i8) = %d, %v", i, err)
+	}
+	if f, err := m.LoadFloat64("f32"); err != nil || f != 1.5 {
+		t.Fatalf("LoadFloat64(f32) = %v, %v", f, err)
+	}
+	if _, err := m.LoadString("absent"); err != cmap.ErrNotFound {
+		t.Fatalf("LoadString(absent) error = %v, want ErrNotFound", err)
+	}
+
+	var te *cmap.TypeError
+	if _, err := m.LoadInt("s"); !errors.As(err, &te) || te.Key != "s" || te.Value != "str" {
+		t.Fatalf("LoadInt(s) error = %v, want a TypeError", err)
+	}
+	var u8 uint8
+	if err := m.LoadAs("i8", &u8); !errors.As(err, &te) {
+		t.Fatalf("LoadAs(i8, *uint8) error = %v, want a TypeError", err)
+	}
+	var i32 int32
+	if err := m.LoadAs("big", &i32); !errors.As(err, &te) {
+		t.Fatalf("LoadAs(big, *int32) error = %v, want a TypeError", err)
+	}
+	var u uint
+	if err := m.LoadAs("big", &u); err != nil || u != 1<<40 {
+		t.Fatalf("LoadAs(big, *uint) = %d, %v", u, err)
+	}
+	var st fmtStringer = stringer("x")
+	if err := m.LoadAs("nil", &st); err != nil || st != nil {
+		t.Fatalf("LoadAs(nil, *interface) = %v, %v", st, err)
+	}
+	m.Store("stringer", stringer("y"))
+	if err := m.LoadAs("stringer", &st); err != nil || st.String() != "y" {
+		t.Fatalf("LoadAs(stringer, *interface) = %v, %v", st, err)
+	}
+}
+
+type fmtStringer interface {
+	String() string
+}
+
+type stringer string
+
+func (s stringer) String() string { return string(s) }
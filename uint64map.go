@@ -0,0 +1,130 @@
+package cmap
+
+import "sync"
+
+// Uint64Map is a concurrent map of uint64 keys to uint64 values. Its
+// shards are plain map[uint64]uint64, which hold no pointer, so the
+// garbage collector never scans their content however large they grow.
+// Values wider than 64 bits can be kept in a slice indexed by the value.
+//
+// The zero Uint64Map is empty and ready for use. A Uint64Map must not be
+// copied after first use.
+type Uint64Map struct {
+	shards [1 << sBit]uint64Shard
+}
+
+type uint64Shard struct {
+	mu sync.RWMutex
+	m  map[uint64]uint64
+}
+
+func (m *Uint64Map) getShard(key uint64) *uint64Shard {
+	return &m.shards[mix(key)>>(64-sBit)]
+}
+
+// Load returns the value stored in the map for a key, or 0 if no
+// value is present.
+// The ok result indicates whether value was found in the map.
+func (m *Uint64Map) Load(key uint64) (value uint64, ok bool) {
+	s := m.getShard(key)
+	s.mu.RLock()
+	value, ok = s.m[key]
+	s.mu.RUnlock()
+	return value, ok
+}
+
+// Store sets the value for a key.
+func (m *Uint64Map) Store(key, value uint64) {
+	s := m.getShard(key)
+	s.mu.Lock()
+	if s.m == nil {
+		s.m = make(map[uint64]uint64)
+	}
+	s.m[key] = value
+	s.mu.Unlock()
+}
+
+// LoadOrStore returns the existing value for the key if present.
+// Otherwise, it stores and returns the given value.
+// The loaded result is true if the value was loaded, false if stored.
+func (m *Uint64Map) LoadOrStore(key, value uint64) (actual uint64, loaded bool) {
+	s := m.getShard(key)
+	s.mu.Lock()
+	actual, loaded = s.m[key]
+	if !loaded {
+		if s.m == nil {
+			s.m = make(map[uint64]uint64)
+		}
+		s.m[key] = value
+		actual = value
+	}
+	s.mu.Unlock()
+	return actual, loaded
+}
+
+// Add adds delta to the value of key, which starts at 0, and returns the
+// new value.
+func (m *Uint64Map) Add(key, delta uint64) uint64 {
+	s := m.getShard(key)
+	s.mu.Lock()
+	if s.m == nil {
+		s.m = make(map[uint64]uint64)
+	}
+	v := s.m[key] + delta
+	s.m[key] = v
+	s.mu.Unlock()
+	return v
+}
+
+// LoadAndDelete deletes the value for a key, returning the previous value if any.
+// The loaded result reports whether the key was present.
+func (m *Uint64Map) LoadAndDelete(key uint64) (value uint64, loaded bool) {
+	s := m.getShard(key)
+	s.mu.Lock()
+	value, loaded = s.m[key]
+	delete(s.m, key)
+	s.mu.Unlock()
+	return value, loaded
+}
+
+// Delete deletes the value for a key.
+func (m *Uint64Map) Delete(key uint64) {
+	m.LoadAndDelete(key)
+}
+
+// Len returns the number of elements within the map.
+func (m *Uint64Map) Len() int {
+	n := 0
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		n += len(s.m)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+// Range calls f sequentially for each key and value present in the map.
+// If f returns false, range stops the iteration.
+//
+// The entries of a shard are copied before f is called on them, so f may
+// use the map.
+func (m *Uint64Map) Range(f func(key, value uint64) bool) bool {
+	var keys, values []uint64
+	for i := range m.shards {
+		s := &m.shards[i]
+		keys, values = keys[:0], values[:0]
+		s.mu.RLock()
+		for k, v := range s.m {
+			keys = append(keys, k)
+			values = append(values, v)
+		}
+		s.mu.RUnlock()
+		for j, k := range keys {
+			if !f(k, values[j]) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,44 @@
+package cmap_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestUint64Map(t *testing.T) {
+	var m cmap.Uint64Map
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := uint64(0); i < 1000; i++ {
+				m.Add(i, i)
+			}
+		}()
+	}
+	wg.Wait()
+	if m.Len() != 1000 {
+		t.Fatalf("Len() = %d, want 1000", m.Len())
+	}
+	if v, ok := m.Load(10); !ok || v != 40 {
+		t.Fatalf("Load(10) = %d, %v", v, ok)
+	}
+	if v, loaded := m.LoadOrStore(10, 0); !loaded || v != 40 {
+		t.Fatalf("LoadOrStore(10) = %d, %v", v, loaded)
+	}
+	if v, loaded := m.LoadAndDelete(10); !loaded || v != 40 {
+		t.Fatalf("LoadAndDelete(10) = %d, %v", v, loaded)
+	}
+	m.Store(10, 1)
+	sum := uint64(0)
+	m.Range(func(key, value uint64) bool {
+		sum += value
+		return true
+	})
+	if want := uint64(4*999*1000/2 - 40 + 1); sum != want {
+		t.Fatalf("sum of values = %d, want %d", sum, want)
+	}
+}
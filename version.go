@@ -0,0 +1,49 @@
+package cmap
+
+// WithVersions gives each value stored in the map a version, as reported
+// by LoadVersioned, for optimistic writes with StoreIfVersion: the
+// versions come from a counter of the map, so a value never gets the
+// version of an earlier one, even of a deleted key. Like WithEntryMeta,
+// it costs an allocation per write.
+func WithVersions() Option {
+	return func(m *CMap) {
+		m.versioned = true
+	}
+}
+
+// LoadVersioned is like Load, but also returns the version of the value,
+// which is never 0. The version is 0 if the map was not created
+// WithVersions.
+func (m *CMap) LoadVersioned(key interface{}) (value interface{}, version uint64, ok bool) {
+	hash := m.hash(key)
+	raw, ok := m.getNode().readBucket(hash).load(key, hash)
+	if !ok {
+		return nil, 0, false
+	}
+	if e, _ := raw.(*expiring); e != nil {
+		version = e.version
+	}
+	if value, ok = access(raw); !ok {
+		return nil, 0, false
+	}
+	return value, version, true
+}
+
+// StoreIfVersion stores value for key if the version of its value is
+// still version, as returned by LoadVersioned, and reports whether it
+// did: a caller can load a value, compute a new one without holding any
+// lock, and store it unless the value changed meanwhile. A version of 0
+// stores value only if key is absent.
+//
+// Like Update, a ttl of the value is kept. In a map not created
+// WithVersions, StoreIfVersion only stores absent keys.
+func (m *CMap) StoreIfVersion(key, value interface{}, version uint64) (stored bool) {
+	m.computeVersion(key, func(_ interface{}, cur uint64, loaded bool) (interface{}, action) {
+		if loaded != (version != 0) || cur != version {
+			return nil, actKeep
+		}
+		stored = true
+		return value, actStore
+	})
+	return stored
+}
@@ -0,0 +1,63 @@
+package cmap_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestStoreIfVersion(t *testing.T) {
+	m := cmap.New(cmap.WithVersions())
+	if !m.StoreIfVersion("a", 1, 0) {
+		t.Fatalf("StoreIfVersion(a, 0) did not store an absent key")
+	}
+	v, ver, ok := m.LoadVersioned("a")
+	if !ok || v != 1 || ver == 0 {
+		t.Fatalf("LoadVersioned(a) = %v, %d, %v", v, ver, ok)
+	}
+	if m.StoreIfVersion("a", 2, 0) {
+		t.Fatalf("StoreIfVersion(a, 0) stored over a present key")
+	}
+	m.Store("a", 3)
+	if m.StoreIfVersion("a", 2, ver) {
+		t.Fatalf("StoreIfVersion stored with a stale version")
+	}
+	_, ver2, _ := m.LoadVersioned("a")
+	if ver2 <= ver {
+		t.Fatalf("version went from %d to %d", ver, ver2)
+	}
+	m.Delete("a")
+	m.Store("a", 4)
+	if _, ver3, _ := m.LoadVersioned("a"); ver3 <= ver2 {
+		t.Fatalf("version of a new value %d not above the one of a deleted value %d", ver3, ver2)
+	}
+
+	// optimistic increments
+	m.Store("n", 0)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				for {
+					v, ver, _ := m.LoadVersioned("n")
+					if m.StoreIfVersion("n", v.(int)+1, ver) {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _ := m.Load("n"); v != 800 {
+		t.Fatalf("n = %v after optimistic increments, want 800", v)
+	}
+
+	var plain cmap.CMap
+	plain.Store("a", 1)
+	if _, ver, _ := plain.LoadVersioned("a"); ver != 0 || plain.StoreIfVersion("a", 2, ver) {
+		t.Fatalf("a map without versions reported a version or stored over a key")
+	}
+}
@@ -0,0 +1,39 @@
+package cmap
+
+import "context"
+
+// LoadWait returns the value of key, waiting until another goroutine
+// stores one if key is absent. If ctx is done first, LoadWait returns
+// ctx.Err().
+//
+// If the map is closed meanwhile, LoadWait returns ErrClosed.
+//
+// LoadWait waits with Watch, so it costs nothing to the writers of the
+// map while no goroutine is waiting.
+func (m *CMap) LoadWait(ctx context.Context, key interface{}) (value interface{}, err error) {
+	if err := m.checkOpen(); err != nil {
+		return nil, err
+	}
+	if v, ok := m.Load(key); ok {
+		return v, nil
+	}
+	ch, cancel := m.Watch(key)
+	defer cancel()
+	// stored before the watch started
+	if v, ok := m.Load(key); ok {
+		return v, nil
+	}
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return nil, ErrClosed
+			}
+			if ev.Type == EventStore {
+				return ev.Value, nil
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package cmap_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/min1324/cmap"
+)
+
+func TestLoadWait(t *testing.T) {
+	var m cmap.CMap
+	m.Store("now", 1)
+	if v, err := m.LoadWait(context.Background(), "now"); err != nil || v != 1 {
+		t.Fatalf("LoadWait(now) = %v, %v", v, err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		m.Store("later", 2)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if v, err := m.LoadWait(ctx, "later"); err != nil || v != 2 {
+		t.Fatalf("LoadWait(later) = %v, %v", v, err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := m.LoadWait(ctx, "never"); err != context.DeadlineExceeded {
+		t.Fatalf("LoadWait(never) error = %v", err)
+	}
+}
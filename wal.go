@@ -0,0 +1,409 @@
+package cmap
+
+import (
+	"bufio"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// walQueue is the size of the queue of changes to log.
+	walQueue = 1024
+	// walCompactMin is the number of records logged before a compaction is
+	// considered, see wal.log.
+	walCompactMin = 4096
+)
+
+// walRecord is a change logged by the WAL.
+type walRecord struct {
+	Del bool
+	SnapshotEntry
+}
+
+// WithWAL makes the map log its writes and deletes to files in dir, from
+// a goroutine like WithPersister, so that Recover can rebuild the map
+// after a crash or a restart. The log is compacted into a snapshot, see
+// Snapshot, once it holds more records than the map has entries.
+//
+// The changes are recorded while the bucket of their key is locked, like
+// those of Changes, so that they are logged in the order they were made
+// and Recover ends with the values the map ended with; to record them in
+// order, the writers lock their bucket exclusively. The records reach the
+// files once the queue of changes is empty, and the disk on Flush, which
+// also returns the errors of the log. Keys with an idle ttl get back the
+// deadline of their last write. The keys and values are encoded like
+// Snapshot encodes them.
+//
+// New with WithWAL starts from an empty map, whose first compaction
+// removes the previous log of dir: use Recover to load it instead.
+func WithWAL(dir string) Option {
+	return func(m *CMap) {
+		w := &wal{m: m, dir: dir}
+		w.q = &persister{p: w, ops: make(chan persistOp, walQueue)}
+		m.wal = w
+	}
+}
+
+// Recover returns a map created with opts and WithWAL(dir), holding the
+// entries logged to dir by a previous map with WithWAL(dir): the last
+// snapshot of the log, and the records after it. A record torn by a crash
+// at the end of the log is ignored, and keys expired meanwhile are
+// skipped. Recover then compacts the log.
+func Recover(dir string, opts ...Option) (*CMap, error) {
+	m := New(append(opts[:len(opts):len(opts)], WithWAL(dir))...)
+	if err := m.wal.recover(); err != nil {
+		m.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// wal logs the changes of a CMap to the segments of a directory: files of
+// records named after their sequence number, and snapshots named after the
+// first segment they are followed by.
+type wal struct {
+	m   *CMap
+	q   *persister
+	dir string
+
+	mu      sync.Mutex // guards the segment written
+	seg     uint64     // sequence number of the segment written
+	f       *os.File   // segment written, nil until the first record
+	buf     *bufio.Writer
+	enc     *gob.Encoder
+	records int // records logged since the last compaction
+	live    int // Len at the last compaction
+
+	replaying  uint32 // 1 while Recover replays the log, which is not logged again
+	compacting uint32 // 1 while a compaction runs
+	wg         sync.WaitGroup
+	errMu      sync.Mutex
+	err        error // error of the last compaction
+}
+
+func (w *wal) OnStore(key, value interface{}) error {
+	return w.storeEntry(SnapshotEntry{Key: key, Value: value})
+}
+
+func (w *wal) storeEntry(e SnapshotEntry) error {
+	return w.log(walRecord{SnapshotEntry: e})
+}
+
+func (w *wal) OnDelete(key interface{}) error {
+	return w.log(walRecord{Del: true, SnapshotEntry: SnapshotEntry{Key: key}})
+}
+
+// Flush writes the records logged to the disk.
+func (w *wal) Flush(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return w.takeErr()
+	}
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	if err := w.f.Sync(); err != nil {
+		return err
+	}
+	return w.takeErr()
+}
+
+// log appends r to the segment written, from the persister goroutine.
+func (w *wal) log(r walRecord) error {
+	if atomic.LoadUint32(&w.replaying) == 1 {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+	if err := w.enc.Encode(&r); err != nil {
+		return err
+	}
+	w.records++
+	if len(w.q.ops) == 0 {
+		if err := w.buf.Flush(); err != nil {
+			return err
+		}
+	}
+	if w.records >= walCompactMin && w.records > w.live && atomic.CompareAndSwapUint32(&w.compacting, 0, 1) {
+		// the segments before the new one are in the map, which the
+		// compaction snapshots in the background.
+		if err := w.rotate(); err != nil {
+			atomic.StoreUint32(&w.compacting, 0)
+			return err
+		}
+		w.wg.Add(1)
+		go func(seg uint64) {
+			defer w.wg.Done()
+			defer atomic.StoreUint32(&w.compacting, 0)
+			if err := w.compact(seg); err != nil {
+				w.setErr(err)
+			}
+		}(w.seg)
+	}
+	return w.takeErr()
+}
+
+// open creates the segment after those of dir.
+func (w *wal) open() error {
+	if err := os.MkdirAll(w.dir, 0o755); err != nil {
+		return err
+	}
+	segs, snaps, err := w.list()
+	if err != nil {
+		return err
+	}
+	w.seg = 1
+	if n := len(segs); n > 0 && segs[n-1] >= w.seg {
+		w.seg = segs[n-1] + 1
+	}
+	if n := len(snaps); n > 0 && snaps[n-1] > w.seg {
+		w.seg = snaps[n-1]
+	}
+	return w.create()
+}
+
+// create creates the segment w.seg.
+func (w *wal) create() error {
+	f, err := os.OpenFile(w.path("wal", w.seg, ".log"), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	w.f, w.buf = f, bufio.NewWriter(f)
+	w.enc = gob.NewEncoder(w.buf)
+	w.records = 0
+	return nil
+}
+
+// rotate closes the segment written and creates the next one.
+func (w *wal) rotate() error {
+	if err := w.closeFile(); err != nil {
+		return err
+	}
+	w.seg++
+	return w.create()
+}
+
+func (w *wal) closeFile() error {
+	err := w.buf.Flush()
+	if serr := w.f.Sync(); err == nil {
+		err = serr
+	}
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	w.f = nil
+	return err
+}
+
+// compact writes a snapshot of the map followed by the segment seg, and
+// removes the segments and snapshots before it.
+func (w *wal) compact(seg uint64) error {
+	tmp := w.path("snapshot", seg, ".tmp")
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	err = w.m.Snapshot(bw)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, w.path("snapshot", seg, ".gob"))
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := syncDir(w.dir); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	w.live = w.m.Len()
+	w.mu.Unlock()
+
+	segs, snaps, err := w.list()
+	if err != nil {
+		return err
+	}
+	for _, n := range segs {
+		if n < seg {
+			if err := os.Remove(w.path("wal", n, ".log")); err != nil {
+				return err
+			}
+		}
+	}
+	for _, n := range snaps {
+		if n < seg {
+			if err := os.Remove(w.path("snapshot", n, ".gob")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// recover loads the log of dir into the map, and compacts it.
+func (w *wal) recover() error {
+	atomic.StoreUint32(&w.replaying, 1)
+	defer atomic.StoreUint32(&w.replaying, 0)
+	segs, snaps, err := w.list()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var from uint64
+	if n := len(snaps); n > 0 {
+		from = snaps[n-1]
+		if err := w.restore(from); err != nil {
+			return err
+		}
+	}
+	next := from
+	for i, n := range segs {
+		if n < from {
+			continue
+		}
+		if err := w.replay(n, i == len(segs)-1); err != nil {
+			return err
+		}
+		next = n + 1
+	}
+	if next == from {
+		return nil
+	}
+	// the changes of the replay, not logged, have to leave the queue
+	// before the snapshot replaces the log.
+	if err := w.q.flush(context.Background()); err != nil {
+		return err
+	}
+	return w.compact(next)
+}
+
+// restore loads the snapshot followed by the segment seg.
+func (w *wal) restore(seg uint64) error {
+	f, err := os.Open(w.path("snapshot", seg, ".gob"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return w.m.Restore(bufio.NewReader(f))
+}
+
+// replay applies the records of the segment seg. The last record of the
+// last segment may be torn by a crash.
+func (w *wal) replay(seg uint64, last bool) error {
+	f, err := os.Open(w.path("wal", seg, ".log"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dec := gob.NewDecoder(bufio.NewReader(f))
+	for {
+		var r walRecord
+		if err := dec.Decode(&r); err != nil {
+			if err == io.EOF || last && errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil
+			}
+			return fmt.Errorf("cmap: wal segment %d: %w", seg, err)
+		}
+		if r.Del {
+			w.m.Delete(r.Key)
+		} else if err := w.m.RestoreEntry(r.SnapshotEntry); err != nil {
+			return err
+		}
+	}
+}
+
+// close waits for a compaction in progress and closes the segment written.
+func (w *wal) close() {
+	w.wg.Wait()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f != nil {
+		w.closeFile()
+	}
+}
+
+// list returns the sequence numbers of the segments and snapshots of dir,
+// in increasing order.
+func (w *wal) list() (segs, snaps []uint64, err error) {
+	files, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, f := range files {
+		name := f.Name()
+		if n, ok := walSeq(name, "wal-", ".log"); ok {
+			segs = append(segs, n)
+		} else if n, ok := walSeq(name, "snapshot-", ".gob"); ok {
+			snaps = append(snaps, n)
+		}
+	}
+	sort.Slice(segs, func(i, j int) bool { return segs[i] < segs[j] })
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i] < snaps[j] })
+	return segs, snaps, nil
+}
+
+func (w *wal) path(prefix string, seq uint64, ext string) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%s-%020d%s", prefix, seq, ext))
+}
+
+func (w *wal) setErr(err error) {
+	w.errMu.Lock()
+	if w.err == nil {
+		w.err = err
+	}
+	w.errMu.Unlock()
+}
+
+func (w *wal) takeErr() error {
+	w.errMu.Lock()
+	defer w.errMu.Unlock()
+	err := w.err
+	w.err = nil
+	return err
+}
+
+// walSeq parses the sequence number of a file named prefix, seq, ext.
+func walSeq(name, prefix, ext string) (uint64, bool) {
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(name[len(prefix):len(name)-len(ext)], 10, 64)
+	return n, err == nil
+}
+
+// syncDir makes the renames in dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
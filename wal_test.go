@@ -0,0 +1,153 @@
+package cmap_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/min1324/cmap"
+)
+
+func TestWAL(t *testing.T) {
+	dir := t.TempDir()
+	m := cmap.New(cmap.WithWAL(dir))
+	for i := 0; i < 10000; i++ {
+		m.Store(i, i)
+	}
+	for i := 0; i < 10000; i += 2 {
+		m.Delete(i)
+	}
+	m.StoreWithTTL("ttl", 1, time.Hour)
+	m.StoreWithTTL("short", 1, 50*time.Millisecond)
+	if err := m.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() = %v", err)
+	}
+	m.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	r, err := cmap.Recover(dir)
+	if err != nil {
+		t.Fatalf("Recover() = %v", err)
+	}
+	if n := r.Len(); n != 5001 {
+		t.Fatalf("Len() = %d after Recover, want 5001", n)
+	}
+	if v, ok := r.Load(9999); !ok || v != 9999 {
+		t.Fatalf("Load(9999) = %v, %v after Recover", v, ok)
+	}
+	if _, ok := r.Load(0); ok {
+		t.Fatalf("deleted key 0 recovered")
+	}
+	if ttl, ok := r.GetTTL("ttl"); !ok || ttl <= 59*time.Minute {
+		t.Fatalf("GetTTL(ttl) = %v, %v after Recover; want about 1h", ttl, ok)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 1 {
+		t.Fatalf("files after Recover = %v, want a snapshot", files)
+	}
+
+	r.Store("a", 1)
+	r.Store("b", 2)
+	if err := r.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() = %v", err)
+	}
+	r.Close()
+	segs, _ := filepath.Glob(filepath.Join(dir, "wal-*.log"))
+	if len(segs) != 1 {
+		t.Fatalf("segments = %v, want 1", segs)
+	}
+	fi, err := os.Stat(segs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(segs[0], fi.Size()-1); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err = cmap.Recover(dir)
+	if err != nil {
+		t.Fatalf("Recover() of a torn log = %v", err)
+	}
+	defer r.Close()
+	if _, ok := r.Load("a"); !ok {
+		t.Fatalf("Load(a) failed after Recover")
+	}
+	if _, ok := r.Load("b"); ok {
+		t.Fatalf("torn record recovered")
+	}
+	if n := r.Len(); n != 5002 {
+		t.Fatalf("Len() = %d after Recover, want 5002", n)
+	}
+}
+
+func TestWALClose(t *testing.T) {
+	dir := t.TempDir()
+	m := cmap.New(cmap.WithWAL(dir))
+	const n = 3000
+	for i := 0; i < n; i++ {
+		m.Store(i, i)
+	}
+	m.Close() // without Flush
+
+	r, err := cmap.Recover(dir)
+	if err != nil {
+		t.Fatalf("Recover() = %v", err)
+	}
+	defer r.Close()
+	if got := r.Len(); got != n {
+		t.Fatalf("Len() = %d after Close and Recover, want %d", got, n)
+	}
+}
+
+func TestWALRacing(t *testing.T) {
+	dir := t.TempDir()
+	m := cmap.New(cmap.WithWAL(dir))
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := i % 5
+				switch i % 3 {
+				case 0:
+					m.Store(key, g)
+				case 1:
+					m.Delete(key)
+				default:
+					m.LoadOrStore(key, g)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	want := cmap.New()
+	m.Range(func(key, value interface{}) bool {
+		want.Store(key, value)
+		return true
+	})
+	m.Close()
+
+	r, err := cmap.Recover(dir)
+	if err != nil {
+		t.Fatalf("Recover() = %v", err)
+	}
+	defer r.Close()
+	if !r.Equal(want, nil) {
+		t.Fatalf("Recover() has %d keys, the map ended with %d", r.Len(), want.Len())
+	}
+}
+
+func TestRecoverEmpty(t *testing.T) {
+	m, err := cmap.Recover(filepath.Join(t.TempDir(), "none"))
+	if err != nil {
+		t.Fatalf("Recover() of a missing dir = %v", err)
+	}
+	defer m.Close()
+	if n := m.Len(); n != 0 {
+		t.Fatalf("Len() = %d, want 0", n)
+	}
+}
@@ -0,0 +1,181 @@
+package cmap
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// watchBuffer is the channel buffer of Watch and Subscribe.
+const watchBuffer = 64
+
+// EventType is the kind of change reported by an Event.
+type EventType uint8
+
+const (
+	// EventStore reports a value stored for a key.
+	EventStore EventType = iota + 1
+	// EventDelete reports a key deleted or expired.
+	EventDelete
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventStore:
+		return "Store"
+	case EventDelete:
+		return "Delete"
+	default:
+		return "EventType(?)"
+	}
+}
+
+// Event is a change of a CMap delivered by Watch and Subscribe.
+type Event struct {
+	Type  EventType
+	Key   interface{}
+	Value interface{} // the stored value, or the deleted one
+}
+
+// Watch returns a channel receiving the events of key, and a cancel func
+// which stops the delivery and closes the channel.
+//
+// Events are sent after the change is made, without blocking: if the
+// receiver falls more than a buffer behind, further events are dropped.
+// Changes racing on the same key may be delivered in either order.
+func (m *CMap) Watch(key interface{}) (<-chan Event, func()) {
+	return m.getHub().add(key, false)
+}
+
+// Subscribe is like Watch, but receives the events of every key.
+func (m *CMap) Subscribe() (<-chan Event, func()) {
+	return m.getHub().add(nil, true)
+}
+
+func (m *CMap) getHub() *watchHub {
+	if h, _ := m.hub.Load().(*watchHub); h != nil {
+		return h
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, _ := m.hub.Load().(*watchHub)
+	if h == nil {
+		h = &watchHub{keys: make(map[interface{}][]*watcher), closed: atomic.LoadUint32(&m.closed) != 0}
+		m.hub.Store(h)
+	}
+	return h
+}
+
+func (m *CMap) notify(typ EventType, key, value interface{}) {
+	if h, _ := m.hub.Load().(*watchHub); h != nil {
+		h.notify(Event{Type: typ, Key: key, Value: value})
+	}
+}
+
+func (m *CMap) stored(key, value interface{}) {
+	if e, ok := value.(*expiring); ok {
+		value = e.value
+	}
+	m.weigh(key, value, 1)
+	m.notify(EventStore, key, value)
+	m.sendChanges()
+	if m.persister != nil {
+		m.persister.send(persistOp{key: key, value: value})
+	}
+}
+
+// watchHub keeps the watchers of a CMap.
+type watchHub struct {
+	mu     sync.RWMutex
+	keys   map[interface{}][]*watcher
+	all    []*watcher
+	closed bool
+}
+
+type watcher struct {
+	ch     chan Event
+	closed bool // ch is closed, changed with the hub locked
+}
+
+func (h *watchHub) add(key interface{}, all bool) (<-chan Event, func()) {
+	w := &watcher{ch: make(chan Event, watchBuffer)}
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		close(w.ch)
+		return w.ch, func() {}
+	}
+	if all {
+		h.all = append(h.all, w)
+	} else {
+		h.keys[key] = append(h.keys[key], w)
+	}
+	h.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			h.mu.Lock()
+			if all {
+				h.all = removeWatcher(h.all, w)
+			} else if ws := removeWatcher(h.keys[key], w); len(ws) > 0 {
+				h.keys[key] = ws
+			} else {
+				delete(h.keys, key)
+			}
+			w.close()
+			h.mu.Unlock()
+		})
+	}
+	return w.ch, cancel
+}
+
+func (h *watchHub) notify(ev Event) {
+	h.mu.RLock()
+	for _, w := range h.keys[ev.Key] {
+		w.send(ev)
+	}
+	for _, w := range h.all {
+		w.send(ev)
+	}
+	h.mu.RUnlock()
+}
+
+// close closes the channel of every watcher, once the map is closed.
+func (h *watchHub) close() {
+	h.mu.Lock()
+	for _, ws := range h.keys {
+		for _, w := range ws {
+			w.close()
+		}
+	}
+	for _, w := range h.all {
+		w.close()
+	}
+	h.keys, h.all, h.closed = nil, nil, true
+	h.mu.Unlock()
+}
+
+func (w *watcher) close() {
+	if !w.closed {
+		close(w.ch)
+		w.closed = true
+	}
+}
+
+func (w *watcher) send(ev Event) {
+	select {
+	case w.ch <- ev:
+	default:
+	}
+}
+
+func removeWatcher(ws []*watcher, w *watcher) []*watcher {
+	for i := range ws {
+		if ws[i] == w {
+			ws[i] = ws[len(ws)-1]
+			ws[len(ws)-1] = nil
+			return ws[:len(ws)-1]
+		}
+	}
+	return ws
+}
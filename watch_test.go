@@ -0,0 +1,41 @@
+package cmap_test
+
+import (
+	"testing"
+
+	"github.com/min1324/cmap"
+)
+
+func TestWatch(t *testing.T) {
+	var m cmap.CMap
+	ch, cancel := m.Watch("a")
+	all, cancelAll := m.Subscribe()
+	defer cancelAll()
+
+	m.Store("a", 1)
+	m.Store("b", 2)
+	m.LoadOrStore("a", 3)
+	m.Delete("a")
+	cancel()
+	cancel()
+	m.Store("a", 4)
+
+	want := []cmap.Event{
+		{Type: cmap.EventStore, Key: "a", Value: 1},
+		{Type: cmap.EventDelete, Key: "a", Value: 1},
+	}
+	var got []cmap.Event
+	for ev := range ch {
+		got = append(got, ev)
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("Watch(a) got %v, want %v", got, want)
+	}
+
+	if n := len(all); n != 4 {
+		t.Fatalf("Subscribe got %d events, want 4", n)
+	}
+	if ev := <-all; ev.Key != "a" || ev.Type != cmap.EventStore {
+		t.Fatalf("Subscribe got %v first", ev)
+	}
+}
@@ -0,0 +1,176 @@
+package cmap
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// The janitor finds the expired keys with hierarchical timer wheels
+// instead of scanning the map: each key stored with a ttl is scheduled in
+// the wheel of its stripe, and each tick of the janitor only visits the
+// keys due at that tick.
+//
+// A wheel has wheelLevels levels of wheelSlots slots. A slot of level l
+// spans wheelSlots^l ticks, and holds the timers due in the next
+// wheelSlots^(l+1) ticks. When the slots of a level all went by, the next
+// slot of the level above is cascaded into it. Timers due past the last
+// level wait in its farthest slot and are cascaded again.
+const (
+	wheelBits    = 6
+	wheelSlots   = 1 << wheelBits
+	wheelLevels  = 4
+	wheelStripes = 64
+)
+
+// timer is a key scheduled to expire at a tick, for the expiring value e.
+// It is dropped once due if the key holds another value by then.
+type timer struct {
+	key  interface{}
+	hash uintptr
+	e    *expiring
+	at   int64 // tick
+}
+
+type wheel struct {
+	mu    sync.Mutex
+	now   int64 // last tick done
+	slots [wheelLevels][wheelSlots][]timer
+}
+
+// wheels are the timer wheels of a map, one per stripe of keys.
+type wheels struct {
+	tick   int64 // ns
+	stripe [wheelStripes]wheel
+}
+
+func newWheels(tick int64) *wheels {
+	w := &wheels{tick: tick}
+	now := nanotime() / tick
+	for i := range w.stripe {
+		w.stripe[i].now = now
+	}
+	return w
+}
+
+// clear drops every timer of w.
+func (w *wheels) clear() {
+	for i := range w.stripe {
+		s := &w.stripe[i]
+		s.mu.Lock()
+		s.slots = [wheelLevels][wheelSlots][]timer{}
+		s.mu.Unlock()
+	}
+}
+
+// schedule schedules the expiration of key, holding e, at the deadline of e.
+func (w *wheels) schedule(key interface{}, hash uintptr, e *expiring) {
+	deadline := atomic.LoadInt64(&e.deadline)
+	if deadline == 0 {
+		return
+	}
+	s := &w.stripe[hash%wheelStripes]
+	s.mu.Lock()
+	s.add(timer{key: key, hash: hash, e: e, at: (deadline + w.tick - 1) / w.tick})
+	s.mu.Unlock()
+}
+
+// add adds t to the slot of its tick, or of the next tick if it is past.
+func (s *wheel) add(t timer) {
+	delta := t.at - s.now
+	if delta <= 0 {
+		delta, t.at = 1, s.now+1
+	}
+	for l := 0; l < wheelLevels; l++ {
+		if delta < 1<<(wheelBits*(l+1)) {
+			i := t.at >> (wheelBits * l) & (wheelSlots - 1)
+			s.slots[l][i] = append(s.slots[l][i], t)
+			return
+		}
+	}
+	// past the last level, wait in its farthest slot
+	l := wheelLevels - 1
+	i := (s.now>>(wheelBits*l) - 1) & (wheelSlots - 1)
+	s.slots[l][i] = append(s.slots[l][i], t)
+}
+
+// advance moves s to tick to, and appends the timers due meanwhile to due.
+func (s *wheel) advance(to int64, due []timer) []timer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.now < to {
+		s.now++
+		// cascade the levels whose slots all went by
+		for l := 1; l < wheelLevels && s.now&(1<<(wheelBits*l)-1) == 0; l++ {
+			i := s.now >> (wheelBits * l) & (wheelSlots - 1)
+			ts := s.slots[l][i]
+			s.slots[l][i] = nil
+			for _, t := range ts {
+				if t.at <= s.now {
+					due = append(due, t)
+				} else {
+					s.add(t)
+				}
+			}
+		}
+		i := s.now & (wheelSlots - 1)
+		due = append(due, s.slots[0][i]...)
+		s.slots[0][i] = nil
+	}
+	return due
+}
+
+// schedule schedules the expiration of key, whose raw value is v, if the
+// map has a janitor.
+func (m *CMap) schedule(key interface{}, hash uintptr, v interface{}) {
+	if m.janitor == nil {
+		return
+	}
+	if e, ok := v.(*expiring); ok {
+		m.janitor.wheels.schedule(key, hash, e)
+	}
+}
+
+// expireDue deletes the keys of the wheels due by now which expired, and
+// schedules again those whose deadline was pushed back.
+func (m *CMap) expireDue(w *wheels) {
+	now := nanotime()
+	var due []timer
+	for i := range w.stripe {
+		due = w.stripe[i].advance(now/w.tick, due[:0])
+		for _, t := range due {
+			m.expireTimer(w, t, now)
+		}
+	}
+	m.checkShrink(m.getNode())
+}
+
+func (m *CMap) expireTimer(w *wheels, t timer, now int64) {
+	for {
+		_, b := m.getNodeAndBucket(t.hash)
+		n, capture, ok := b.wlock(m, t.hash)
+		if !ok {
+			runtime.Gosched()
+			continue
+		}
+		raw, present := b.load(t.key, t.hash)
+		current := present && raw == interface{}(t.e)
+		evicted := current && t.e.expired(now) && b.compareAndDelete(t.key, t.hash, raw)
+		if evicted {
+			atomic.AddInt64(&b.count, -1)
+			m.dropKey(t.hash)
+			if capture {
+				m.record(ChangeExpire, t.key, t.e.value, nil)
+			}
+		}
+		b.wunlock(capture)
+		n.assist()
+		if evicted {
+			m.evicted(t.key, t.e.value)
+		} else if current {
+			// pushed back by a load
+			w.schedule(t.key, t.hash, t.e)
+		}
+		return
+	}
+}